@@ -0,0 +1,139 @@
+// Package billing computes sale amounts and taxes with exact decimal arithmetic.
+package billing
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rounding scopes: the taxable amount, the GST on it, and the invoice total.
+const (
+	ScopeAmount = "amount"
+	ScopeGST    = "gst"
+	ScopeTotal  = "total"
+)
+
+// Rounding modes.
+const (
+	HalfUp   = "half_up"
+	HalfEven = "half_even"
+	Up       = "up"
+	Down     = "down"
+)
+
+// Rule rounds to Places decimal places using Mode.
+type Rule struct {
+	Places int32  `json:"places"`
+	Mode   string `json:"mode"`
+}
+
+// Apply rounds d by the rule. Up and down round away from and towards zero.
+func (r Rule) Apply(d decimal.Decimal) decimal.Decimal {
+	switch r.Mode {
+	case HalfEven:
+		return d.RoundBank(r.Places)
+	case Up:
+		return d.RoundUp(r.Places)
+	case Down:
+		return d.RoundDown(r.Places)
+	default:
+		return d.Round(r.Places)
+	}
+}
+
+// Unit is the smallest step at the rule's precision, e.g. 0.01 for two places.
+func (r Rule) Unit() decimal.Decimal {
+	return decimal.New(1, -r.Places)
+}
+
+// Rules holds one rule per scope.
+type Rules map[string]Rule
+
+// DefaultRules round every scope half-up to paise.
+var DefaultRules = Rules{
+	ScopeAmount: {Places: 2, Mode: HalfUp},
+	ScopeGST:    {Places: 2, Mode: HalfUp},
+	ScopeTotal:  {Places: 2, Mode: HalfUp},
+}
+
+func (rs Rules) get(scope string) Rule {
+	if r, ok := rs[scope]; ok {
+		return r
+	}
+	return DefaultRules[scope]
+}
+
+// SaleAmounts is the computation behind a sale's amount, GST and total. It is
+// stored with the sale so the figures can be explained later.
+type SaleAmounts struct {
+	RatePerTon        decimal.Decimal `json:"rate_per_ton"`
+	BillingWeightTons decimal.Decimal `json:"billing_weight_tons"`
+	GSTPercentage     decimal.Decimal `json:"gst_percentage"`
+	// ExactAmount is rate x billing weight before rounding.
+	ExactAmount decimal.Decimal `json:"exact_amount"`
+	Amount      decimal.Decimal `json:"amount"`
+	// ExactGST is the GST on the rounded Amount before rounding.
+	ExactGST  decimal.Decimal `json:"exact_gst"`
+	GSTAmount decimal.Decimal `json:"gst_amount"`
+	// RoundOff is what rounding the total added to Amount + GSTAmount.
+	RoundOff    decimal.Decimal `json:"round_off"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+	Rounding    Rules           `json:"rounding"`
+}
+
+// ComputeSaleAmounts prices billingWeightTons at ratePerTon and adds GST.
+// The GST is taken on the rounded amount, and the total is rounded last.
+func ComputeSaleAmounts(ratePerTon, billingWeightTons, gstPercentage decimal.Decimal, rules Rules) SaleAmounts {
+	s := SaleAmounts{
+		RatePerTon:        ratePerTon,
+		BillingWeightTons: billingWeightTons,
+		GSTPercentage:     gstPercentage,
+		Rounding:          Rules{ScopeAmount: rules.get(ScopeAmount), ScopeGST: rules.get(ScopeGST), ScopeTotal: rules.get(ScopeTotal)},
+	}
+	s.ExactAmount = ratePerTon.Mul(billingWeightTons)
+	s.Amount = rules.get(ScopeAmount).Apply(s.ExactAmount)
+	s.ExactGST = s.Amount.Mul(gstPercentage).Div(decimal.NewFromInt(100))
+	s.GSTAmount = rules.get(ScopeGST).Apply(s.ExactGST)
+	sum := s.Amount.Add(s.GSTAmount)
+	s.TotalAmount = rules.get(ScopeTotal).Apply(sum)
+	s.RoundOff = s.TotalAmount.Sub(sum)
+	return s
+}
+
+// MismatchError is returned when a client-computed figure disagrees with the
+// server's by a whole unit at the scope's precision or more.
+type MismatchError struct {
+	Field     string
+	Expected  decimal.Decimal
+	Submitted decimal.Decimal
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s is %s, submitted %s", e.Field, e.Expected, e.Submitted)
+}
+
+// Check compares client figures with the computation. A zero submitted value
+// means the client left the figure to the server and is not checked.
+func (s SaleAmounts) Check(amount, gstAmount, totalAmount float64) error {
+	checks := []struct {
+		field     string
+		scope     string
+		expected  decimal.Decimal
+		submitted float64
+	}{
+		{"amount", ScopeAmount, s.Amount, amount},
+		{"gst_amount", ScopeGST, s.GSTAmount, gstAmount},
+		{"total_amount", ScopeTotal, s.TotalAmount, totalAmount},
+	}
+	for _, c := range checks {
+		if c.submitted == 0 {
+			continue
+		}
+		submitted := decimal.NewFromFloat(c.submitted)
+		if submitted.Sub(c.expected).Abs().GreaterThanOrEqual(s.Rounding[c.scope].Unit()) {
+			return &MismatchError{Field: c.field, Expected: c.expected, Submitted: submitted}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestRuleApply(t *testing.T) {
+	tests := []struct {
+		mode   string
+		places int32
+		in     string
+		want   string
+	}{
+		{HalfUp, 2, "10.125", "10.13"},
+		{HalfUp, 2, "10.124", "10.12"},
+		{HalfUp, 2, "-10.125", "-10.13"},
+		{HalfUp, 1, "10.25", "10.3"},
+		{HalfUp, 0, "10.5", "11"},
+		{HalfUp, 0, "10.49", "10"},
+
+		{HalfEven, 2, "10.125", "10.12"},
+		{HalfEven, 2, "10.135", "10.14"},
+		{HalfEven, 2, "10.1251", "10.13"},
+		{HalfEven, 1, "10.25", "10.2"},
+		{HalfEven, 0, "10.5", "10"},
+		{HalfEven, 0, "11.5", "12"},
+
+		{Up, 2, "10.121", "10.13"},
+		{Up, 2, "10.12", "10.12"},
+		{Up, 2, "-10.121", "-10.13"},
+		{Up, 1, "10.01", "10.1"},
+		{Up, 0, "10.01", "11"},
+
+		{Down, 2, "10.129", "10.12"},
+		{Down, 2, "-10.129", "-10.12"},
+		{Down, 1, "10.99", "10.9"},
+		{Down, 0, "10.99", "10"},
+
+		// An unknown mode falls back to half-up.
+		{"", 2, "10.125", "10.13"},
+	}
+	for _, tt := range tests {
+		got := Rule{Places: tt.places, Mode: tt.mode}.Apply(dec(tt.in))
+		if !got.Equal(dec(tt.want)) {
+			t.Errorf("%s to %d places: Apply(%s) = %s, want %s", tt.mode, tt.places, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRuleUnit(t *testing.T) {
+	for places, want := range map[int32]string{0: "1", 1: "0.1", 2: "0.01"} {
+		if got := (Rule{Places: places}).Unit(); !got.Equal(dec(want)) {
+			t.Errorf("Unit at %d places = %s, want %s", places, got, want)
+		}
+	}
+}
+
+func TestComputeSaleAmounts(t *testing.T) {
+	tests := []struct {
+		name                       string
+		rate, weight, gst          string
+		rules                      Rules
+		amount, gstAmount, total   string
+		exactAmount, exactGST, off string
+	}{
+		{
+			name: "whole rupees", rate: "12000", weight: "2.5", gst: "18",
+			amount: "30000", gstAmount: "5400", total: "35400", exactAmount: "30000", exactGST: "5400", off: "0",
+		},
+		{
+			// 1234.5 x 1.001 = 1235.7345; GST on 1235.73 at 5% = 61.7865.
+			name: "paise under default rules", rate: "1234.5", weight: "1.001", gst: "5",
+			amount: "1235.73", gstAmount: "61.79", total: "1297.52", exactAmount: "1235.7345", exactGST: "61.7865", off: "0",
+		},
+		{
+			// 0.001 x 5 = 0.005 sits exactly on the half-paisa boundary.
+			name: "half paisa rounds up by default", rate: "5", weight: "0.001", gst: "0",
+			amount: "0.01", gstAmount: "0", total: "0.01", exactAmount: "0.005", exactGST: "0", off: "0",
+		},
+		{
+			name: "half paisa rounds to even", rate: "5", weight: "0.001", gst: "0",
+			rules:  Rules{ScopeAmount: {Places: 2, Mode: HalfEven}},
+			amount: "0", gstAmount: "0", total: "0", exactAmount: "0.005", exactGST: "0", off: "0",
+		},
+		{
+			// GST on 100.10 at 18% = 18.018; the total is rounded to the rupee.
+			name: "total rounded to rupees", rate: "100.1", weight: "1", gst: "18",
+			rules:  Rules{ScopeTotal: {Places: 0, Mode: HalfUp}},
+			amount: "100.1", gstAmount: "18.02", total: "118", exactAmount: "100.1", exactGST: "18.018", off: "-0.12",
+		},
+		{
+			name: "gst rounded up, amount down", rate: "333.33", weight: "0.333", gst: "12",
+			rules:  Rules{ScopeAmount: {Places: 2, Mode: Down}, ScopeGST: {Places: 1, Mode: Up}},
+			amount: "110.99", gstAmount: "13.4", total: "124.39", exactAmount: "110.99889", exactGST: "13.3188", off: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ComputeSaleAmounts(dec(tt.rate), dec(tt.weight), dec(tt.gst), tt.rules)
+			for _, f := range []struct {
+				field     string
+				got, want decimal.Decimal
+			}{
+				{"ExactAmount", s.ExactAmount, dec(tt.exactAmount)},
+				{"Amount", s.Amount, dec(tt.amount)},
+				{"ExactGST", s.ExactGST, dec(tt.exactGST)},
+				{"GSTAmount", s.GSTAmount, dec(tt.gstAmount)},
+				{"TotalAmount", s.TotalAmount, dec(tt.total)},
+				{"RoundOff", s.RoundOff, dec(tt.off)},
+			} {
+				if !f.got.Equal(f.want) {
+					t.Errorf("%s = %s, want %s", f.field, f.got, f.want)
+				}
+			}
+			if len(s.Rounding) != 3 {
+				t.Errorf("Rounding records %d scopes, want all 3", len(s.Rounding))
+			}
+		})
+	}
+}
+
+func TestSaleAmountsCheck(t *testing.T) {
+	s := ComputeSaleAmounts(dec("1234.5"), dec("1.001"), dec("5"), nil)
+	rupees := ComputeSaleAmounts(dec("100.1"), dec("1"), dec("18"), Rules{ScopeTotal: {Places: 0, Mode: HalfUp}})
+
+	tests := []struct {
+		name               string
+		s                  SaleAmounts
+		amount, gst, total float64
+		wantField          string
+	}{
+		{name: "exact figures", s: s, amount: 1235.73, gst: 61.79, total: 1297.52},
+		{name: "figures left to the server", s: s},
+		{name: "within a paisa", s: s, amount: 1235.7345, gst: 61.7865, total: 1297.5299},
+		{name: "amount off by a paisa", s: s, amount: 1235.74, wantField: "amount"},
+		{name: "amount off below", s: s, amount: 1235.72, wantField: "amount"},
+		{name: "gst off by a paisa", s: s, amount: 1235.73, gst: 61.8, wantField: "gst_amount"},
+		{name: "total off by a paisa", s: s, total: 1297.53, wantField: "total_amount"},
+		{name: "rupee total within a rupee", s: rupees, total: 118.99},
+		{name: "rupee total off by a rupee", s: rupees, total: 119, wantField: "total_amount"},
+	}
+	for _, tt := range tests {
+		err := tt.s.Check(tt.amount, tt.gst, tt.total)
+		if tt.wantField == "" {
+			if err != nil {
+				t.Errorf("%s: Check = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		var mismatch *MismatchError
+		if !errors.As(err, &mismatch) || mismatch.Field != tt.wantField {
+			t.Errorf("%s: Check = %v, want a mismatch on %s", tt.name, err, tt.wantField)
+		}
+	}
+}
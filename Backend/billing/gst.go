@@ -0,0 +1,237 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// States maps GST state codes to state and union territory names.
+var States = map[string]string{
+	"01": "Jammu and Kashmir",
+	"02": "Himachal Pradesh",
+	"03": "Punjab",
+	"04": "Chandigarh",
+	"05": "Uttarakhand",
+	"06": "Haryana",
+	"07": "Delhi",
+	"08": "Rajasthan",
+	"09": "Uttar Pradesh",
+	"10": "Bihar",
+	"11": "Sikkim",
+	"12": "Arunachal Pradesh",
+	"13": "Nagaland",
+	"14": "Manipur",
+	"15": "Mizoram",
+	"16": "Tripura",
+	"17": "Meghalaya",
+	"18": "Assam",
+	"19": "West Bengal",
+	"20": "Jharkhand",
+	"21": "Odisha",
+	"22": "Chhattisgarh",
+	"23": "Madhya Pradesh",
+	"24": "Gujarat",
+	"26": "Dadra and Nagar Haveli and Daman and Diu",
+	"27": "Maharashtra",
+	"29": "Karnataka",
+	"30": "Goa",
+	"31": "Lakshadweep",
+	"32": "Kerala",
+	"33": "Tamil Nadu",
+	"34": "Puducherry",
+	"35": "Andaman and Nicobar Islands",
+	"36": "Telangana",
+	"37": "Andhra Pradesh",
+	"38": "Ladakh",
+	"97": "Other Territory",
+}
+
+// StateLabel formats a state code as "29-Karnataka", as printed for the place of supply.
+func StateLabel(code string) string {
+	if name, ok := States[code]; ok {
+		return code + "-" + name
+	}
+	return code
+}
+
+var gstinPattern = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+
+const gstinCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// NormalizeGSTIN upper-cases a GSTIN and strips spaces.
+func NormalizeGSTIN(gstin string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(gstin), " ", ""))
+}
+
+// ValidGSTIN reports whether gstin is well formed for a known state and its
+// last character is the correct check digit.
+func ValidGSTIN(gstin string) bool {
+	if !gstinPattern.MatchString(gstin) {
+		return false
+	}
+	if _, ok := States[gstin[:2]]; !ok {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 14; i++ {
+		product := strings.IndexByte(gstinCharset, gstin[i]) * (i%2 + 1)
+		sum += product/36 + product%36
+	}
+	return gstin[14] == gstinCharset[(36-sum%36)%36]
+}
+
+// indiaTime is Indian Standard Time. India has no daylight saving, so a fixed
+// offset is exact and needs no time zone database.
+var indiaTime = time.FixedZone("IST", 5*60*60+30*60)
+
+// Today returns the current date in India, the date invoices are issued on.
+func Today() time.Time {
+	now := time.Now().In(indiaTime)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, indiaTime)
+}
+
+// FinancialYear returns the April-to-March financial year containing t, as
+// "2026-27". The year turns at midnight on 1 April in India.
+func FinancialYear(t time.Time) string {
+	t = t.In(indiaTime)
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// InvoiceNumber formats the seq-th invoice of financialYear as
+// "INV/26-27/000042", within the 16 characters the e-invoice schema allows.
+func InvoiceNumber(financialYear string, seq int) string {
+	return fmt.Sprintf("INV/%s/%06d", financialYear[2:], seq)
+}
+
+// TaxSplit is GST divided between the central and state taxes for a supply
+// within the seller's state, or levied as integrated tax across states.
+type TaxSplit struct {
+	Interstate bool
+	CGSTRate   decimal.Decimal
+	CGST       decimal.Decimal
+	SGSTRate   decimal.Decimal
+	SGST       decimal.Decimal
+	IGSTRate   decimal.Decimal
+	IGST       decimal.Decimal
+}
+
+// SplitGST divides gst, charged at rate percent, by comparing the seller's
+// state with the place of supply. Within a state the odd paisa of an uneven
+// split goes to CGST.
+func SplitGST(gst, rate decimal.Decimal, sellerState, placeOfSupply string) TaxSplit {
+	if sellerState != placeOfSupply {
+		return TaxSplit{Interstate: true, IGSTRate: rate, IGST: gst}
+	}
+	two := decimal.NewFromInt(2)
+	cgst := gst.Div(two).RoundUp(2)
+	return TaxSplit{
+		CGSTRate: rate.Div(two),
+		CGST:     cgst,
+		SGSTRate: rate.Div(two),
+		SGST:     gst.Sub(cgst),
+	}
+}
+
+// Seller is the registered business issuing tax invoices.
+type Seller struct {
+	GSTIN     string
+	LegalName string
+	Address   string
+	Location  string
+	Pincode   string
+}
+
+// ErrSellerNotConfigured is returned when INVOICE_SELLER_GSTIN is missing or invalid.
+var ErrSellerNotConfigured = errors.New("seller GSTIN is not configured")
+
+// SellerFromEnv reads INVOICE_SELLER_GSTIN, INVOICE_SELLER_LOCATION and
+// INVOICE_SELLER_PINCODE; the legal name and address are the letterhead's
+// DOCUMENT_ORG_NAME and DOCUMENT_ORG_ADDRESS.
+func SellerFromEnv() (Seller, error) {
+	s := Seller{
+		GSTIN:     NormalizeGSTIN(os.Getenv("INVOICE_SELLER_GSTIN")),
+		LegalName: os.Getenv("DOCUMENT_ORG_NAME"),
+		Address:   os.Getenv("DOCUMENT_ORG_ADDRESS"),
+		Location:  os.Getenv("INVOICE_SELLER_LOCATION"),
+		Pincode:   os.Getenv("INVOICE_SELLER_PINCODE"),
+	}
+	if !ValidGSTIN(s.GSTIN) {
+		return s, ErrSellerNotConfigured
+	}
+	if s.LegalName == "" {
+		s.LegalName = "MRF"
+	}
+	return s, nil
+}
+
+// StateCode is the state the seller is registered in.
+func (s Seller) StateCode() string {
+	return s.GSTIN[:2]
+}
+
+var (
+	ones = []string{"", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten",
+		"Eleven", "Twelve", "Thirteen", "Fourteen", "Fifteen", "Sixteen", "Seventeen", "Eighteen", "Nineteen"}
+	tens = []string{"", "", "Twenty", "Thirty", "Forty", "Fifty", "Sixty", "Seventy", "Eighty", "Ninety"}
+)
+
+// belowHundred and belowThousand spell n, which must be in range.
+func belowHundred(n int64) string {
+	if n < 20 {
+		return ones[n]
+	}
+	return strings.TrimSpace(tens[n/10] + " " + ones[n%10])
+}
+
+func belowThousand(n int64) string {
+	if n < 100 {
+		return belowHundred(n)
+	}
+	return strings.TrimSpace(ones[n/100] + " Hundred " + belowHundred(n%100))
+}
+
+// indianWords spells n using the Indian crore and lakh grouping.
+func indianWords(n int64) string {
+	var parts []string
+	if crores := n / 10000000; crores > 0 {
+		parts = append(parts, indianWords(crores), "Crore")
+	}
+	if lakhs := n / 100000 % 100; lakhs > 0 {
+		parts = append(parts, belowHundred(lakhs), "Lakh")
+	}
+	if thousands := n / 1000 % 100; thousands > 0 {
+		parts = append(parts, belowHundred(thousands), "Thousand")
+	}
+	if rest := n % 1000; rest > 0 {
+		parts = append(parts, belowThousand(rest))
+	}
+	return strings.Join(parts, " ")
+}
+
+// AmountInWords spells a rupee amount as printed on invoices, such as
+// "Rupees One Lakh Twenty Thousand and Fifty Paise Only".
+func AmountInWords(amount decimal.Decimal) string {
+	amount = amount.Abs().Round(2)
+	rupees := amount.IntPart()
+	paise := amount.Sub(decimal.NewFromInt(rupees)).Shift(2).IntPart()
+
+	words := indianWords(rupees)
+	if words == "" {
+		words = "Zero"
+	}
+	words = "Rupees " + words
+	if paise > 0 {
+		words += " and " + belowHundred(paise) + " Paise"
+	}
+	return words + " Only"
+}
@@ -0,0 +1,118 @@
+package billing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidGSTIN(t *testing.T) {
+	tests := []struct {
+		gstin string
+		want  bool
+	}{
+		{"27AAPFU0939F1ZV", true},
+		{"29AAGCB7383J1Z4", true},
+		{"36AABCT1332L1ZF", true},
+		{"27AAPFU0939F1ZA", false}, // wrong check digit
+		{"29AAGCB7383J1Z5", false}, // wrong check digit
+		{"99AAPFU0939F1ZV", false}, // unknown state
+		{"27AAPFU0939F1YV", false}, // fourteenth character must be Z
+		{"27AAPFU0939F0ZV", false}, // entity number starts at 1
+		{"27aapfu0939f1zv", false}, // not normalized
+		{"27AAPFU0939F1Z", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidGSTIN(tt.gstin); got != tt.want {
+			t.Errorf("ValidGSTIN(%q) = %v, want %v", tt.gstin, got, tt.want)
+		}
+	}
+	if got := NormalizeGSTIN(" 27aapfu0939f1zv "); !ValidGSTIN(got) {
+		t.Errorf("NormalizeGSTIN produced %q, which does not validate", got)
+	}
+}
+
+func TestSplitGST(t *testing.T) {
+	tests := []struct {
+		name               string
+		gst, rate          string
+		seller, supply     string
+		cgst, sgst, igst   string
+		cgstRate, igstRate string
+		wantInterstate     bool
+	}{
+		{name: "even intra-state", gst: "90.00", rate: "18", seller: "27", supply: "27",
+			cgst: "45", sgst: "45", igst: "0", cgstRate: "9", igstRate: "0"},
+		{name: "odd paisa goes to CGST", gst: "61.79", rate: "5", seller: "27", supply: "27",
+			cgst: "30.90", sgst: "30.89", igst: "0", cgstRate: "2.5", igstRate: "0"},
+		{name: "single paisa", gst: "0.01", rate: "12", seller: "29", supply: "29",
+			cgst: "0.01", sgst: "0", igst: "0", cgstRate: "6", igstRate: "0"},
+		{name: "interstate", gst: "61.79", rate: "5", seller: "27", supply: "29",
+			cgst: "0", sgst: "0", igst: "61.79", cgstRate: "0", igstRate: "5", wantInterstate: true},
+	}
+	for _, tt := range tests {
+		split := SplitGST(dec(tt.gst), dec(tt.rate), tt.seller, tt.supply)
+		if split.Interstate != tt.wantInterstate {
+			t.Errorf("%s: Interstate = %v, want %v", tt.name, split.Interstate, tt.wantInterstate)
+		}
+		for _, f := range []struct {
+			field     string
+			got, want string
+		}{
+			{"CGST", split.CGST.String(), dec(tt.cgst).String()},
+			{"SGST", split.SGST.String(), dec(tt.sgst).String()},
+			{"IGST", split.IGST.String(), dec(tt.igst).String()},
+			{"CGSTRate", split.CGSTRate.String(), dec(tt.cgstRate).String()},
+			{"SGSTRate", split.SGSTRate.String(), dec(tt.cgstRate).String()},
+			{"IGSTRate", split.IGSTRate.String(), dec(tt.igstRate).String()},
+		} {
+			if f.got != f.want {
+				t.Errorf("%s: %s = %s, want %s", tt.name, f.field, f.got, f.want)
+			}
+		}
+		if total := split.CGST.Add(split.SGST).Add(split.IGST); !total.Equal(dec(tt.gst)) {
+			t.Errorf("%s: split adds up to %s, want %s", tt.name, total, tt.gst)
+		}
+	}
+}
+
+func TestFinancialYear(t *testing.T) {
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2026, time.March, 31, 23, 59, 59, 0, indiaTime), "2025-26"},
+		{time.Date(2026, time.April, 1, 0, 0, 0, 0, indiaTime), "2026-27"},
+		// 18:30 UTC on 31 March is already 1 April in India.
+		{time.Date(2026, time.March, 31, 18, 29, 59, 0, time.UTC), "2025-26"},
+		{time.Date(2026, time.March, 31, 18, 30, 0, 0, time.UTC), "2026-27"},
+		{time.Date(2099, time.December, 31, 12, 0, 0, 0, indiaTime), "2099-00"},
+		{time.Date(2030, time.January, 15, 12, 0, 0, 0, indiaTime), "2029-30"},
+	}
+	for _, tt := range tests {
+		if got := FinancialYear(tt.at); got != tt.want {
+			t.Errorf("FinancialYear(%s) = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestInvoiceNumber(t *testing.T) {
+	tests := []struct {
+		fy   string
+		seq  int
+		want string
+	}{
+		{"2026-27", 1, "INV/26-27/000001"},
+		{"2026-27", 42, "INV/26-27/000042"},
+		{"2099-00", 999999, "INV/99-00/999999"},
+	}
+	for _, tt := range tests {
+		got := InvoiceNumber(tt.fy, tt.seq)
+		if got != tt.want {
+			t.Errorf("InvoiceNumber(%q, %d) = %q, want %q", tt.fy, tt.seq, got, tt.want)
+		}
+		if len(got) > 16 {
+			t.Errorf("InvoiceNumber(%q, %d) = %q is longer than the 16 characters an e-invoice allows", tt.fy, tt.seq, got)
+		}
+	}
+}
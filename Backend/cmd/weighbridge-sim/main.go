@@ -0,0 +1,114 @@
+// Command weighbridge-sim imitates a weighbridge indicator's continuous output
+// so the reader can be exercised without hardware. It serves a TCP socket or
+// creates a pseudo-terminal, and changes the weight whenever a new value in kg
+// is typed on stdin, reporting motion while the weight settles.
+//
+//	go run ./cmd/weighbridge-sim -listen 127.0.0.1:4001 -protocol ascii
+//	go run ./cmd/weighbridge-sim -pty -protocol toledo
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/weighbridge"
+
+	"github.com/creack/pty"
+)
+
+type indicator struct {
+	mu       sync.Mutex
+	weight   float64
+	target   float64
+	settleAt time.Time
+}
+
+func (ind *indicator) set(target float64, settle time.Duration) {
+	ind.mu.Lock()
+	defer ind.mu.Unlock()
+	ind.target, ind.settleAt = target, time.Now().Add(settle)
+}
+
+// frame moves the weight towards the target and reports motion until it settles.
+func (ind *indicator) frame() weighbridge.Frame {
+	ind.mu.Lock()
+	defer ind.mu.Unlock()
+	if time.Now().Before(ind.settleAt) {
+		ind.weight += (ind.target - ind.weight) / 3
+		return weighbridge.Frame{WeightKg: ind.weight, Motion: true}
+	}
+	ind.weight = ind.target
+	return weighbridge.Frame{WeightKg: ind.weight}
+}
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:4001", "TCP address to serve")
+	usePTY := flag.Bool("pty", false, "create a pseudo-terminal instead of listening on TCP")
+	protocolName := flag.String("protocol", "ascii", "output protocol: ascii or toledo")
+	initial := flag.Float64("weight", 0, "initial weight in kg")
+	interval := flag.Duration("interval", 100*time.Millisecond, "time between frames")
+	settle := flag.Duration("settle", 2*time.Second, "how long a new weight reports motion")
+	flag.Parse()
+
+	protocol, err := weighbridge.ProtocolByName(*protocolName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ind := &indicator{weight: *initial, target: *initial}
+
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			kg, err := strconv.ParseFloat(strings.TrimSpace(scanner.Text()), 64)
+			if err != nil {
+				log.Printf("enter a weight in kg")
+				continue
+			}
+			ind.set(kg, *settle)
+		}
+	}()
+
+	if *usePTY {
+		master, slave, err := pty.Open()
+		if err != nil {
+			log.Fatalf("Failed to open pty: %v", err)
+		}
+		defer slave.Close()
+		fmt.Printf("Indicator on serial://%s\n", slave.Name())
+		stream(master, protocol, ind, *interval)
+		return
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	fmt.Printf("Indicator on tcp://%s\n", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Fatalf("Accept failed: %v", err)
+		}
+		go stream(conn, protocol, ind, *interval)
+	}
+}
+
+func stream(w io.WriteCloser, protocol weighbridge.Protocol, ind *indicator, interval time.Duration) {
+	defer w.Close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := w.Write(protocol.Encode(ind.frame())); err != nil {
+			return
+		}
+	}
+}
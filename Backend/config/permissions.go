@@ -8,12 +8,29 @@ var AllPermissions = []string{
 	"manage:users",
 	"approve:users",
 	"manage:roles",
+	"view:audit_log",
+	"manage:service_accounts",
 
 	// Dashboard
 	"view:dashboard",
 
 	// Cashbook & Sales
 	"view:cashbook",
+	"create:cashbook_entry",
+	"approve:cashbook_entry",
+	"view:sales",
+	"create:sales",
+	"cancel:sales",
+	"issue:invoices",
+	"manage:invoicing",
+	"view:receivables",
+	"record:payments",
+	"manage:sale_tolerances",
+	"manage:sale_rounding",
+	"resolve:sale_disputes",
+
+	// Partners (sources, destinations, buyers, transporters)
+	"manage:partners",
 
 	// Inward Entries
 	"create:inward_entry",
@@ -22,6 +39,11 @@ var AllPermissions = []string{
 	"delete:inward_entry",
 	"log:inbound_material",
 
+	// Vehicle registry
+	"view:vehicles",
+	"manage:vehicles",
+	"blacklist:vehicles",
+
 	// Sorting
 	"create:sorting_log",
 	"log:sorted_bale",
@@ -51,6 +73,33 @@ var AllPermissions = []string{
 	// --- NEW INVENTORY & HR PERMISSIONS ---
 	"view:inventory",
 	"manage:inventory_audit",
+	"manage:inventory_rules",
 	"manage:employees",
 	"manage:attendance",
 }
+
+// PermissionDefaults maps a permission to the existing permissions whose roles
+// should receive it automatically. SyncPermissions applies it once, at the moment
+// the new permission is first added to the database, so admins can revoke the
+// grant afterwards without it coming back.
+var PermissionDefaults = map[string][]string{
+	"create:cashbook_entry":   {"view:cashbook", "manage:users"},
+	"approve:cashbook_entry":  {"manage:users"},
+	"view:sales":              {"view:cashbook", "manage:users"},
+	"create:sales":            {"view:cashbook", "manage:users"},
+	"manage:partners":         {"create:inward_entry", "view:cashbook", "manage:users"},
+	"view:audit_log":          {"manage:users"},
+	"manage:service_accounts": {"manage:users"},
+	"manage:inventory_rules":  {"manage:inventory_audit"},
+	"view:vehicles":           {"create:inward_entry", "complete:inward_entry", "manage:users"},
+	"manage:vehicles":         {"manage:partners", "manage:users"},
+	"blacklist:vehicles":      {"manage:users"},
+	"manage:sale_tolerances":  {"approve:cashbook_entry", "manage:users"},
+	"resolve:sale_disputes":   {"approve:cashbook_entry", "manage:users"},
+	"manage:sale_rounding":    {"approve:cashbook_entry", "manage:users"},
+	"cancel:sales":            {"approve:cashbook_entry", "manage:users"},
+	"issue:invoices":          {"create:sales"},
+	"manage:invoicing":        {"approve:cashbook_entry", "manage:users"},
+	"view:receivables":        {"view:sales", "view:cashbook"},
+	"record:payments":         {"create:cashbook_entry"},
+}
@@ -0,0 +1,81 @@
+// Backend/config/roles.go
+package config
+
+import "github.com/solaris-hms/mrf-backend/models"
+
+// RoleTemplates are the built-in roles created on first start. Each template is
+// seeded once; renaming, editing or deleting the resulting role afterwards is
+// left to the admins. The templates can also be used as a starting point for new roles.
+var RoleTemplates = []models.RoleTemplate{
+	{
+		Name:        "Plant Head",
+		Description: "Oversees daily plant operations, stock, approvals and reporting.",
+		Permissions: []string{
+			"view:dashboard",
+			"view:inward_entries",
+			"create:sorting_log",
+			"view:inventory",
+			"manage:inventory_audit",
+			"manage:inventory_rules",
+			"view:cashbook",
+			"approve:cashbook_entry",
+			"view:sales",
+			"cancel:sales",
+			"manage:sale_tolerances",
+			"manage:sale_rounding",
+			"manage:invoicing",
+			"view:receivables",
+			"resolve:sale_disputes",
+			"manage:partners",
+			"view:vehicles",
+			"manage:vehicles",
+			"blacklist:vehicles",
+			"view:assets",
+			"view:vendors",
+			"manage:attendance",
+			"create:plant_head_report",
+			"view:reports",
+			"generate:reports",
+		},
+	},
+	{
+		Name:        "Weighbridge Operator",
+		Description: "Records vehicles at the weighbridge and completes weighments.",
+		Permissions: []string{
+			"view:dashboard",
+			"create:inward_entry",
+			"view:inward_entries",
+			"complete:inward_entry",
+			"view:vehicles",
+			"log:inbound_material",
+		},
+	},
+	{
+		Name:        "Accountant",
+		Description: "Maintains the cashbook, sales and buyer records.",
+		Permissions: []string{
+			"view:dashboard",
+			"view:cashbook",
+			"create:cashbook_entry",
+			"view:sales",
+			"create:sales",
+			"issue:invoices",
+			"view:receivables",
+			"record:payments",
+			"manage:partners",
+			"view:vendors",
+			"view:reports",
+		},
+	},
+	{
+		Name:        "HR",
+		Description: "Manages employees, attendance and workforce reporting.",
+		Permissions: []string{
+			"view:dashboard",
+			"manage:employees",
+			"manage:attendance",
+			"create:workforce_material_report",
+			"view:reports",
+		},
+	},
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// APIKeyPrefix starts every API key, which lets AuthMiddleware tell keys from JWTs.
+const APIKeyPrefix = "mrf_"
+
+// ErrInvalidAPIKey is returned when an API key is unknown, revoked, expired or
+// belongs to a service account that is no longer active.
+var ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
+// HashAPIKey returns the value stored for an API key. Keys carry enough entropy
+// that a fast hash is sufficient.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateServiceAccount adds an active service account with the given roles.
+// The password hash should be of a random value nobody knows, as service accounts never log in.
+func (db *DB) CreateServiceAccount(req *models.CreateServiceAccountRequest, email, passwordHash string, actorID int) (*models.ServiceAccount, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	account := models.ServiceAccount{Name: req.Name, Status: models.UserStatusActive}
+	if req.Description != "" {
+		account.Description = &req.Description
+	}
+	query := `
+		INSERT INTO users (full_name, email, designation, password_hash, is_approved, status, account_type,
+			status_changed_at, status_changed_by_user_id)
+		VALUES ($1, $2, $3, $4, true, 'active', 'service', NOW(), $5)
+		RETURNING id, created_at`
+	err = tx.QueryRow(context.Background(), query, req.Name, email, account.Description, passwordHash, actorID).Scan(&account.ID, &account.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertUserRoles(tx, account.ID, req.RoleIDs); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(context.Background(), `SELECT array_agg(name ORDER BY name) FROM roles WHERE id = ANY($1)`, req.RoleIDs).Scan(&account.Roles)
+	if err != nil {
+		return nil, err
+	}
+	return &account, tx.Commit(context.Background())
+}
+
+// GetServiceAccounts lists every service account with its roles and number of usable keys.
+func (db *DB) GetServiceAccounts() ([]models.ServiceAccount, error) {
+	query := `
+		SELECT u.id, u.full_name, u.designation, u.status, u.created_at,
+			COALESCE((SELECT array_agg(DISTINCT r.name) FROM user_roles ur JOIN roles r ON ur.role_id = r.id WHERE ur.user_id = u.id), '{}'),
+			(SELECT COUNT(*) FROM api_keys k WHERE k.user_id = u.id AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW()))
+		FROM users u
+		WHERE u.account_type = 'service'
+		ORDER BY u.full_name ASC`
+	rows, err := db.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.ServiceAccount
+	for rows.Next() {
+		var a models.ServiceAccount
+		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.Status, &a.CreatedAt, &a.Roles, &a.ActiveKeys); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
+
+// IsServiceAccount reports whether the user exists and is a service account.
+func (db *DB) IsServiceAccount(userID int) (bool, error) {
+	var isService bool
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND account_type = 'service')`
+	err := db.pool.QueryRow(context.Background(), query, userID).Scan(&isService)
+	return isService, err
+}
+
+// CreateAPIKey stores a new key for a service account and fills in its ID and creation time.
+func (db *DB) CreateAPIKey(key *models.APIKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_by_user_id)
+		VALUES ($1, $2, $3, $4, COALESCE($5::text[], '{}'), $6, $7)
+		RETURNING id, created_at`
+	return db.pool.QueryRow(context.Background(), query,
+		key.UserID, key.Name, key.Prefix, keyHash, key.Scopes, key.ExpiresAt, key.CreatedByUserID,
+	).Scan(&key.ID, &key.CreatedAt)
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, expires_at, created_by_user_id, created_at,
+	last_used_at, last_used_ip, revoked_at, rotated_to_key_id`
+
+func scanAPIKey(row pgx.Row, k *models.APIKey) error {
+	return row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.ExpiresAt, &k.CreatedByUserID, &k.CreatedAt,
+		&k.LastUsedAt, &k.LastUsedIP, &k.RevokedAt, &k.RotatedToKeyID)
+}
+
+// GetAPIKeys lists a service account's keys, newest first.
+func (db *DB) GetAPIKeys(userID int) ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+	rows, err := db.pool.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey disables a key immediately. It returns pgx.ErrNoRows if the
+// account has no such key that is still unrevoked.
+func (db *DB) RevokeAPIKey(userID, keyID int) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	tag, err := db.pool.Exec(context.Background(), query, keyID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// RotateAPIKey replaces a usable key with a new one carrying the same name, scopes
+// and lifetime. The old key stops working after grace, or immediately if grace is zero.
+// It returns pgx.ErrNoRows if the key does not exist or is no longer usable.
+func (db *DB) RotateAPIKey(userID, keyID int, newKey *models.APIKey, newKeyHash string, grace time.Duration) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	var old models.APIKey
+	query := `
+		SELECT ` + apiKeyColumns + ` FROM api_keys
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		FOR UPDATE`
+	if err := scanAPIKey(tx.QueryRow(context.Background(), query, keyID, userID), &old); err != nil {
+		return err
+	}
+
+	newKey.UserID = old.UserID
+	newKey.Name = old.Name
+	newKey.Scopes = old.Scopes
+	newKey.ExpiresAt = nil
+	if old.ExpiresAt != nil {
+		expiresAt := time.Now().Add(old.ExpiresAt.Sub(old.CreatedAt))
+		newKey.ExpiresAt = &expiresAt
+	}
+	insertQuery := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_by_user_id)
+		VALUES ($1, $2, $3, $4, COALESCE($5::text[], '{}'), $6, $7)
+		RETURNING id, created_at`
+	err = tx.QueryRow(context.Background(), insertQuery,
+		newKey.UserID, newKey.Name, newKey.Prefix, newKeyHash, newKey.Scopes, newKey.ExpiresAt, newKey.CreatedByUserID,
+	).Scan(&newKey.ID, &newKey.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if grace > 0 {
+		graceUntil := time.Now().Add(grace)
+		_, err = tx.Exec(context.Background(), `
+			UPDATE api_keys SET rotated_to_key_id = $1, expires_at = LEAST(COALESCE(expires_at, $2), $2)
+			WHERE id = $3`, newKey.ID, graceUntil, keyID)
+	} else {
+		_, err = tx.Exec(context.Background(), `
+			UPDATE api_keys SET rotated_to_key_id = $1, revoked_at = NOW()
+			WHERE id = $2`, newKey.ID, keyID)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// AuthenticateAPIKey resolves a presented key to its service account.
+func (db *DB) AuthenticateAPIKey(key string) (*models.APIKeyIdentity, error) {
+	var identity models.APIKeyIdentity
+	query := `
+		SELECT k.id, k.user_id, k.scopes
+		FROM api_keys k
+		JOIN users u ON k.user_id = u.id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW())
+			AND u.status = 'active' AND u.account_type = 'service'`
+	err := db.pool.QueryRow(context.Background(), query, HashAPIKey(key)).Scan(&identity.KeyID, &identity.UserID, &identity.Scopes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// TouchAPIKey records that a key was used. Writes are limited to one a minute per key.
+func (db *DB) TouchAPIKey(keyID int, ipAddress string) error {
+	query := `
+		UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+	_, err := db.pool.Exec(context.Background(), query, ipAddress, keyID)
+	return err
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// InsertAuditLogEntry appends an entry to the admin audit log. The table rejects
+// updates and deletes, so entries cannot be altered once written.
+func (db *DB) InsertAuditLogEntry(e *models.AuditLogEntry) error {
+	query := `
+		INSERT INTO admin_audit_log (actor_user_id, action, target_type, target_id, before_value, after_value,
+			method, route, path, query, status_code, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	_, err := db.pool.Exec(context.Background(), query,
+		e.ActorUserID, e.Action, e.TargetType, e.TargetID, nullableJSON(e.Before), nullableJSON(e.After),
+		e.Method, e.Route, e.Path, e.Query, e.StatusCode, e.IPAddress, e.UserAgent)
+	return err
+}
+
+func nullableJSON(raw []byte) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+// GetAuditLog returns audit log entries matching the filter, newest first, and
+// the total number of matching entries. A filter Limit of 0 returns every match.
+func (db *DB) GetAuditLog(filter *models.AuditLogFilter) ([]models.AuditLogEntry, int, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.ActorUserID != nil {
+		conditions = append(conditions, fmt.Sprintf("a.actor_user_id = $%d", argCount))
+		args = append(args, *filter.ActorUserID)
+		argCount++
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, fmt.Sprintf("a.action = $%d", argCount))
+		args = append(args, filter.Action)
+		argCount++
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, fmt.Sprintf("a.target_type = $%d", argCount))
+		args = append(args, filter.TargetType)
+		argCount++
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, fmt.Sprintf("a.target_id = $%d", argCount))
+		args = append(args, filter.TargetID)
+		argCount++
+	}
+	if filter.From != nil {
+		conditions = append(conditions, fmt.Sprintf("a.created_at >= $%d", argCount))
+		args = append(args, *filter.From)
+		argCount++
+	}
+	if filter.To != nil {
+		conditions = append(conditions, fmt.Sprintf("a.created_at < $%d", argCount))
+		args = append(args, *filter.To)
+		argCount++
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM admin_audit_log a %s`, where)
+	if err := db.pool.QueryRow(context.Background(), countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page := ""
+	if filter.Limit > 0 {
+		page = fmt.Sprintf("LIMIT $%d OFFSET $%d", argCount, argCount+1)
+		args = append(args, filter.Limit, filter.Offset)
+	}
+	query := fmt.Sprintf(`
+		SELECT a.id, a.actor_user_id, u.full_name, a.action, a.target_type, a.target_id, a.before_value, a.after_value,
+			a.method, a.route, a.path, a.query, a.status_code, a.ip_address, a.user_agent, a.created_at
+		FROM admin_audit_log a
+		LEFT JOIN users u ON a.actor_user_id = u.id
+		%s
+		ORDER BY a.created_at DESC, a.id DESC
+		%s`, where, page)
+
+	rows, err := db.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditLogEntry
+	for rows.Next() {
+		var e models.AuditLogEntry
+		if err := rows.Scan(&e.ID, &e.ActorUserID, &e.ActorName, &e.Action, &e.TargetType, &e.TargetID, &e.Before, &e.After,
+			&e.Method, &e.Route, &e.Path, &e.Query, &e.StatusCode, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// GetUserSnapshot captures a user's profile, status and role assignments for the audit log.
+func (db *DB) GetUserSnapshot(userID int) (*models.UserSnapshot, error) {
+	var s models.UserSnapshot
+	query := `
+		SELECT full_name, username, email, designation, status, status_reason, must_change_password
+		FROM users WHERE id = $1`
+	err := db.pool.QueryRow(context.Background(), query, userID).Scan(
+		&s.FullName, &s.Username, &s.Email, &s.Designation, &s.Status, &s.StatusReason, &s.MustChangePassword)
+	if err != nil {
+		return nil, err
+	}
+	s.Roles, err = db.GetUserRoleAssignments(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetRoleSnapshot captures a role's name, description and permission actions for the audit log.
+func (db *DB) GetRoleSnapshot(roleID int) (*models.RoleSnapshot, error) {
+	var s models.RoleSnapshot
+	err := db.pool.QueryRow(context.Background(), `SELECT name, description, require_two_factor FROM roles WHERE id = $1`, roleID).Scan(&s.Name, &s.Description, &s.RequireTwoFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT p.action FROM permissions p
+		JOIN role_permissions rp ON p.id = rp.permission_id
+		WHERE rp.role_id = $1
+		ORDER BY p.action`
+	rows, err := db.pool.Query(context.Background(), query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	s.Permissions = []string{}
+	for rows.Next() {
+		var action string
+		if err := rows.Scan(&action); err != nil {
+			return nil, err
+		}
+		s.Permissions = append(s.Permissions, action)
+	}
+	return &s, rows.Err()
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// CreateSaleCreditNote cancels a sale by issuing a credit note for its full
+// amount. The sale is kept, marked cancelled, and its weight share may be
+// billed again. Payments allocated to it return to the payer's advance. It
+// returns pgx.ErrNoRows for an unknown sale.
+func (db *DB) CreateSaleCreditNote(saleID int, req *models.CreateSaleCreditNoteRequest, userID int) (*models.SaleCreditNote, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var cancelledAt *time.Time
+	err = tx.QueryRow(context.Background(), `SELECT cancelled_at FROM material_sales WHERE id = $1 FOR UPDATE`, saleID).Scan(&cancelledAt)
+	if err != nil {
+		return nil, err
+	}
+	if cancelledAt != nil {
+		return nil, ErrSaleCancelled
+	}
+
+	var noteID int
+	query := `
+		INSERT INTO sale_credit_notes (credit_note_number, sale_id, reason, weight_tons, amount, gst_amount, total_amount, created_by_user_id)
+		SELECT 'CN-' || LPAD(nextval('credit_note_number_seq')::text, 6, '0'), id, $2, original_weight_tons, amount, gst_amount, total_amount, $3
+		FROM material_sales WHERE id = $1
+		RETURNING id`
+	if err := tx.QueryRow(context.Background(), query, saleID, req.Reason, userID).Scan(&noteID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(context.Background(), `UPDATE material_sales SET cancelled_at = NOW() WHERE id = $1`, saleID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(context.Background(), `DELETE FROM sale_payment_allocations WHERE sale_id = $1`, saleID); err != nil {
+		return nil, err
+	}
+
+	note, err := getSaleCreditNote(tx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	return note, tx.Commit(context.Background())
+}
+
+const saleCreditNoteSelect = `
+	SELECT cn.id, cn.credit_note_number, cn.sale_id, ms.inward_entry_id, p.name, cn.reason, cn.weight_tons,
+		cn.amount, cn.gst_amount, cn.total_amount, cn.created_by_user_id, u.full_name, cn.created_at
+	FROM sale_credit_notes cn
+	JOIN material_sales ms ON cn.sale_id = ms.id
+	LEFT JOIN partners p ON ms.party_id = p.id
+	LEFT JOIN users u ON cn.created_by_user_id = u.id`
+
+func scanSaleCreditNote(row pgx.Row) (*models.SaleCreditNote, error) {
+	var n models.SaleCreditNote
+	err := row.Scan(&n.ID, &n.CreditNoteNumber, &n.SaleID, &n.InwardEntryID, &n.PartyName, &n.Reason, &n.WeightTons,
+		&n.Amount, &n.GSTAmount, &n.TotalAmount, &n.CreatedByUserID, &n.CreatedByName, &n.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func getSaleCreditNote(q querier, id int) (*models.SaleCreditNote, error) {
+	return scanSaleCreditNote(q.QueryRow(context.Background(), saleCreditNoteSelect+` WHERE cn.id = $1`, id))
+}
+
+func (db *DB) GetSaleCreditNote(id int) (*models.SaleCreditNote, error) {
+	return getSaleCreditNote(db.pool, id)
+}
+
+// GetSaleCreditNotes lists credit notes issued within the optional date range, newest first.
+func (db *DB) GetSaleCreditNotes(from, to *time.Time) ([]models.SaleCreditNote, error) {
+	query := saleCreditNoteSelect + `
+	WHERE ($1::timestamptz IS NULL OR cn.created_at >= $1) AND ($2::timestamptz IS NULL OR cn.created_at < $2)
+	ORDER BY cn.created_at DESC, cn.id DESC`
+	rows, err := db.pool.Query(context.Background(), query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []models.SaleCreditNote
+	for rows.Next() {
+		n, err := scanSaleCreditNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *n)
+	}
+	return notes, rows.Err()
+}
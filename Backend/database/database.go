@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -11,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/solaris-hms/mrf-backend/billing"
 	"github.com/solaris-hms/mrf-backend/models"
 
 	"github.com/jackc/pgx/v5"
@@ -48,8 +50,8 @@ func (db *DB) RegisterUserInDB(req *models.RegisterRequest, passwordHash string)
 
 func (db *DB) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, full_name, email, password_hash, is_approved, created_at FROM users WHERE email = $1`
-	err := db.pool.QueryRow(context.Background(), query, email).Scan(&user.ID, &user.FullName, &user.Email, &user.PasswordHash, &user.IsApproved, &user.CreatedAt)
+	query := `SELECT id, full_name, email, password_hash, is_approved, status, account_type, must_change_password, failed_login_count, locked_until, created_at FROM users WHERE email = $1`
+	err := db.pool.QueryRow(context.Background(), query, email).Scan(&user.ID, &user.FullName, &user.Email, &user.PasswordHash, &user.IsApproved, &user.Status, &user.AccountType, &user.MustChangePassword, &user.FailedLoginCount, &user.LockedUntil, &user.CreatedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -61,8 +63,18 @@ func (db *DB) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+func (db *DB) GetUserByID(userID int) (*models.User, error) {
+	var user models.User
+	query := `SELECT id, full_name, email, password_hash, is_approved, status, account_type, must_change_password, failed_login_count, locked_until, created_at FROM users WHERE id = $1`
+	err := db.pool.QueryRow(context.Background(), query, userID).Scan(&user.ID, &user.FullName, &user.Email, &user.PasswordHash, &user.IsApproved, &user.Status, &user.AccountType, &user.MustChangePassword, &user.FailedLoginCount, &user.LockedUntil, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (db *DB) GetPendingUsers() ([]models.User, error) {
-	query := `SELECT id, full_name, username, email, designation, created_at FROM users WHERE is_approved = false ORDER BY created_at ASC`
+	query := `SELECT id, full_name, username, email, designation, created_at FROM users WHERE status = 'pending' ORDER BY created_at ASC`
 	rows, err := db.pool.Query(context.Background(), query)
 	if err != nil {
 		return nil, err
@@ -82,13 +94,17 @@ func (db *DB) GetPendingUsers() ([]models.User, error) {
 func (db *DB) GetAllUsers() ([]models.User, error) {
 	query := `
 		SELECT 
-			u.id, u.full_name, u.username, u.email, u.designation, u.is_approved, u.created_at, r.name 
+			u.id, u.full_name, u.username, u.email, u.designation, u.is_approved, u.status, u.status_reason, u.account_type, u.created_at,
+			COALESCE(array_agg(r.id ORDER BY r.name) FILTER (WHERE r.id IS NOT NULL), '{}'),
+			COALESCE(array_agg(r.name ORDER BY r.name) FILTER (WHERE r.id IS NOT NULL), '{}')
 		FROM 
 			users u
 		LEFT JOIN 
-			user_roles ur ON u.id = ur.user_id
+			user_roles ur ON u.id = ur.user_id AND ur.scope_type = ''
 		LEFT JOIN 
 			roles r ON ur.role_id = r.id
+		GROUP BY 
+			u.id
 		ORDER BY 
 			u.full_name ASC`
 	rows, err := db.pool.Query(context.Background(), query)
@@ -99,9 +115,13 @@ func (db *DB) GetAllUsers() ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.FullName, &user.Username, &user.Email, &user.Designation, &user.IsApproved, &user.CreatedAt, &user.RoleName); err != nil {
+		if err := rows.Scan(&user.ID, &user.FullName, &user.Username, &user.Email, &user.Designation, &user.IsApproved, &user.Status, &user.StatusReason, &user.AccountType, &user.CreatedAt, &user.RoleIDs, &user.Roles); err != nil {
 			return nil, err
 		}
+		if len(user.Roles) > 0 {
+			roleName := strings.Join(user.Roles, ", ")
+			user.RoleName = &roleName
+		}
 		users = append(users, user)
 	}
 	return users, nil
@@ -122,49 +142,58 @@ func (db *DB) UpdateUser(userID int, req *models.UpdateUserRequest) error {
 		return err
 	}
 
-	deleteRoleQuery := `DELETE FROM user_roles WHERE user_id = $1`
+	checkAdmins, err := guardLastAdmin(tx)
+	if err != nil {
+		return err
+	}
+	deleteRoleQuery := `DELETE FROM user_roles WHERE user_id = $1 AND scope_type = ''`
 	_, err = tx.Exec(context.Background(), deleteRoleQuery, userID)
 	if err != nil {
 		return err
 	}
 
-	insertRoleQuery := `INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)`
-	_, err = tx.Exec(context.Background(), insertRoleQuery, userID, req.RoleID)
-	if err != nil {
+	if err := insertUserRoles(tx, userID, req.AllRoleIDs()); err != nil {
 		return err
 	}
 
+	if err := checkAdmins(); err != nil {
+		return err
+	}
 	return tx.Commit(context.Background())
 }
 
-func (db *DB) ApproveUserInDB(userID int, roleID int) error {
+// ApproveUserInDB activates a pending user and grants them the given global roles.
+func (db *DB) ApproveUserInDB(userID int, roleIDs []int, actorID int) error {
 	tx, err := db.pool.Begin(context.Background())
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(context.Background())
-	_, err = tx.Exec(context.Background(), "UPDATE users SET is_approved = true WHERE id = $1", userID)
-	if err != nil {
+	if err := transitionUserStatus(tx, userID, models.UserStatusActive, "Registration approved", actorID); err != nil {
 		return err
 	}
-	_, err = tx.Exec(context.Background(), `
-		INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)`,
-		userID, roleID)
-	if err != nil {
+	if err := insertUserRoles(tx, userID, roleIDs); err != nil {
 		return err
 	}
 	return tx.Commit(context.Background())
 }
 
-func (db *DB) DeleteUser(userID int) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := db.pool.Exec(context.Background(), query, userID)
+func insertUserRoles(tx pgx.Tx, userID int, roleIDs []int) error {
+	query := `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, unnest($2::int[])
+		ON CONFLICT DO NOTHING`
+	_, err := tx.Exec(context.Background(), query, userID, roleIDs)
 	return err
 }
 
 // --- Role and Permission Functions ---
 func (db *DB) GetAllRoles() ([]models.Role, error) {
-	query := `SELECT id, name FROM roles ORDER BY name ASC`
+	query := `
+		SELECT r.id, r.name, r.description, r.template, r.require_two_factor, r.created_at,
+			(SELECT COUNT(DISTINCT ur.user_id) FROM user_roles ur WHERE ur.role_id = r.id)
+		FROM roles r
+		ORDER BY r.name ASC`
 	rows, err := db.pool.Query(context.Background(), query)
 	if err != nil {
 		return nil, err
@@ -173,7 +202,7 @@ func (db *DB) GetAllRoles() ([]models.Role, error) {
 	var roles []models.Role
 	for rows.Next() {
 		var role models.Role
-		if err := rows.Scan(&role.ID, &role.Name); err != nil {
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.Template, &role.RequireTwoFactor, &role.CreatedAt, &role.UserCount); err != nil {
 			return nil, err
 		}
 		roles = append(roles, role)
@@ -223,6 +252,10 @@ func (db *DB) UpdatePermissionsForRole(roleID int, permissionIDs []int) error {
 		return err
 	}
 	defer tx.Rollback(context.Background())
+	checkAdmins, err := guardLastAdmin(tx)
+	if err != nil {
+		return err
+	}
 	deleteQuery := `DELETE FROM role_permissions WHERE role_id = $1`
 	_, err = tx.Exec(context.Background(), deleteQuery, roleID)
 	if err != nil {
@@ -237,36 +270,43 @@ func (db *DB) UpdatePermissionsForRole(roleID int, permissionIDs []int) error {
 			}
 		}
 	}
+	if err := checkAdmins(); err != nil {
+		return err
+	}
 	return tx.Commit(context.Background())
 }
 
-func (db *DB) GetUserPermissions(userID int) ([]string, error) {
+// GetUserPermissions returns every permission the user holds through their roles,
+// together with the site or department scope of the role assignment.
+func (db *DB) GetUserPermissions(userID int) (models.Grants, error) {
 	query := `
-		SELECT DISTINCT p.action FROM permissions p
+		SELECT DISTINCT p.action, ur.scope_type, ur.scope_value FROM permissions p
 		JOIN role_permissions rp ON p.id = rp.permission_id
 		JOIN user_roles ur ON rp.role_id = ur.role_id
-		WHERE ur.user_id = $1`
+		WHERE ur.user_id = $1
+		ORDER BY p.action, ur.scope_type, ur.scope_value`
 	rows, err := db.pool.Query(context.Background(), query, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var permissions []string
+	var grants models.Grants
 	for rows.Next() {
-		var permission string
-		if err := rows.Scan(&permission); err != nil {
+		var grant models.PermissionGrant
+		if err := rows.Scan(&grant.Action, &grant.ScopeType, &grant.ScopeValue); err != nil {
 			return nil, err
 		}
-		permissions = append(permissions, permission)
+		grants = append(grants, grant)
 	}
-	return permissions, nil
+	return grants, nil
 }
 
 func (db *DB) GetUserRoles(userID int) ([]string, error) {
 	query := `
-		SELECT r.name FROM roles r
+		SELECT DISTINCT r.name FROM roles r
 		JOIN user_roles ur ON r.id = ur.role_id
-		WHERE ur.user_id = $1`
+		WHERE ur.user_id = $1
+		ORDER BY r.name`
 	rows, err := db.pool.Query(context.Background(), query, userID)
 	if err != nil {
 		return nil, err
@@ -284,66 +324,188 @@ func (db *DB) GetUserRoles(userID int) ([]string, error) {
 }
 
 // --- Inward Entry Functions ---
+// CreateInwardEntry records a vehicle at gate-in together with its first weighing.
 func (db *DB) CreateInwardEntry(req *models.CreateInwardEntryRequest, userID int) (*models.InwardEntry, error) {
 	grossWeightKg := req.GrossWeightTons * 1000
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	vehicleID, err := admitVehicle(tx, req.VehicleNumber, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.VendorID != nil && *req.VendorID != "" {
+		if err := checkVendorApproved(tx, *req.VendorID); err != nil {
+			return nil, err
+		}
+	} else {
+		req.VendorID = nil
+	}
+
 	query := `
-        INSERT INTO inward_entries (vehicle_number, source_id, destination_id, party_id, material, entry_type, gross_weight, created_by_user_id)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        INSERT INTO inward_entries (vehicle_number, vehicle_id, source_id, destination_id, party_id, vendor_id, material, entry_type, gross_weight, gross_weight_manual, status, created_by_user_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 'gate_in', $11)
         RETURNING id`
 	var entryID int
-	err := db.pool.QueryRow(context.Background(), query,
-		req.VehicleNumber, req.SourceID, req.DestinationID, req.PartyID, req.Material, req.EntryType, grossWeightKg, userID,
+	err = tx.QueryRow(context.Background(), query,
+		strings.TrimSpace(req.VehicleNumber), vehicleID, req.SourceID, req.DestinationID, req.PartyID, req.VendorID, req.Material, req.EntryType, grossWeightKg, req.ManualWeight, userID,
 	).Scan(&entryID)
 	if err != nil {
 		return nil, err
 	}
-	return &models.InwardEntry{ID: entryID}, nil
+	if err := recordInwardStatusChange(tx, entryID, nil, models.InwardStatusGateIn, "", userID); err != nil {
+		return nil, err
+	}
+	if err := transitionInwardEntry(tx, entryID, models.InwardStatusFirstWeighed, "", userID); err != nil {
+		return nil, err
+	}
+	return &models.InwardEntry{ID: entryID, Status: models.InwardStatusFirstWeighed}, tx.Commit(context.Background())
 }
 
-func (db *DB) GetPendingInwardEntries() ([]models.InwardEntry, error) {
-	query := `
-        SELECT ie.id, ie.vehicle_number, COALESCE(p.name, d.name) AS location, ie.material, ie.entry_type, ie.gross_weight, ie.status, ie.created_at
+// inwardEntryConditions turns a list filter into SQL conditions and their
+// arguments. timeColumn and weightColumn are what the date and weight ranges
+// and the cursor apply to; the list must be ordered by timeColumn, ie.id.
+func inwardEntryConditions(filter *models.InwardEntryFilter, statuses []string, timeColumn, weightColumn string) ([]string, []interface{}) {
+	conditions := []string{"ie.status = ANY($1)"}
+	args := []interface{}{statuses}
+	add := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.From != nil {
+		add(timeColumn+" >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add(timeColumn+" < $%d", *filter.To)
+	}
+	if filter.VehicleNumber != "" {
+		add("normalize_vehicle_number(ie.vehicle_number) = $%d", models.NormalizeVehicleNumber(filter.VehicleNumber))
+	}
+	if search := models.NormalizeVehicleNumber(filter.Search); search != "" {
+		add("normalize_vehicle_number(ie.vehicle_number) LIKE '%%' || $%d || '%%'", search)
+	}
+	if filter.PartyID != nil {
+		add("ie.party_id = $%d", *filter.PartyID)
+	}
+	if filter.SourceID != nil {
+		add("ie.source_id = $%d", *filter.SourceID)
+	}
+	if filter.Material != "" {
+		add("LOWER(ie.material) = LOWER($%d)", filter.Material)
+	}
+	if filter.EntryType != "" {
+		add("ie.entry_type = $%d", filter.EntryType)
+	}
+	if filter.MinWeightTons != nil {
+		add(weightColumn+" >= $%d", *filter.MinWeightTons*1000)
+	}
+	if filter.MaxWeightTons != nil {
+		add(weightColumn+" <= $%d", *filter.MaxWeightTons*1000)
+	}
+	return conditions, args
+}
+
+// pageInwardEntries appends the cursor and limit to a filtered list and
+// returns the clause to put after ORDER BY.
+func pageInwardEntries(filter *models.InwardEntryFilter, conditions []string, args []interface{}, timeColumn string) ([]string, []interface{}, string) {
+	if filter.After != nil {
+		args = append(args, filter.After.At, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, ie.id) < ($%d, $%d)", timeColumn, len(args)-1, len(args)))
+	}
+	limit := ""
+	if filter.Limit > 0 {
+		// One extra row tells the caller whether there is a next page.
+		args = append(args, filter.Limit+1)
+		limit = fmt.Sprintf("LIMIT $%d", len(args))
+	}
+	return conditions, args, limit
+}
+
+// GetPendingInwardEntries returns entries waiting for their second weighing
+// that match the filter, newest first, and the total number of matches.
+func (db *DB) GetPendingInwardEntries(filter *models.InwardEntryFilter) ([]models.InwardEntry, int, error) {
+	conditions, args := inwardEntryConditions(filter, models.InwardPendingStatuses, "ie.created_at", "ie.gross_weight")
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM inward_entries ie WHERE ` + strings.Join(conditions, " AND ")
+	if err := db.pool.QueryRow(context.Background(), countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	conditions, args, limit := pageInwardEntries(filter, conditions, args, "ie.created_at")
+	query := fmt.Sprintf(`
+        SELECT ie.id, ie.vehicle_number, COALESCE(p.name, d.name) AS location, ie.vendor_id, v.vendor_name, ie.material, ie.entry_type, ie.gross_weight, ie.gross_weight_manual, ie.status, ie.created_at,
+            ie.source_id, ie.party_id, ie.vehicle_id
         FROM inward_entries ie
         LEFT JOIN partners p ON ie.source_id = p.id
         LEFT JOIN partners d ON ie.destination_id = d.id
-        WHERE ie.status = 'Pending' ORDER BY ie.created_at DESC`
-	rows, err := db.pool.Query(context.Background(), query)
+        LEFT JOIN vendors v ON ie.vendor_id = v.id
+        WHERE %s
+        ORDER BY ie.created_at DESC, ie.id DESC
+        %s`, strings.Join(conditions, " AND "), limit)
+	rows, err := db.pool.Query(context.Background(), query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 	var entries []models.InwardEntry
 	for rows.Next() {
 		var entry models.InwardEntry
 		var grossWeightKg float64
-		if err := rows.Scan(&entry.ID, &entry.VehicleNumber, &entry.SourceName, &entry.Material, &entry.EntryType, &grossWeightKg, &entry.Status, &entry.CreatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&entry.ID, &entry.VehicleNumber, &entry.SourceName, &entry.VendorID, &entry.VendorName, &entry.Material, &entry.EntryType, &grossWeightKg, &entry.GrossWeightManual, &entry.Status, &entry.CreatedAt,
+			&entry.SourceID, &entry.PartyID, &entry.VehicleID); err != nil {
+			return nil, 0, err
 		}
 		entry.GrossWeightTons = grossWeightKg / 1000
 		entries = append(entries, entry)
 	}
-	return entries, nil
+	return entries, total, rows.Err()
 }
 
-func (db *DB) GetCompletedInwardEntries() ([]models.InwardEntry, error) {
-	query := `
+// completedSortTime orders the completed list. Entries weighed before
+// completed_at was always recorded fall back to their arrival, so the keyset
+// cursor never has to compare a NULL.
+const completedSortTime = "COALESCE(ie.completed_at, ie.created_at)"
+
+// GetCompletedInwardEntries returns weighed entries that match the filter,
+// most recently weighed first, and the total number of matches.
+func (db *DB) GetCompletedInwardEntries(filter *models.InwardEntryFilter) ([]models.InwardEntry, int, error) {
+	conditions, args := inwardEntryConditions(filter, models.InwardWeighedStatuses, completedSortTime, "ie.net_weight")
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM inward_entries ie WHERE ` + strings.Join(conditions, " AND ")
+	if err := db.pool.QueryRow(context.Background(), countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	conditions, args, limit := pageInwardEntries(filter, conditions, args, completedSortTime)
+	query := fmt.Sprintf(`
         SELECT 
             ie.id, ie.vehicle_number, 
             COALESCE(s.name, d.name) AS location, 
             p.name AS party_name,
             ie.material, ie.entry_type, 
-            ie.gross_weight, ie.tare_weight, ie.net_weight, 
+            ie.gross_weight, ie.tare_weight, ie.net_weight,
+            ie.gross_weight_manual, ie.tare_weight_manual,
             ie.status, ie.created_at, ie.completed_at,
-            ie.party_id
+            ie.party_id, ie.vendor_id, v.vendor_name, ie.source_id,
+            ie.vehicle_id, ie.tare_weight_stored
         FROM inward_entries ie
         LEFT JOIN partners s ON ie.source_id = s.id
         LEFT JOIN partners d ON ie.destination_id = d.id
         LEFT JOIN partners p ON ie.party_id = p.id
-        WHERE ie.status = 'Completed' 
-        ORDER BY ie.completed_at DESC`
-	rows, err := db.pool.Query(context.Background(), query)
+        LEFT JOIN vendors v ON ie.vendor_id = v.id
+        WHERE %s
+        ORDER BY %s DESC, ie.id DESC
+        %s`, strings.Join(conditions, " AND "), completedSortTime, limit)
+	rows, err := db.pool.Query(context.Background(), query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 	var entries []models.InwardEntry
@@ -354,10 +516,11 @@ func (db *DB) GetCompletedInwardEntries() ([]models.InwardEntry, error) {
 		if err := rows.Scan(
 			&entry.ID, &entry.VehicleNumber, &entry.SourceName, &entry.PartyName,
 			&entry.Material, &entry.EntryType, &grossKg,
-			&tareKgPtr, &netKgPtr, &entry.Status, &entry.CreatedAt,
-			&entry.CompletedAt, &entry.PartyID,
+			&tareKgPtr, &netKgPtr, &entry.GrossWeightManual, &entry.TareWeightManual, &entry.Status, &entry.CreatedAt,
+			&entry.CompletedAt, &entry.PartyID, &entry.VendorID, &entry.VendorName, &entry.SourceID,
+			&entry.VehicleID, &entry.TareWeightStored,
 		); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		entry.GrossWeightTons = grossKg / 1000
 		if tareKgPtr != nil {
@@ -370,54 +533,82 @@ func (db *DB) GetCompletedInwardEntries() ([]models.InwardEntry, error) {
 		}
 		entries = append(entries, entry)
 	}
-	return entries, nil
+	return entries, total, rows.Err()
 }
 
-func (db *DB) CompleteInwardEntry(entryID int, req *models.CompleteInwardEntryRequest) (*models.InwardEntry, error) {
+// CompleteInwardEntry records the second weighing and posts the net weight to
+// inventory by the entry type's posting rule. An entry still at its first
+// weighing is moved through unloading or loading on the way, so the history
+// shows every step. The entry type is never changed: an empty vehicle that
+// leaves loaded stays an "Empty Vehicle" entry with its material set.
+func (db *DB) CompleteInwardEntry(entryID int, req *models.CompleteInwardEntryRequest, userID int) (*models.InwardEntry, error) {
 	var grossWeightKg float64
-	var entryType string
+	var entryType, status string
+	var material *string
+	var vehicleID *int
 	tx, err := db.pool.Begin(context.Background())
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback(context.Background())
-	err = tx.QueryRow(context.Background(), `SELECT gross_weight, entry_type FROM inward_entries WHERE id = $1`, entryID).Scan(&grossWeightKg, &entryType)
+	err = tx.QueryRow(context.Background(), `SELECT gross_weight, entry_type, status, material, vehicle_id FROM inward_entries WHERE id = $1 FOR UPDATE`, entryID).Scan(&grossWeightKg, &entryType, &status, &material, &vehicleID)
 	if err != nil {
 		return nil, err
 	}
 
 	tareWeightKg := req.TareWeightTons * 1000
+	if req.UseStoredTare {
+		if tareWeightKg, err = storedTareKg(tx, vehicleID, entryType, grossWeightKg); err != nil {
+			return nil, err
+		}
+		req.ManualWeight = false
+	}
+
+	if status == models.InwardStatusFirstWeighed {
+		next := models.InwardStatusUnloading
+		if models.IsOutgoingEntryType(entryType) {
+			next = models.InwardStatusLoading
+		}
+		if err := transitionInwardEntry(tx, entryID, next, "", userID); err != nil {
+			return nil, err
+		}
+	}
+	if err := transitionInwardEntry(tx, entryID, models.InwardStatusSecondWeighed, "", userID); err != nil {
+		return nil, err
+	}
+
 	netWeightKg := math.Abs(grossWeightKg - tareWeightKg)
 
-	if req.Material != nil && *req.Material != "" && entryType == "Empty Vehicle" {
+	if req.Material != nil && *req.Material != "" && models.IsOutgoingEntryType(entryType) {
 		query := `
             UPDATE inward_entries
-            SET tare_weight = $1, net_weight = $2, status = 'Completed', completed_at = CURRENT_TIMESTAMP, material = $3, entry_type = 'Item Export'
-            WHERE id = $4`
-		_, err = tx.Exec(context.Background(), query, tareWeightKg, netWeightKg, *req.Material, entryID)
-		if err != nil {
-			return nil, err
-		}
-		err = updateInventoryStock(tx, *req.Material, -netWeightKg)
+            SET tare_weight = $1, net_weight = $2, tare_weight_manual = $3, tare_weight_stored = $4, material = $5
+            WHERE id = $6`
+		_, err = tx.Exec(context.Background(), query, tareWeightKg, netWeightKg, req.ManualWeight, req.UseStoredTare, *req.Material, entryID)
 		if err != nil {
 			return nil, err
 		}
+		material = req.Material
 	} else {
 		query := `
             UPDATE inward_entries
-            SET tare_weight = $1, net_weight = $2, status = 'Completed', completed_at = CURRENT_TIMESTAMP
-            WHERE id = $3`
-		_, err = tx.Exec(context.Background(), query, tareWeightKg, netWeightKg, entryID)
+            SET tare_weight = $1, net_weight = $2, tare_weight_manual = $3, tare_weight_stored = $4
+            WHERE id = $5`
+		_, err = tx.Exec(context.Background(), query, tareWeightKg, netWeightKg, req.ManualWeight, req.UseStoredTare, entryID)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return &models.InwardEntry{ID: entryID}, tx.Commit(context.Background())
+
+	if err := applyInventoryPostingRule(tx, entryID, entryType, material, netWeightKg, userID); err != nil {
+		return nil, err
+	}
+	return &models.InwardEntry{ID: entryID, Status: models.InwardStatusSecondWeighed}, tx.Commit(context.Background())
 }
 
 // --- Partner Functions ---
 func (db *DB) GetAllPartners() ([]models.Partner, error) {
-	query := `SELECT id, name, type FROM partners ORDER BY name ASC`
+	query := `SELECT id, name, type, gstin, address, city, pincode, state_code FROM partners ORDER BY name ASC`
 	rows, err := db.pool.Query(context.Background(), query)
 	if err != nil {
 		return nil, err
@@ -426,7 +617,7 @@ func (db *DB) GetAllPartners() ([]models.Partner, error) {
 	var partners []models.Partner
 	for rows.Next() {
 		var p models.Partner
-		if err := rows.Scan(&p.ID, &p.Name, &p.Type); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Type, &p.GSTIN, &p.Address, &p.City, &p.Pincode, &p.StateCode); err != nil {
 			return nil, err
 		}
 		partners = append(partners, p)
@@ -444,6 +635,38 @@ func (db *DB) CreatePartner(name string, partnerType string) (*models.Partner, e
 	return &p, nil
 }
 
+// UpdatePartnerTaxDetails sets the GSTIN and address a partner is invoiced
+// at. A GSTIN decides the state code; without one the given state code is
+// kept. Empty values clear the field. It returns pgx.ErrNoRows for an
+// unknown partner.
+func (db *DB) UpdatePartnerTaxDetails(partnerID int, req *models.UpdatePartnerTaxDetailsRequest) (*models.Partner, error) {
+	gstin := billing.NormalizeGSTIN(req.GSTIN)
+	stateCode := req.StateCode
+	if gstin != "" {
+		if !billing.ValidGSTIN(gstin) {
+			return nil, ErrInvalidGSTIN
+		}
+		stateCode = gstin[:2]
+	}
+	if _, ok := billing.States[stateCode]; stateCode != "" && !ok {
+		return nil, ErrInvalidStateCode
+	}
+
+	query := `
+		UPDATE partners SET gstin = NULLIF($2, ''), address = NULLIF($3, ''), city = NULLIF($4, ''),
+			pincode = NULLIF($5, ''), state_code = NULLIF($6, '')
+		WHERE id = $1
+		RETURNING id, name, type, gstin, address, city, pincode, state_code`
+	var p models.Partner
+	err := db.pool.QueryRow(context.Background(), query, partnerID, gstin, strings.TrimSpace(req.Address),
+		strings.TrimSpace(req.City), req.Pincode, stateCode).
+		Scan(&p.ID, &p.Name, &p.Type, &p.GSTIN, &p.Address, &p.City, &p.Pincode, &p.StateCode)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
 // --- Sorting and Inventory Functions ---
 func updateInventoryStock(tx pgx.Tx, materialName string, quantityChangeKg float64) error {
 	query := `
@@ -506,7 +729,12 @@ func (db *DB) CreateSortingLog(req *models.CreateSortingLogRequest, userID int)
 		if err != nil {
 			return err
 		}
-		err = updateInventoryStock(tx, entry.Material, quantityKg)
+		// Sorting turns unsorted waste into sorted material; the total stays the same.
+		err = postInventoryMovement(tx, models.UnsortedMaterial, -quantityKg, models.MovementSourceSortingLog, logID, userID)
+		if err != nil {
+			return err
+		}
+		err = postInventoryMovement(tx, entry.Material, quantityKg, models.MovementSourceSortingLog, logID, userID)
 		if err != nil {
 			return err
 		}
@@ -558,10 +786,13 @@ func (db *DB) GetOpeningBalance(date string) (float64, error) {
 
 func (db *DB) GetTransactionsByDate(date string) ([]models.CashbookTransaction, error) {
 	query := `
-        SELECT id, transaction_date, created_at, description, cash_in, cash_out 
-        FROM cashbook_transactions 
-        WHERE transaction_date = $1 
-        ORDER BY created_at ASC`
+        SELECT ct.id, ct.transaction_date, ct.created_at, ct.description, ct.cash_in, ct.cash_out,
+            cu.full_name, au.full_name, ct.approved_at
+        FROM cashbook_transactions ct
+        LEFT JOIN users cu ON ct.created_by_user_id = cu.id
+        LEFT JOIN users au ON ct.approved_by_user_id = au.id
+        WHERE ct.transaction_date = $1 
+        ORDER BY ct.created_at ASC`
 
 	rows, err := db.pool.Query(context.Background(), query, date)
 	if err != nil {
@@ -572,7 +803,7 @@ func (db *DB) GetTransactionsByDate(date string) ([]models.CashbookTransaction,
 	var transactions []models.CashbookTransaction
 	for rows.Next() {
 		var t models.CashbookTransaction
-		if err := rows.Scan(&t.ID, &t.Date, &t.Time, &t.Description, &t.CashIn, &t.CashOut); err != nil {
+		if err := rows.Scan(&t.ID, &t.Date, &t.Time, &t.Description, &t.CashIn, &t.CashOut, &t.CreatedBy, &t.ApprovedBy, &t.ApprovedAt); err != nil {
 			return nil, err
 		}
 		transactions = append(transactions, t)
@@ -603,32 +834,100 @@ func (db *DB) CreateCashbookTransaction(req *models.CreateCashbookTransactionReq
 	return &models.CashbookTransaction{ID: transactionID}, nil
 }
 
+// ApproveCashbookTransaction records a second person's sign-off on a cashbook entry.
+// Entries cannot be approved by the user who created them, nor approved twice.
+func (db *DB) ApproveCashbookTransaction(transactionID int, userID int) error {
+	var createdBy *int
+	var approvedAt *time.Time
+	err := db.pool.QueryRow(context.Background(),
+		`SELECT created_by_user_id, approved_at FROM cashbook_transactions WHERE id = $1`, transactionID,
+	).Scan(&createdBy, &approvedAt)
+	if err != nil {
+		return err
+	}
+	if approvedAt != nil {
+		return ErrAlreadyApproved
+	}
+	if createdBy != nil && *createdBy == userID {
+		return ErrSelfApproval
+	}
+
+	query := `
+        UPDATE cashbook_transactions SET approved_by_user_id = $1, approved_at = NOW()
+        WHERE id = $2 AND approved_at IS NULL`
+	tag, err := db.pool.Exec(context.Background(), query, userID, transactionID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyApproved
+	}
+	return nil
+}
+
 // --- Material Sale Functions ---
 
+// CreateMaterialSale records a sale of all or part of a weighed dispatch. The
+// original, deduction and billing weights are derived from the sale's share
+// of the entry's net weight; see deriveSaleWeights. Amount, GST and total are computed from
+// them under the rounding rules; see deriveSaleAmounts. A buyer weighbridge
+// figure outside the material's tolerance opens a dispute with the sale.
 func (db *DB) CreateMaterialSale(req *models.CreateMaterialSaleRequest, userID int) (*models.MaterialSale, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	tolerance, err := deriveSaleWeights(tx, req)
+	if err != nil {
+		return nil, err
+	}
+	amounts, breakdown, err := deriveSaleAmounts(tx, req)
+	if err != nil {
+		return nil, err
+	}
+	var buyerWeight *float64
+	if req.BuyerWeightTons != nil {
+		rounded := models.RoundTons(*req.BuyerWeightTons)
+		buyerWeight = &rounded
+	}
+
 	query := `
         INSERT INTO material_sales 
             (inward_entry_id, party_id, sale_date, driver_name, driver_mobile, rate, gst_percentage, 
             amount, gst_amount, total_amount, mode_of_payment, remark, transportation_expense, 
             transporter_id, created_by_user_id, original_weight_tons, deduction_type, deduction_value,
-            deduction_amount, deduction_reason, billing_weight_tons)
+            deduction_amount, deduction_reason, billing_weight_tons, buyer_weight_tons, amount_breakdown)
         VALUES 
-            ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+            ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
         RETURNING id`
 
 	var saleID int
-	err := db.pool.QueryRow(context.Background(), query,
+	err = tx.QueryRow(context.Background(), query,
 		req.InwardEntryID, req.PartyID, req.SaleDate, req.DriverName, req.DriverMobile, req.Rate,
 		req.GSTPercentage, req.Amount, req.GSTAmount, req.TotalAmount, req.ModeOfPayment, req.Remark,
 		req.TransportationExpense, req.TransporterID, userID, req.OriginalWeightTons, req.DeductionType,
-		req.DeductionValue, req.DeductionAmount, req.DeductionReason, req.BillingWeightTons,
+		req.DeductionValue, req.DeductionAmount, req.DeductionReason, req.BillingWeightTons, buyerWeight, breakdown,
 	).Scan(&saleID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.MaterialSale{ID: saleID}, nil
+	sale := &models.MaterialSale{ID: saleID, OriginalWeightTons: &req.OriginalWeightTons, DeductionAmount: &req.DeductionAmount, BillingWeightTons: &req.BillingWeightTons, BuyerWeightTons: buyerWeight,
+		Rate: req.Rate, GSTPercentage: req.GSTPercentage, Amount: req.Amount, GSTAmount: req.GSTAmount, TotalAmount: req.TotalAmount, AmountBreakdown: amounts}
+	if buyerWeight != nil {
+		disputeID, err := openSaleDispute(tx, saleID, req.OriginalWeightTons, *buyerWeight, tolerance, "Buyer weight recorded with the sale", userID)
+		switch {
+		case err == nil:
+			status := models.DisputeStatusOpen
+			sale.DisputeID, sale.DisputeStatus = &disputeID, &status
+		case !errors.Is(err, ErrWithinTolerance):
+			return nil, err
+		}
+	}
+	return sale, tx.Commit(context.Background())
 }
 
 func (db *DB) GetMaterialSales() ([]models.MaterialSale, error) {
@@ -640,11 +939,18 @@ func (db *DB) GetMaterialSales() ([]models.MaterialSale, error) {
             ms.remark, ms.amount, ms.gst_amount, ms.total_amount, ms.created_at,
             ms.transportation_expense, ms.inward_entry_id,
             ms.original_weight_tons, ms.deduction_type, ms.deduction_value,
-            ms.deduction_amount, ms.deduction_reason, ms.billing_weight_tons
+            ms.deduction_amount, ms.deduction_reason, ms.billing_weight_tons,
+            ms.buyer_weight_tons, sd.id, sd.status, ms.amount_breakdown,
+            ms.cancelled_at, cn.credit_note_number,
+            COALESCE((SELECT SUM(a.amount) FROM sale_payment_allocations a WHERE a.sale_id = ms.id), 0)
         FROM material_sales ms
         JOIN inward_entries ie ON ms.inward_entry_id = ie.id
         JOIN partners p ON ms.party_id = p.id
         LEFT JOIN partners t ON ms.transporter_id = t.id
+        LEFT JOIN sale_credit_notes cn ON cn.sale_id = ms.id
+        LEFT JOIN LATERAL (
+            SELECT id, status FROM sale_disputes WHERE sale_id = ms.id ORDER BY opened_at DESC, id DESC LIMIT 1
+        ) sd ON true
         ORDER BY ms.created_at DESC`
 
 	rows, err := db.pool.Query(context.Background(), query)
@@ -664,6 +970,8 @@ func (db *DB) GetMaterialSales() ([]models.MaterialSale, error) {
 			&sale.TotalAmount, &sale.CreatedAt, &sale.TransportationExpense, &sale.InwardEntryID,
 			&sale.OriginalWeightTons, &sale.DeductionType, &sale.DeductionValue,
 			&sale.DeductionAmount, &sale.DeductionReason, &sale.BillingWeightTons,
+			&sale.BuyerWeightTons, &sale.DisputeID, &sale.DisputeStatus, &sale.AmountBreakdown,
+			&sale.CancelledAt, &sale.CreditNoteNumber, &sale.SettledAmount,
 		); err != nil {
 			return nil, err
 		}
@@ -673,18 +981,23 @@ func (db *DB) GetMaterialSales() ([]models.MaterialSale, error) {
 	return sales, nil
 }
 func (db *DB) CreateEmployee(req *models.Employee) (*models.Employee, error) {
-	query := `INSERT INTO employees (id, name, designation) VALUES ($1, $2, $3) RETURNING id`
+	query := `INSERT INTO employees (id, name, designation, department) VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING id`
 	var empID int
-	err := db.pool.QueryRow(context.Background(), query, req.ID, req.Name, req.Designation).Scan(&empID)
+	err := db.pool.QueryRow(context.Background(), query, req.ID, req.Name, req.Designation, req.Department).Scan(&empID)
 	if err != nil {
 		return nil, err
 	}
-	return &models.Employee{ID: empID, Name: req.Name, Designation: req.Designation}, nil
+	return &models.Employee{ID: empID, Name: req.Name, Designation: req.Designation, Department: req.Department}, nil
 }
 
-func (db *DB) GetAllActiveEmployees() ([]models.Employee, error) {
-	query := `SELECT id, name, designation FROM employees WHERE is_active = TRUE ORDER BY name ASC`
-	rows, err := db.pool.Query(context.Background(), query)
+// GetAllActiveEmployees lists active employees. A nil departments slice lists
+// every department; otherwise only employees in the given departments are returned.
+func (db *DB) GetAllActiveEmployees(departments []string) ([]models.Employee, error) {
+	query := `
+		SELECT id, name, designation, COALESCE(department, '') FROM employees
+		WHERE is_active = TRUE AND ($1::text[] IS NULL OR department = ANY($1))
+		ORDER BY name ASC`
+	rows, err := db.pool.Query(context.Background(), query, departments)
 	if err != nil {
 		return nil, err
 	}
@@ -693,7 +1006,7 @@ func (db *DB) GetAllActiveEmployees() ([]models.Employee, error) {
 	var employees []models.Employee
 	for rows.Next() {
 		var emp models.Employee
-		if err := rows.Scan(&emp.ID, &emp.Name, &emp.Designation); err != nil {
+		if err := rows.Scan(&emp.ID, &emp.Name, &emp.Designation, &emp.Department); err != nil {
 			return nil, err
 		}
 		employees = append(employees, emp)
@@ -701,9 +1014,34 @@ func (db *DB) GetAllActiveEmployees() ([]models.Employee, error) {
 	return employees, nil
 }
 
+// GetEmployeeDepartments returns the department of each listed employee that
+// exists, keyed by employee ID. Employees without a department map to "".
+func (db *DB) GetEmployeeDepartments(ids []int) (map[int]string, error) {
+	query := `SELECT id, COALESCE(department, '') FROM employees WHERE id = ANY($1)`
+	rows, err := db.pool.Query(context.Background(), query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	departments := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var department string
+		if err := rows.Scan(&id, &department); err != nil {
+			return nil, err
+		}
+		departments[id] = department
+	}
+	return departments, rows.Err()
+}
+
+// UpdateEmployee updates an employee's details. An empty department keeps the current one.
 func (db *DB) UpdateEmployee(id int, req *models.Employee) error {
-	query := `UPDATE employees SET name = $1, designation = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`
-	_, err := db.pool.Exec(context.Background(), query, req.Name, req.Designation, id)
+	query := `
+		UPDATE employees SET name = $1, designation = $2, department = COALESCE(NULLIF($3, ''), department), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4`
+	_, err := db.pool.Exec(context.Background(), query, req.Name, req.Designation, req.Department, id)
 	return err
 }
 
@@ -713,13 +1051,17 @@ func (db *DB) DeactivateEmployee(id int) error {
 	return err
 }
 
-func (db *DB) GetMonthlyAttendance(month string) (map[string]map[int]string, error) {
+// GetMonthlyAttendance returns a month's attendance. A nil departments slice
+// covers every employee; otherwise only employees in those departments.
+func (db *DB) GetMonthlyAttendance(month string, departments []string) (map[string]map[int]string, error) {
 	query := `
-        SELECT employee_id, record_date, status 
-        FROM attendance_records 
-        WHERE TO_CHAR(record_date, 'YYYY-MM') = $1`
+        SELECT ar.employee_id, ar.record_date, ar.status
+        FROM attendance_records ar
+        JOIN employees e ON e.id = ar.employee_id
+        WHERE TO_CHAR(ar.record_date, 'YYYY-MM') = $1
+          AND ($2::text[] IS NULL OR e.department = ANY($2))`
 
-	rows, err := db.pool.Query(context.Background(), query, month)
+	rows, err := db.pool.Query(context.Background(), query, month, departments)
 	if err != nil {
 		return nil, err
 	}
@@ -831,7 +1173,11 @@ func (db *DB) DeleteAsset(assetID string) error {
 	_, err := db.pool.Exec(context.Background(), query, assetID)
 	return err
 }
-func (db *DB) SyncPermissions(permissions []string) error {
+
+// SyncPermissions inserts any permission missing from the database. When a
+// permission is added for the first time, roles holding any of its entries in
+// defaults are granted it too.
+func (db *DB) SyncPermissions(permissions []string, defaults map[string][]string) error {
 	// 1. Get all permissions currently in the database
 	rows, err := db.pool.Query(context.Background(), "SELECT action FROM permissions")
 	if err != nil {
@@ -866,6 +1212,23 @@ func (db *DB) SyncPermissions(permissions []string) error {
 			if _, err := tx.Exec(context.Background(), stmt.Name, p); err != nil {
 				return err
 			}
+
+			// 3. Grant the new permission to roles that hold its source permissions
+			if sources := defaults[p]; len(sources) > 0 {
+				grantQuery := `
+					INSERT INTO role_permissions (role_id, permission_id)
+					SELECT DISTINCT rp.role_id, np.id
+					FROM role_permissions rp
+					JOIN permissions sp ON rp.permission_id = sp.id
+					JOIN permissions np ON np.action = $1
+					WHERE sp.action = ANY($2)
+					ON CONFLICT DO NOTHING`
+				tag, err := tx.Exec(context.Background(), grantQuery, p, sources)
+				if err != nil {
+					return err
+				}
+				log.Printf("Granted %s to %d existing role(s)\n", p, tag.RowsAffected())
+			}
 		}
 	}
 
@@ -1408,8 +1771,8 @@ func (db *DB) DeleteVendorDocument(docID int) error {
 	return err
 }
 func (db *DB) DeleteInwardEntry(entryID int) error {
-	query := `DELETE FROM inward_entries WHERE id = $1 AND status = 'Pending'`
-	_, err := db.pool.Exec(context.Background(), query, entryID)
+	query := `DELETE FROM inward_entries WHERE id = $1 AND status = ANY($2)`
+	_, err := db.pool.Exec(context.Background(), query, entryID, models.InwardPendingStatuses)
 	return err
 }
 func (db *DB) AdjustInventory(req *models.AdjustInventoryRequest, userID int) (*models.InventoryAudit, error) {
@@ -1454,6 +1817,10 @@ func (db *DB) AdjustInventory(req *models.AdjustInventoryRequest, userID int) (*
 	if err != nil {
 		return nil, fmt.Errorf("could not create audit log: %w", err)
 	}
+	err = recordInventoryMovement(tx, materialName, newStockKg-currentStockKg, models.MovementSourceAdjustment, newLog.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not record stock movement: %w", err)
+	}
 
 	// Get user's name for the response
 	err = tx.QueryRow(context.Background(), `SELECT full_name FROM users WHERE id = $1`, userID).Scan(&auditedByUserName)
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrAlreadyApproved is returned when approving a record that already carries an approval.
+	ErrAlreadyApproved = errors.New("record is already approved")
+	// ErrSelfApproval is returned when a user tries to approve a record they created.
+	ErrSelfApproval = errors.New("records cannot be approved by their creator")
+	// ErrLastAdmin is returned when a change would leave no active user holding manage:users globally.
+	ErrLastAdmin = errors.New("change would remove the last administrator")
+	// ErrVendorNotApproved is returned when linking a record to a vendor that is missing or not approved.
+	ErrVendorNotApproved = errors.New("vendor is not approved")
+)
+
+// TransitionError is returned when a state change is not allowed from the record's current state.
+type TransitionError struct {
+	Entity string
+	From   string
+	To     string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s cannot move from %q to %q", e.Entity, e.From, e.To)
+}
+
+// RoleInUseError is returned when deleting a role that is still assigned to users.
+type RoleInUseError struct {
+	Users int
+}
+
+func (e *RoleInUseError) Error() string {
+	return fmt.Sprintf("role is still assigned to %d user(s)", e.Users)
+}
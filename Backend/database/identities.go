@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrInvalidOIDCState is returned when an OIDC callback's state is unknown, used, expired
+// or was started in a different browser.
+var ErrInvalidOIDCState = errors.New("invalid or expired login state")
+
+// CreateOIDCLoginState stores the nonce and PKCE verifier of a login that was sent to the identity provider,
+// together with the hash of the cookie that binds it to the browser that started it.
+func (db *DB) CreateOIDCLoginState(stateHash, bindingHash, nonce, codeVerifier string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO oidc_login_states (state_hash, browser_binding_hash, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err := db.pool.Exec(context.Background(), query, stateHash, bindingHash, nonce, codeVerifier, expiresAt)
+	return err
+}
+
+// ConsumeOIDCLoginState marks a login state as used and returns its nonce and PKCE verifier.
+// Each state can be consumed once, and only from the browser holding its binding cookie.
+func (db *DB) ConsumeOIDCLoginState(stateHash, bindingHash string) (string, string, error) {
+	var nonce, codeVerifier string
+	query := `
+		UPDATE oidc_login_states SET used_at = NOW()
+		WHERE state_hash = $1 AND browser_binding_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING nonce, code_verifier`
+	err := db.pool.QueryRow(context.Background(), query, stateHash, bindingHash).Scan(&nonce, &codeVerifier)
+	if err == pgx.ErrNoRows {
+		return "", "", ErrInvalidOIDCState
+	}
+	return nonce, codeVerifier, err
+}
+
+// GetUserIDByIdentity returns the user linked to an external identity and records the login.
+// It returns pgx.ErrNoRows when the identity is not linked.
+func (db *DB) GetUserIDByIdentity(issuer, subject, email string) (int, error) {
+	var userID int
+	query := `
+		UPDATE user_identities SET last_login_at = NOW(), email = COALESCE(NULLIF($3, ''), email)
+		WHERE issuer = $1 AND subject = $2
+		RETURNING user_id`
+	err := db.pool.QueryRow(context.Background(), query, issuer, subject, email).Scan(&userID)
+	return userID, err
+}
+
+// LinkUserIdentity attaches an external identity to an existing user.
+func (db *DB) LinkUserIdentity(userID int, issuer, subject, email string) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NOW())`
+	_, err := db.pool.Exec(context.Background(), query, userID, issuer, subject, email)
+	return err
+}
+
+// CreatePendingExternalUser registers a user first seen at the identity provider.
+// The account starts pending, like a self-registration, and must be approved before it can log in.
+func (db *DB) CreatePendingExternalUser(fullName, email, passwordHash, issuer, subject string) (int, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var userID int
+	query := `INSERT INTO users (full_name, email, password_hash) VALUES ($1, $2, $3) RETURNING id`
+	if err := tx.QueryRow(context.Background(), query, fullName, email, passwordHash).Scan(&userID); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(context.Background(), `
+		INSERT INTO user_identities (user_id, issuer, subject, email)
+		VALUES ($1, $2, $3, $4)`, userID, issuer, subject, email)
+	if err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit(context.Background())
+}
+
+// GetUserIdentities lists the external identities linked to a user.
+func (db *DB) GetUserIdentities(userID int) ([]models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at ASC`
+	rows, err := db.pool.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []models.UserIdentity
+	for rows.Next() {
+		var i models.UserIdentity
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Issuer, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// UnlinkUserIdentity removes an external identity from a user.
+func (db *DB) UnlinkUserIdentity(userID, identityID int) error {
+	tag, err := db.pool.Exec(context.Background(), `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, identityID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
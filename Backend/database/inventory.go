@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// postInventoryMovement changes a material's stock and records why.
+func postInventoryMovement(tx pgx.Tx, materialName string, quantityKg float64, sourceType string, sourceID int, userID int) error {
+	if err := updateInventoryStock(tx, materialName, quantityKg); err != nil {
+		return err
+	}
+	return recordInventoryMovement(tx, materialName, quantityKg, sourceType, sourceID, userID)
+}
+
+func recordInventoryMovement(tx pgx.Tx, materialName string, quantityKg float64, sourceType string, sourceID int, userID int) error {
+	query := `
+		INSERT INTO inventory_movements (material_name, quantity_kg, source_type, source_id, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err := tx.Exec(context.Background(), query, materialName, quantityKg, sourceType, sourceID, userID)
+	return err
+}
+
+// applyInventoryPostingRule posts a completed entry's net weight according to
+// the rule for its entry type. Types without a rule, or whose rule needs the
+// entry's material when it has none, leave stock unchanged.
+func applyInventoryPostingRule(tx pgx.Tx, entryID int, entryType string, material *string, netWeightKg float64, userID int) error {
+	var action string
+	var ruleMaterial *string
+	err := tx.QueryRow(context.Background(), `SELECT action, material_name FROM inventory_posting_rules WHERE entry_type = $1`, entryType).Scan(&action, &ruleMaterial)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	target := ruleMaterial
+	if target == nil || *target == "" {
+		target = material
+	}
+	if target == nil || *target == "" {
+		return nil
+	}
+
+	switch action {
+	case models.PostingActionAdd:
+		return postInventoryMovement(tx, *target, netWeightKg, models.MovementSourceInwardEntry, entryID, userID)
+	case models.PostingActionDeduct:
+		return postInventoryMovement(tx, *target, -netWeightKg, models.MovementSourceInwardEntry, entryID, userID)
+	}
+	return nil
+}
+
+// GetInventoryPostingRules lists the posting rule of every entry type.
+func (db *DB) GetInventoryPostingRules() ([]models.InventoryPostingRule, error) {
+	query := `
+		SELECT entry_type, action, material_name, updated_by_user_id, updated_at
+		FROM inventory_posting_rules
+		ORDER BY entry_type ASC`
+	rows, err := db.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.InventoryPostingRule
+	for rows.Next() {
+		var r models.InventoryPostingRule
+		if err := rows.Scan(&r.EntryType, &r.Action, &r.MaterialName, &r.UpdatedByUserID, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// SetInventoryPostingRule creates or replaces the posting rule for an entry type.
+// It only affects entries completed afterwards.
+func (db *DB) SetInventoryPostingRule(entryType string, req *models.UpdateInventoryPostingRuleRequest, userID int) (*models.InventoryPostingRule, error) {
+	var materialName *string
+	if req.MaterialName != nil && *req.MaterialName != "" {
+		materialName = req.MaterialName
+	}
+
+	rule := models.InventoryPostingRule{EntryType: entryType}
+	query := `
+		INSERT INTO inventory_posting_rules (entry_type, action, material_name, updated_by_user_id, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (entry_type)
+		DO UPDATE SET action = $2, material_name = $3, updated_by_user_id = $4, updated_at = NOW()
+		RETURNING action, material_name, updated_by_user_id, updated_at`
+	err := db.pool.QueryRow(context.Background(), query, entryType, req.Action, materialName, userID).Scan(&rule.Action, &rule.MaterialName, &rule.UpdatedByUserID, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetInventoryMovements lists the latest stock movements, optionally for one material.
+func (db *DB) GetInventoryMovements(materialName string, limit int) ([]models.InventoryMovement, error) {
+	query := `
+		SELECT m.id, m.material_name, m.quantity_kg, m.source_type, m.source_id, u.full_name, m.created_at
+		FROM inventory_movements m
+		LEFT JOIN users u ON m.created_by_user_id = u.id
+		WHERE $1 = '' OR m.material_name = $1
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $2`
+	rows, err := db.pool.Query(context.Background(), query, materialName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []models.InventoryMovement
+	for rows.Next() {
+		var m models.InventoryMovement
+		var quantityKg float64
+		if err := rows.Scan(&m.ID, &m.MaterialName, &quantityKg, &m.SourceType, &m.SourceID, &m.CreatedByName, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.QuantityTons = quantityKg / 1000
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/solaris-hms/mrf-backend/billing"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+var (
+	// ErrInvalidGSTIN is returned for a GSTIN that is malformed or fails its check digit.
+	ErrInvalidGSTIN = errors.New("invalid GSTIN")
+	// ErrInvalidStateCode is returned for a state code GST does not define.
+	ErrInvalidStateCode = errors.New("invalid state code")
+	// ErrSaleAlreadyInvoiced is returned when issuing a second invoice for a sale.
+	ErrSaleAlreadyInvoiced = errors.New("sale already has a tax invoice")
+	// ErrMissingHSNCode is returned when invoicing a material without an HSN code.
+	ErrMissingHSNCode = errors.New("material has no HSN code")
+	// ErrBuyerStateUnknown is returned when invoicing a party with neither a GSTIN nor a state code.
+	ErrBuyerStateUnknown = errors.New("buyer has no GSTIN or state code")
+)
+
+func (db *DB) GetMaterialHSNCodes() ([]models.MaterialHSNCode, error) {
+	rows, err := db.pool.Query(context.Background(), `SELECT material_name, hsn_code, updated_by_user_id, updated_at FROM material_hsn_codes ORDER BY material_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []models.MaterialHSNCode
+	for rows.Next() {
+		var h models.MaterialHSNCode
+		if err := rows.Scan(&h.MaterialName, &h.HSNCode, &h.UpdatedByUserID, &h.UpdatedAt); err != nil {
+			return nil, err
+		}
+		codes = append(codes, h)
+	}
+	return codes, rows.Err()
+}
+
+// SetMaterialHSNCode creates or replaces the HSN code a material is invoiced under.
+func (db *DB) SetMaterialHSNCode(material string, req *models.UpdateMaterialHSNCodeRequest, userID int) (*models.MaterialHSNCode, error) {
+	h := models.MaterialHSNCode{MaterialName: material}
+	query := `
+		INSERT INTO material_hsn_codes (material_name, hsn_code, updated_by_user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (material_name) DO UPDATE
+		SET hsn_code = EXCLUDED.hsn_code, updated_by_user_id = EXCLUDED.updated_by_user_id, updated_at = NOW()
+		RETURNING hsn_code, updated_by_user_id, updated_at`
+	err := db.pool.QueryRow(context.Background(), query, material, req.HSNCode, userID).Scan(&h.HSNCode, &h.UpdatedByUserID, &h.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// IssueTaxInvoice issues the tax invoice for a sale, dated today and numbered
+// next in today's financial year, so invoicing a sale from a closed year does
+// not reopen that year's series. The tax is split into CGST and SGST when the
+// buyer is in the seller's state and charged as IGST otherwise. It returns
+// pgx.ErrNoRows for an unknown sale.
+func (db *DB) IssueTaxInvoice(saleID int, seller billing.Seller, userID int) (*models.TaxInvoice, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var (
+		partyID                               int
+		cancelledAt                           *time.Time
+		rate, gstRate, amount, gst, total     float64
+		quantity                              float64
+		material, vehicle, hsnCode            *string
+		buyerName                             string
+		buyerGSTIN, address, city, pin, state *string
+		invoiced                              bool
+	)
+	query := `
+		SELECT ms.party_id, ms.cancelled_at, ms.rate, ms.gst_percentage, ms.amount, ms.gst_amount,
+			ms.total_amount, COALESCE(ms.billing_weight_tons, ie.net_weight / 1000), ie.material, ie.vehicle_number,
+			h.hsn_code, p.name, p.gstin, p.address, p.city, p.pincode, p.state_code,
+			EXISTS (SELECT 1 FROM tax_invoices WHERE sale_id = ms.id)
+		FROM material_sales ms
+		JOIN inward_entries ie ON ms.inward_entry_id = ie.id
+		JOIN partners p ON ms.party_id = p.id
+		LEFT JOIN material_hsn_codes h ON h.material_name = ie.material
+		WHERE ms.id = $1
+		FOR UPDATE OF ms`
+	err = tx.QueryRow(context.Background(), query, saleID).Scan(&partyID, &cancelledAt, &rate, &gstRate,
+		&amount, &gst, &total, &quantity, &material, &vehicle, &hsnCode, &buyerName, &buyerGSTIN, &address, &city, &pin, &state, &invoiced)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case invoiced:
+		return nil, ErrSaleAlreadyInvoiced
+	case cancelledAt != nil:
+		return nil, ErrSaleCancelled
+	case material == nil || hsnCode == nil:
+		return nil, ErrMissingHSNCode
+	}
+	placeOfSupply := ""
+	if buyerGSTIN != nil {
+		placeOfSupply = (*buyerGSTIN)[:2]
+	} else if state != nil {
+		placeOfSupply = *state
+	}
+	if placeOfSupply == "" {
+		return nil, ErrBuyerStateUnknown
+	}
+
+	taxable, gstAmount := decimal.NewFromFloat(amount), decimal.NewFromFloat(gst)
+	split := billing.SplitGST(gstAmount, decimal.NewFromFloat(gstRate), seller.StateCode(), placeOfSupply)
+	roundOff := decimal.NewFromFloat(total).Sub(taxable).Sub(gstAmount)
+
+	// The counter row stays locked until commit, so concurrent invoices in
+	// the same year take consecutive numbers and a rollback leaves no gap.
+	issueDate := billing.Today()
+	financialYear := billing.FinancialYear(issueDate)
+	var seq int
+	err = tx.QueryRow(context.Background(), `
+		INSERT INTO invoice_number_counters (financial_year, last_number) VALUES ($1, 1)
+		ON CONFLICT (financial_year) DO UPDATE SET last_number = invoice_number_counters.last_number + 1
+		RETURNING last_number`, financialYear).Scan(&seq)
+	if err != nil {
+		return nil, err
+	}
+
+	var invoiceID int
+	insert := `
+		INSERT INTO tax_invoices (
+			invoice_number, financial_year, sequence_number, invoice_date, sale_id,
+			seller_gstin, seller_name, seller_address, seller_location, seller_pincode,
+			buyer_partner_id, buyer_name, buyer_gstin, buyer_address, buyer_location, buyer_pincode, place_of_supply, interstate,
+			material, hsn_code, quantity_tons, rate_per_ton, taxable_value, gst_rate,
+			cgst_rate, cgst_amount, sgst_rate, sgst_amount, igst_rate, igst_amount, round_off, total_amount,
+			vehicle_number, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, $12, $13, $14, $15, $16, $17, $18,
+			$19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34)
+		RETURNING id`
+	err = tx.QueryRow(context.Background(), insert,
+		billing.InvoiceNumber(financialYear, seq), financialYear, seq, issueDate, saleID,
+		seller.GSTIN, seller.LegalName, seller.Address, seller.Location, seller.Pincode,
+		partyID, buyerName, buyerGSTIN, address, city, pin, placeOfSupply, split.Interstate,
+		*material, *hsnCode, quantity, rate, taxable.String(), gstRate,
+		split.CGSTRate.String(), split.CGST.String(), split.SGSTRate.String(), split.SGST.String(),
+		split.IGSTRate.String(), split.IGST.String(), roundOff.String(), total,
+		vehicle, userID,
+	).Scan(&invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice, err := getTaxInvoice(tx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	return invoice, tx.Commit(context.Background())
+}
+
+const taxInvoiceSelect = `
+	SELECT ti.id, ti.invoice_number, ti.financial_year, ti.sequence_number, ti.invoice_date, ti.sale_id, ti.vehicle_number,
+		ti.seller_gstin, ti.seller_name, ti.seller_address, ti.seller_location, ti.seller_pincode,
+		ti.buyer_partner_id, ti.buyer_name, ti.buyer_gstin, ti.buyer_address, ti.buyer_location, ti.buyer_pincode,
+		ti.place_of_supply, ti.interstate,
+		ti.material, ti.hsn_code, ti.quantity_tons, ti.rate_per_ton, ti.taxable_value, ti.gst_rate,
+		ti.cgst_rate, ti.cgst_amount, ti.sgst_rate, ti.sgst_amount, ti.igst_rate, ti.igst_amount, ti.round_off, ti.total_amount,
+		cn.credit_note_number, ti.created_by_user_id, u.full_name, ti.created_at
+	FROM tax_invoices ti
+	LEFT JOIN sale_credit_notes cn ON cn.sale_id = ti.sale_id
+	LEFT JOIN users u ON ti.created_by_user_id = u.id`
+
+func scanTaxInvoice(row pgx.Row) (*models.TaxInvoice, error) {
+	var t models.TaxInvoice
+	err := row.Scan(&t.ID, &t.InvoiceNumber, &t.FinancialYear, &t.SequenceNumber, &t.InvoiceDate, &t.SaleID, &t.VehicleNumber,
+		&t.SellerGSTIN, &t.SellerName, &t.SellerAddress, &t.SellerLocation, &t.SellerPincode,
+		&t.BuyerPartnerID, &t.BuyerName, &t.BuyerGSTIN, &t.BuyerAddress, &t.BuyerLocation, &t.BuyerPincode,
+		&t.PlaceOfSupply, &t.Interstate,
+		&t.Material, &t.HSNCode, &t.QuantityTons, &t.RatePerTon, &t.TaxableValue, &t.GSTRate,
+		&t.CGSTRate, &t.CGSTAmount, &t.SGSTRate, &t.SGSTAmount, &t.IGSTRate, &t.IGSTAmount, &t.RoundOff, &t.TotalAmount,
+		&t.CreditNoteNumber, &t.CreatedByUserID, &t.CreatedByName, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func getTaxInvoice(q querier, id int) (*models.TaxInvoice, error) {
+	return scanTaxInvoice(q.QueryRow(context.Background(), taxInvoiceSelect+` WHERE ti.id = $1`, id))
+}
+
+func (db *DB) GetTaxInvoice(id int) (*models.TaxInvoice, error) {
+	return getTaxInvoice(db.pool, id)
+}
+
+// GetTaxInvoices lists invoices dated within the optional range in number order.
+func (db *DB) GetTaxInvoices(from, to *time.Time) ([]models.TaxInvoice, error) {
+	query := taxInvoiceSelect + `
+	WHERE ($1::timestamptz IS NULL OR ti.invoice_date >= $1) AND ($2::timestamptz IS NULL OR ti.invoice_date < $2)
+	ORDER BY ti.financial_year, ti.sequence_number`
+	rows, err := db.pool.Query(context.Background(), query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []models.TaxInvoice
+	for rows.Next() {
+		t, err := scanTaxInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, *t)
+	}
+	return invoices, rows.Err()
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TransitionInwardEntry moves an inward entry to another weighbridge state and records the change.
+func (db *DB) TransitionInwardEntry(entryID int, toStatus string, note string, actorID int) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := transitionInwardEntry(tx, entryID, toStatus, note, actorID); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// transitionInwardEntry locks the entry, checks the move against the lifecycle
+// and the entry's direction, then updates it and appends to its history.
+func transitionInwardEntry(tx pgx.Tx, entryID int, toStatus string, note string, actorID int) error {
+	var fromStatus, entryType string
+	err := tx.QueryRow(context.Background(), `SELECT status, entry_type FROM inward_entries WHERE id = $1 FOR UPDATE`, entryID).Scan(&fromStatus, &entryType)
+	if err != nil {
+		return err
+	}
+	if !models.CanTransitionInwardStatus(fromStatus, toStatus) {
+		return &TransitionError{Entity: "inward entry", From: fromStatus, To: toStatus}
+	}
+	// Outgoing vehicles are loaded, incoming ones unloaded.
+	outgoing := models.IsOutgoingEntryType(entryType)
+	if (toStatus == models.InwardStatusLoading && !outgoing) || (toStatus == models.InwardStatusUnloading && outgoing) {
+		return &TransitionError{Entity: "inward entry", From: fromStatus, To: toStatus}
+	}
+
+	query := `
+		UPDATE inward_entries
+		SET status = $1, completed_at = CASE WHEN $1 = 'second_weighed' THEN CURRENT_TIMESTAMP ELSE completed_at END
+		WHERE id = $2`
+	if _, err := tx.Exec(context.Background(), query, toStatus, entryID); err != nil {
+		return err
+	}
+	return recordInwardStatusChange(tx, entryID, &fromStatus, toStatus, note, actorID)
+}
+
+func recordInwardStatusChange(tx pgx.Tx, entryID int, fromStatus *string, toStatus string, note string, actorID int) error {
+	var noteArg *string
+	if note != "" {
+		noteArg = &note
+	}
+	query := `
+		INSERT INTO inward_entry_status_history (inward_entry_id, from_status, to_status, note, changed_by_user_id)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err := tx.Exec(context.Background(), query, entryID, fromStatus, toStatus, noteArg, actorID)
+	return err
+}
+
+// GetInwardEntryHistory lists an entry's lifecycle changes in the order they happened.
+// It returns pgx.ErrNoRows when the entry does not exist.
+func (db *DB) GetInwardEntryHistory(entryID int) ([]models.InwardStatusChange, error) {
+	var exists bool
+	if err := db.pool.QueryRow(context.Background(), `SELECT EXISTS (SELECT 1 FROM inward_entries WHERE id = $1)`, entryID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, pgx.ErrNoRows
+	}
+
+	query := `
+		SELECT h.id, h.inward_entry_id, h.from_status, h.to_status, h.note, h.changed_by_user_id, u.full_name, h.created_at
+		FROM inward_entry_status_history h
+		LEFT JOIN users u ON h.changed_by_user_id = u.id
+		WHERE h.inward_entry_id = $1
+		ORDER BY h.created_at ASC, h.id ASC`
+	rows, err := db.pool.Query(context.Background(), query, entryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.InwardStatusChange
+	for rows.Next() {
+		var ch models.InwardStatusChange
+		if err := rows.Scan(&ch.ID, &ch.InwardEntryID, &ch.FromStatus, &ch.ToStatus, &ch.Note, &ch.ChangedByUserID, &ch.ChangedByName, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, ch)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordLoginAttempt stores the outcome of a single login attempt.
+func (db *DB) RecordLoginAttempt(attempt *models.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (email, user_id, ip_address, user_agent, success, failure_reason)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := db.pool.Exec(context.Background(), query,
+		attempt.Email, attempt.UserID, attempt.IPAddress, attempt.UserAgent, attempt.Success, attempt.FailureReason)
+	return err
+}
+
+// GetRecentFailedLoginsByIP counts failed logins from an IP since the given time
+// and returns when the latest of them happened.
+func (db *DB) GetRecentFailedLoginsByIP(ipAddress string, since time.Time) (int, *time.Time, error) {
+	var count int
+	var lastFailure *time.Time
+	query := `
+		SELECT COUNT(*), MAX(created_at) FROM login_attempts
+		WHERE ip_address = $1 AND success = false AND created_at > $2`
+	err := db.pool.QueryRow(context.Background(), query, ipAddress, since).Scan(&count, &lastFailure)
+	return count, lastFailure, err
+}
+
+// RecordFailedLogin increments the user's consecutive failure counter and
+// returns the new count.
+func (db *DB) RecordFailedLogin(userID int) (int, error) {
+	var count int
+	query := `
+		UPDATE users SET failed_login_count = failed_login_count + 1, last_failed_login_at = NOW()
+		WHERE id = $1
+		RETURNING failed_login_count`
+	err := db.pool.QueryRow(context.Background(), query, userID).Scan(&count)
+	return count, err
+}
+
+// LockUserUntil blocks further login attempts for the user until the given time.
+func (db *DB) LockUserUntil(userID int, until time.Time) error {
+	_, err := db.pool.Exec(context.Background(), `UPDATE users SET locked_until = $1 WHERE id = $2`, until, userID)
+	return err
+}
+
+// ResetFailedLogins clears the failure counter and any lock after a successful login.
+func (db *DB) ResetFailedLogins(userID int) error {
+	query := `UPDATE users SET failed_login_count = 0, locked_until = NULL WHERE id = $1`
+	_, err := db.pool.Exec(context.Background(), query, userID)
+	return err
+}
+
+// GetLockedAccounts lists users whose lockout has been reached and not yet expired.
+func (db *DB) GetLockedAccounts(lockoutThreshold int) ([]models.LockedAccount, error) {
+	query := `
+		SELECT id, full_name, email, failed_login_count, last_failed_login_at, locked_until
+		FROM users
+		WHERE locked_until > NOW() AND failed_login_count >= $1
+		ORDER BY locked_until DESC`
+	rows, err := db.pool.Query(context.Background(), query, lockoutThreshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.LockedAccount
+	for rows.Next() {
+		var a models.LockedAccount
+		if err := rows.Scan(&a.UserID, &a.FullName, &a.Email, &a.FailedLoginCount, &a.LastFailedLoginAt, &a.LockedUntil); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
+
+// UnlockUser lifts a lockout early. It returns pgx.ErrNoRows if the user does not exist.
+func (db *DB) UnlockUser(userID int) error {
+	tag, err := db.pool.Exec(context.Background(), `UPDATE users SET failed_login_count = 0, locked_until = NULL WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// GetLoginAttempts returns login attempts matching the filter, newest first.
+func (db *DB) GetLoginAttempts(filter *models.LoginAttemptFilter) ([]models.LoginAttempt, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.Email != "" {
+		conditions = append(conditions, fmt.Sprintf("email = $%d", argCount))
+		args = append(args, filter.Email)
+		argCount++
+	}
+	if filter.IPAddress != "" {
+		conditions = append(conditions, fmt.Sprintf("ip_address = $%d", argCount))
+		args = append(args, filter.IPAddress)
+		argCount++
+	}
+	if filter.Success != nil {
+		conditions = append(conditions, fmt.Sprintf("success = $%d", argCount))
+		args = append(args, *filter.Success)
+		argCount++
+	}
+	if filter.From != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argCount))
+		args = append(args, *filter.From)
+		argCount++
+	}
+	if filter.To != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", argCount))
+		args = append(args, *filter.To)
+		argCount++
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	query := fmt.Sprintf(`
+		SELECT id, email, user_id, ip_address, user_agent, success, failure_reason, created_at
+		FROM login_attempts
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d`, where, argCount, argCount+1)
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := db.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []models.LoginAttempt
+	for rows.Next() {
+		var a models.LoginAttempt
+		if err := rows.Scan(&a.ID, &a.Email, &a.UserID, &a.IPAddress, &a.UserAgent, &a.Success, &a.FailureReason, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, nil
+}
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLockID is the pg_advisory_lock key that serialises migration runs
+// across every server instance pointed at the same database.
+const migrationLockID int64 = 7316230419
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a single numbered schema change with its up and down SQL.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a known migration has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads every NNN_name.up.sql / NNN_name.down.sql pair from fsys
+// and returns them ordered by version. Versions must run 1..N without gaps.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration numbering gap: expected version %d, found %d (%s)", i+1, m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration in order. It refuses to run if the
+// database has a version newer than anything in migrations.
+func (db *DB) MigrateUp(migrations []Migration) error {
+	return db.withMigrationLock(func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkNotAhead(applied, migrations); err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			log.Printf("Applying migration %06d_%s\n", m.Version, m.Name)
+			if err := runMigration(conn, m.Up, `INSERT INTO schema_versions (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+				return fmt.Errorf("migration %06d_%s failed: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the most recently applied migrations, newest first.
+func (db *DB) MigrateDown(migrations []Migration, steps int) error {
+	return db.withMigrationLock(func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkNotAhead(applied, migrations); err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			log.Printf("Reverting migration %06d_%s\n", m.Version, m.Name)
+			if err := runMigration(conn, m.Down, `DELETE FROM schema_versions WHERE version = $1`, m.Version); err != nil {
+				return fmt.Errorf("rollback of %06d_%s failed: %w", m.Version, m.Name, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// GetMigrationStatus lists every known migration alongside when it was applied.
+func (db *DB) GetMigrationStatus(migrations []Migration) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := db.withMigrationLock(func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkNotAhead(applied, migrations); err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			status := MigrationStatus{Version: m.Version, Name: m.Name}
+			if appliedAt, ok := applied[m.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+func (db *DB) withMigrationLock(fn func(conn *pgxpool.Conn) error) error {
+	conn, err := db.pool.Acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("could not acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_versions (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`
+	if _, err := conn.Exec(context.Background(), query); err != nil {
+		return err
+	}
+	if err := importLegacyMigrateVersion(conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// execQuerier is the part of a connection importLegacyMigrateVersion uses.
+type execQuerier interface {
+	querier
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// importLegacyMigrateVersion seeds schema_versions from the single-row
+// schema_migrations table written by the golang-migrate CLI that deployments
+// used before the runner was built in, so those databases are not re-migrated.
+func importLegacyMigrateVersion(conn execQuerier) error {
+	var tracked int
+	if err := conn.QueryRow(context.Background(), `SELECT COUNT(*) FROM schema_versions`).Scan(&tracked); err != nil {
+		return err
+	}
+	if tracked > 0 {
+		return nil
+	}
+
+	var hasLegacy bool
+	if err := conn.QueryRow(context.Background(), `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&hasLegacy); err != nil {
+		return err
+	}
+	if !hasLegacy {
+		return nil
+	}
+
+	var version int64
+	var dirty bool
+	err := conn.QueryRow(context.Background(), `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("legacy schema_migrations is marked dirty at version %d; repair it before starting", version)
+	}
+
+	log.Printf("Importing legacy migration state up to version %d\n", version)
+	_, err = conn.Exec(context.Background(), `
+		INSERT INTO schema_versions (version, name)
+		SELECT v, 'imported' FROM generate_series(1, $1::int) AS v`, version)
+	return err
+}
+
+func appliedMigrations(conn *pgxpool.Conn) (map[int]time.Time, error) {
+	rows, err := conn.Query(context.Background(), `SELECT version, applied_at FROM schema_versions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func checkNotAhead(applied map[int]time.Time, migrations []Migration) error {
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("database schema is at version %d but this binary only knows up to %d", version, latest)
+		}
+	}
+	return nil
+}
+
+func runMigration(conn *pgxpool.Conn, script string, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if strings.TrimSpace(script) != "" {
+		if _, err := tx.Exec(context.Background(), script); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(context.Background(), bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
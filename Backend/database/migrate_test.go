@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_add_b.up.sql":   file("CREATE TABLE b ();"),
+		"000002_add_b.down.sql": file("DROP TABLE b;"),
+		"000001_add_a.up.sql":   file("CREATE TABLE a ();"),
+		"000001_add_a.down.sql": file("DROP TABLE a;"),
+		"000003_seed.up.sql":    file("INSERT INTO a DEFAULT VALUES;"),
+		"migrations.go":         file("package migrations"),
+		"README.md":             file("not a migration"),
+		"old/000009_x.up.sql":   file("ignored: in a subdirectory"),
+	}
+	got, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "add_a", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;"},
+		{Version: 2, Name: "add_b", Up: "CREATE TABLE b ();", Down: "DROP TABLE b;"},
+		{Version: 3, Name: "seed", Up: "INSERT INTO a DEFAULT VALUES;"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("migrations = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadMigrationsRejectsBadSets(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr string
+	}{
+		{
+			name: "gap",
+			fsys: fstest.MapFS{
+				"000001_a.up.sql": file("SELECT 1;"),
+				"000003_c.up.sql": file("SELECT 3;"),
+			},
+			wantErr: "numbering gap: expected version 2, found 3",
+		},
+		{
+			name: "not starting at one",
+			fsys: fstest.MapFS{
+				"000002_b.up.sql": file("SELECT 2;"),
+			},
+			wantErr: "expected version 1, found 2",
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"000001_a.up.sql":     file("SELECT 1;"),
+				"000001_other.up.sql": file("SELECT 1;"),
+			},
+			wantErr: "version 1 is used by both",
+		},
+		{
+			name: "down without up",
+			fsys: fstest.MapFS{
+				"000001_a.up.sql":   file("SELECT 1;"),
+				"000002_b.down.sql": file("SELECT 2;"),
+			},
+			wantErr: "migration 2 (b) has no up script",
+		},
+		{
+			name: "blank up script",
+			fsys: fstest.MapFS{
+				"000001_a.up.sql": file("  \n"),
+			},
+			wantErr: "has no up script",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadMigrations(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type fakeRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+// legacyConn answers the queries importLegacyMigrateVersion makes.
+type legacyConn struct {
+	tracked     int
+	hasLegacy   bool
+	emptyLegacy bool
+	version     int64
+	dirty       bool
+
+	imported []int64
+}
+
+func (c *legacyConn) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	switch {
+	case strings.Contains(sql, "FROM schema_versions"):
+		return fakeRow{values: []any{c.tracked}}
+	case strings.Contains(sql, "to_regclass('schema_migrations')"):
+		return fakeRow{values: []any{c.hasLegacy}}
+	case strings.Contains(sql, "FROM schema_migrations"):
+		if c.emptyLegacy {
+			return fakeRow{err: pgx.ErrNoRows}
+		}
+		return fakeRow{values: []any{c.version, c.dirty}}
+	}
+	panic("unexpected query: " + sql)
+}
+
+func (c *legacyConn) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if !strings.Contains(sql, "INSERT INTO schema_versions") {
+		panic("unexpected statement: " + sql)
+	}
+	c.imported = append(c.imported, args[0].(int64))
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func TestImportLegacyMigrateVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		conn     legacyConn
+		imported []int64
+		wantErr  string
+	}{
+		{name: "imports clean legacy version", conn: legacyConn{hasLegacy: true, version: 12}, imported: []int64{12}},
+		{name: "already tracked", conn: legacyConn{tracked: 5, hasLegacy: true, version: 12}},
+		{name: "no legacy table", conn: legacyConn{}},
+		{name: "empty legacy table", conn: legacyConn{hasLegacy: true, emptyLegacy: true}},
+		{name: "dirty legacy version", conn: legacyConn{hasLegacy: true, version: 7, dirty: true}, wantErr: "dirty at version 7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := tt.conn
+			err := importLegacyMigrateVersion(&conn)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(conn.imported, tt.imported) {
+				t.Fatalf("imported up to %v, want %v", conn.imported, tt.imported)
+			}
+		})
+	}
+}
+
+func TestCheckNotAhead(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}}
+	if err := checkNotAhead(map[int]time.Time{1: {}, 2: {}}, migrations); err != nil {
+		t.Fatalf("up-to-date database rejected: %v", err)
+	}
+	if err := checkNotAhead(map[int]time.Time{3: {}}, migrations); err == nil {
+		t.Fatal("database ahead of the binary accepted")
+	}
+}
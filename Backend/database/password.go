@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrInvalidResetToken is returned when a password reset token is unknown, used or expired.
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// GetRecentPasswordHashes returns the user's current password hash followed by
+// up to limit previous hashes, newest first.
+func (db *DB) GetRecentPasswordHashes(userID int, limit int) ([]string, error) {
+	query := `
+		(SELECT password_hash, NOW() AS created_at FROM users WHERE id = $1)
+		UNION ALL
+		(SELECT password_hash, created_at FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2)
+		ORDER BY created_at DESC`
+	rows, err := db.pool.Query(context.Background(), query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		var createdAt time.Time
+		if err := rows.Scan(&hash, &createdAt); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// UpdateUserPassword replaces the user's password, records the old hash in the
+// history and clears any forced-rotation flag.
+func (db *DB) UpdateUserPassword(userID int, passwordHash string) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := setUserPassword(tx, userID, passwordHash); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+func setUserPassword(tx pgx.Tx, userID int, passwordHash string) error {
+	historyQuery := `INSERT INTO password_history (user_id, password_hash) SELECT id, password_hash FROM users WHERE id = $1`
+	if _, err := tx.Exec(context.Background(), historyQuery, userID); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE users
+		SET password_hash = $1, must_change_password = false, password_changed_at = NOW()
+		WHERE id = $2`
+	_, err := tx.Exec(context.Background(), query, passwordHash, userID)
+	return err
+}
+
+// SetMustChangePassword flags (or unflags) a user to rotate their password on next login.
+func (db *DB) SetMustChangePassword(userID int, mustChange bool) error {
+	query := `UPDATE users SET must_change_password = $1 WHERE id = $2`
+	tag, err := db.pool.Exec(context.Background(), query, mustChange, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// CreatePasswordResetToken stores a new one-time reset token, invalidating any
+// earlier unused token for the same user.
+func (db *DB) CreatePasswordResetToken(userID int, tokenHash string, expiresAt time.Time, createdByUserID int) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	_, err = tx.Exec(context.Background(), `UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, created_by_user_id, expires_at)
+		VALUES ($1, $2, $3, $4)`
+	_, err = tx.Exec(context.Background(), query, userID, tokenHash, createdByUserID, expiresAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// GetPasswordResetTokenUser returns the user a still-valid reset token belongs to.
+func (db *DB) GetPasswordResetTokenUser(tokenHash string) (int, error) {
+	var userID int
+	query := `SELECT user_id FROM password_reset_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`
+	err := db.pool.QueryRow(context.Background(), query, tokenHash).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return 0, ErrInvalidResetToken
+	}
+	return userID, err
+}
+
+// ResetPasswordWithToken consumes a reset token and sets the new password in one transaction.
+func (db *DB) ResetPasswordWithToken(tokenHash string, passwordHash string) (int, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var userID int
+	query := `
+		UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+	err = tx.QueryRow(context.Background(), query, tokenHash).Scan(&userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, ErrInvalidResetToken
+		}
+		return 0, err
+	}
+
+	if err := setUserPassword(tx, userID, passwordHash); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit(context.Background())
+}
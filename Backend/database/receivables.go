@@ -0,0 +1,383 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+var (
+	// ErrPaymentPartyMismatch is returned when allocating a payment to another party's sale.
+	ErrPaymentPartyMismatch = errors.New("sale belongs to another party")
+	// ErrOverAllocated is returned when allocations exceed what is left of the payment.
+	ErrOverAllocated = errors.New("allocations exceed the payment's unallocated amount")
+	// ErrCashbookNeedsCash is returned when asking to post a non-cash payment to the cashbook.
+	ErrCashbookNeedsCash = errors.New("only cash payments can be posted to the cashbook")
+)
+
+// AllocationError is returned when an allocation exceeds what a sale still owes.
+type AllocationError struct {
+	SaleID      int
+	Outstanding float64
+}
+
+func (e *AllocationError) Error() string {
+	return fmt.Sprintf("sale %d has only %.2f outstanding", e.SaleID, e.Outstanding)
+}
+
+// CreateSalePayment records a payment from a party and allocates it to the
+// party's sales; see models.CreateSalePaymentRequest. It returns
+// pgx.ErrNoRows for an unknown party.
+func (db *DB) CreateSalePayment(req *models.CreateSalePaymentRequest, userID int) (*models.SalePayment, error) {
+	if req.PostToCashbook && req.Mode != models.PaymentModeCash {
+		return nil, ErrCashbookNeedsCash
+	}
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var partyName string
+	if err := tx.QueryRow(context.Background(), `SELECT name FROM partners WHERE id = $1`, req.PartyID).Scan(&partyName); err != nil {
+		return nil, err
+	}
+
+	var paymentID int
+	var receiptNumber string
+	query := `
+		INSERT INTO sale_payments (receipt_number, party_id, payment_date, mode, reference, amount, tds_amount, note, created_by_user_id)
+		VALUES ('RCPT-' || LPAD(nextval('payment_receipt_number_seq')::text, 6, '0'), $1, $2, $3, NULLIF($4, ''), $5, $6, NULLIF($7, ''), $8)
+		RETURNING id, receipt_number`
+	err = tx.QueryRow(context.Background(), query, req.PartyID, req.PaymentDate, req.Mode, req.Reference,
+		decimal.NewFromFloat(req.Amount).Round(2).String(), decimal.NewFromFloat(req.TDSAmount).Round(2).String(), req.Note, userID,
+	).Scan(&paymentID, &receiptNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Allocations) > 0 || req.AutoAllocate {
+		if err := allocatePayment(tx, paymentID, req.Allocations, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.PostToCashbook && req.Amount > 0 {
+		// The entry goes in unapproved, like one keyed in the cashbook, and waits
+		// for a second person's sign-off through ApproveCashbookTransaction.
+		var transactionID int
+		err = tx.QueryRow(context.Background(), `
+			INSERT INTO cashbook_transactions (transaction_date, description, cash_in, cash_out, created_by_user_id, approved_by_user_id, approved_at)
+			VALUES ($1, $2, $3, 0, $4, NULL, NULL)
+			RETURNING id`,
+			req.PaymentDate, fmt.Sprintf("Payment %s from %s", receiptNumber, partyName), req.Amount, userID,
+		).Scan(&transactionID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(context.Background(), `UPDATE sale_payments SET cashbook_transaction_id = $1 WHERE id = $2`, transactionID, paymentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return db.GetSalePayment(paymentID)
+}
+
+// AllocatePayment applies what is left of a payment, such as an advance, to
+// sales. Without allocations the party's oldest outstanding sales are
+// settled first. It returns pgx.ErrNoRows for an unknown payment.
+func (db *DB) AllocatePayment(paymentID int, req *models.AllocatePaymentRequest, userID int) (*models.SalePayment, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := allocatePayment(tx, paymentID, req.Allocations, userID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return db.GetSalePayment(paymentID)
+}
+
+// allocatePayment applies allocations of a payment to sales of the same
+// party. With no allocations it settles the party's outstanding sales,
+// oldest first, until the payment is used up.
+func allocatePayment(tx pgx.Tx, paymentID int, allocations []models.PaymentAllocationRequest, userID int) error {
+	var partyID int
+	var availableF float64
+	err := tx.QueryRow(context.Background(), `
+		SELECT party_id, amount + tds_amount - COALESCE((SELECT SUM(amount) FROM sale_payment_allocations WHERE payment_id = sp.id), 0)
+		FROM sale_payments sp WHERE id = $1
+		FOR UPDATE`, paymentID).Scan(&partyID, &availableF)
+	if err != nil {
+		return err
+	}
+	available := decimal.NewFromFloat(availableF)
+
+	if len(allocations) == 0 {
+		rows, err := tx.Query(context.Background(), `
+			SELECT ms.id, ms.total_amount - COALESCE((SELECT SUM(a.amount) FROM sale_payment_allocations a WHERE a.sale_id = ms.id), 0)
+			FROM material_sales ms
+			WHERE ms.party_id = $1 AND ms.cancelled_at IS NULL
+			ORDER BY ms.sale_date, ms.id
+			FOR UPDATE`, partyID)
+		if err != nil {
+			return err
+		}
+		remaining := available
+		for rows.Next() && remaining.IsPositive() {
+			var saleID int
+			var outstanding float64
+			if err := rows.Scan(&saleID, &outstanding); err != nil {
+				rows.Close()
+				return err
+			}
+			if outstanding <= 0 {
+				continue
+			}
+			amount := decimal.Min(remaining, decimal.NewFromFloat(outstanding))
+			allocations = append(allocations, models.PaymentAllocationRequest{SaleID: saleID, Amount: amount.InexactFloat64()})
+			remaining = remaining.Sub(amount)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	allocated := decimal.Zero
+	for _, a := range allocations {
+		amount := decimal.NewFromFloat(a.Amount).Round(2)
+		var saleParty int
+		var cancelled bool
+		var outstanding float64
+		err := tx.QueryRow(context.Background(), `
+			SELECT party_id, cancelled_at IS NOT NULL,
+				total_amount - COALESCE((SELECT SUM(amount) FROM sale_payment_allocations WHERE sale_id = ms.id), 0)
+			FROM material_sales ms WHERE id = $1
+			FOR UPDATE`, a.SaleID).Scan(&saleParty, &cancelled, &outstanding)
+		if err != nil {
+			return err
+		}
+		switch {
+		case saleParty != partyID:
+			return ErrPaymentPartyMismatch
+		case cancelled:
+			return ErrSaleCancelled
+		case amount.GreaterThan(decimal.NewFromFloat(outstanding)):
+			return &AllocationError{SaleID: a.SaleID, Outstanding: outstanding}
+		}
+		allocated = allocated.Add(amount)
+		if allocated.GreaterThan(available) {
+			return ErrOverAllocated
+		}
+		_, err = tx.Exec(context.Background(), `
+			INSERT INTO sale_payment_allocations (payment_id, sale_id, amount, created_by_user_id)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (payment_id, sale_id) DO UPDATE SET amount = sale_payment_allocations.amount + EXCLUDED.amount`,
+			paymentID, a.SaleID, amount.String(), userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const salePaymentSelect = `
+	SELECT sp.id, sp.receipt_number, sp.party_id, p.name, sp.payment_date, sp.mode, sp.reference, sp.amount, sp.tds_amount,
+		COALESCE(al.allocated, 0), sp.note, sp.cashbook_transaction_id, ct.approved_at, u.full_name, sp.created_at
+	FROM sale_payments sp
+	JOIN partners p ON sp.party_id = p.id
+	LEFT JOIN cashbook_transactions ct ON sp.cashbook_transaction_id = ct.id
+	LEFT JOIN users u ON sp.created_by_user_id = u.id
+	LEFT JOIN LATERAL (SELECT SUM(amount) AS allocated FROM sale_payment_allocations WHERE payment_id = sp.id) al ON true`
+
+func scanSalePayment(row pgx.Row) (*models.SalePayment, error) {
+	var p models.SalePayment
+	err := row.Scan(&p.ID, &p.ReceiptNumber, &p.PartyID, &p.PartyName, &p.PaymentDate, &p.Mode, &p.Reference, &p.Amount, &p.TDSAmount,
+		&p.AllocatedAmount, &p.Note, &p.CashbookTransactionID, &p.CashbookApprovedAt, &p.CreatedByName, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.UnallocatedAmount = decimal.NewFromFloat(p.Amount).Add(decimal.NewFromFloat(p.TDSAmount)).Sub(decimal.NewFromFloat(p.AllocatedAmount)).InexactFloat64()
+	return &p, nil
+}
+
+// GetSalePayment returns a payment with its allocations.
+func (db *DB) GetSalePayment(id int) (*models.SalePayment, error) {
+	payment, err := scanSalePayment(db.pool.QueryRow(context.Background(), salePaymentSelect+` WHERE sp.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.pool.Query(context.Background(), `
+		SELECT a.sale_id, ti.invoice_number, ms.sale_date, a.amount
+		FROM sale_payment_allocations a
+		JOIN material_sales ms ON a.sale_id = ms.id
+		LEFT JOIN tax_invoices ti ON ti.sale_id = ms.id
+		WHERE a.payment_id = $1
+		ORDER BY ms.sale_date, ms.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a models.SalePaymentAllocation
+		if err := rows.Scan(&a.SaleID, &a.InvoiceNumber, &a.SaleDate, &a.Amount); err != nil {
+			return nil, err
+		}
+		payment.Allocations = append(payment.Allocations, a)
+	}
+	return payment, rows.Err()
+}
+
+// GetSalePayments lists payments, optionally for one party and dated within a range, newest first.
+func (db *DB) GetSalePayments(partyID *int, from, to *time.Time) ([]models.SalePayment, error) {
+	query := salePaymentSelect + `
+	WHERE ($1::int IS NULL OR sp.party_id = $1)
+		AND ($2::timestamptz IS NULL OR sp.payment_date >= $2) AND ($3::timestamptz IS NULL OR sp.payment_date < $3)
+	ORDER BY sp.payment_date DESC, sp.id DESC`
+	rows, err := db.pool.Query(context.Background(), query, partyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []models.SalePayment
+	for rows.Next() {
+		p, err := scanSalePayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *p)
+	}
+	return payments, rows.Err()
+}
+
+// GetReceivables returns, for every party with an outstanding sale or an
+// advance, what it owes today with outstanding sales aged by sale date.
+func (db *DB) GetReceivables(partyID *int) (*models.ReceivablesReport, error) {
+	query := `
+		WITH dues AS (
+			SELECT ms.party_id, CURRENT_DATE - ms.sale_date AS age,
+				ms.total_amount - COALESCE((SELECT SUM(a.amount) FROM sale_payment_allocations a WHERE a.sale_id = ms.id), 0) AS due
+			FROM material_sales ms
+			WHERE ms.cancelled_at IS NULL
+		), owed AS (
+			SELECT party_id, SUM(due) AS total,
+				COALESCE(SUM(due) FILTER (WHERE age <= 30), 0) AS d30,
+				COALESCE(SUM(due) FILTER (WHERE age BETWEEN 31 AND 60), 0) AS d60,
+				COALESCE(SUM(due) FILTER (WHERE age BETWEEN 61 AND 90), 0) AS d90,
+				COALESCE(SUM(due) FILTER (WHERE age > 90), 0) AS older
+			FROM dues WHERE due > 0
+			GROUP BY party_id
+		), advances AS (
+			SELECT sp.party_id,
+				SUM(sp.amount + sp.tds_amount - COALESCE((SELECT SUM(a.amount) FROM sale_payment_allocations a WHERE a.payment_id = sp.id), 0)) AS advance
+			FROM sale_payments sp
+			GROUP BY sp.party_id
+		)
+		SELECT CURRENT_DATE, p.id, p.name, COALESCE(o.total, 0), COALESCE(o.d30, 0), COALESCE(o.d60, 0), COALESCE(o.d90, 0),
+			COALESCE(o.older, 0), COALESCE(a.advance, 0)
+		FROM partners p
+		LEFT JOIN owed o ON o.party_id = p.id
+		LEFT JOIN advances a ON a.party_id = p.id
+		WHERE (COALESCE(o.total, 0) <> 0 OR COALESCE(a.advance, 0) <> 0) AND ($1::int IS NULL OR p.id = $1)
+		ORDER BY COALESCE(o.total, 0) - COALESCE(a.advance, 0) DESC, p.name`
+	rows, err := db.pool.Query(context.Background(), query, partyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &models.ReceivablesReport{AsOf: time.Now(), Parties: []models.PartyReceivable{}}
+	for rows.Next() {
+		var r models.PartyReceivable
+		if err := rows.Scan(&report.AsOf, &r.PartyID, &r.PartyName, &r.OutstandingTotal, &r.Days0To30, &r.Days31To60,
+			&r.Days61To90, &r.DaysOver90, &r.Advance); err != nil {
+			return nil, err
+		}
+		r.NetBalance = decimal.NewFromFloat(r.OutstandingTotal).Sub(decimal.NewFromFloat(r.Advance)).InexactFloat64()
+		report.Parties = append(report.Parties, r)
+	}
+	return report, rows.Err()
+}
+
+// GetPartyStatement lists the sales billed to a party, the credit notes
+// cancelling them and the payments and TDS received, with a running
+// balance. Movements before from are carried in the opening balance. It
+// returns pgx.ErrNoRows for an unknown party.
+func (db *DB) GetPartyStatement(partyID int, from, to *time.Time) (*models.PartyStatement, error) {
+	st := &models.PartyStatement{PartyID: partyID, From: from, To: to, Lines: []models.StatementLine{}}
+	if err := db.pool.QueryRow(context.Background(), `SELECT name FROM partners WHERE id = $1`, partyID).Scan(&st.PartyName); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT moved_on, type, reference, description, debit, credit FROM (
+			SELECT ms.sale_date AS moved_on, 1 AS seq, ms.id AS ref_id, 'sale' AS type,
+				COALESCE(ti.invoice_number, 'Sale #' || ms.id) AS reference,
+				CONCAT_WS(', ', ie.material, ie.vehicle_number) AS description,
+				ms.total_amount AS debit, 0 AS credit
+			FROM material_sales ms
+			JOIN inward_entries ie ON ms.inward_entry_id = ie.id
+			LEFT JOIN tax_invoices ti ON ti.sale_id = ms.id
+			WHERE ms.party_id = $1
+			UNION ALL
+			SELECT cn.created_at::date, 2, cn.id, 'credit_note', cn.credit_note_number,
+				'Cancels ' || COALESCE(ti.invoice_number, 'Sale #' || ms.id), 0, cn.total_amount
+			FROM sale_credit_notes cn
+			JOIN material_sales ms ON cn.sale_id = ms.id
+			LEFT JOIN tax_invoices ti ON ti.sale_id = ms.id
+			WHERE ms.party_id = $1
+			UNION ALL
+			SELECT payment_date, 3, id, 'payment', receipt_number, CONCAT_WS(' ', mode, reference), 0, amount
+			FROM sale_payments WHERE party_id = $1 AND amount > 0
+			UNION ALL
+			SELECT payment_date, 4, id, 'tds', receipt_number, 'TDS deducted', 0, tds_amount
+			FROM sale_payments WHERE party_id = $1 AND tds_amount > 0
+		) movements
+		WHERE $2::timestamptz IS NULL OR moved_on < $2
+		ORDER BY moved_on, seq, ref_id`
+	rows, err := db.pool.Query(context.Background(), query, partyID, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	opening, balance := decimal.Zero, decimal.Zero
+	totalDebit, totalCredit := decimal.Zero, decimal.Zero
+	for rows.Next() {
+		var line models.StatementLine
+		if err := rows.Scan(&line.Date, &line.Type, &line.Reference, &line.Description, &line.Debit, &line.Credit); err != nil {
+			return nil, err
+		}
+		debit, credit := decimal.NewFromFloat(line.Debit), decimal.NewFromFloat(line.Credit)
+		balance = balance.Add(debit).Sub(credit)
+		if from != nil && line.Date.Before(*from) {
+			opening = balance
+			continue
+		}
+		totalDebit, totalCredit = totalDebit.Add(debit), totalCredit.Add(credit)
+		line.Balance = balance.InexactFloat64()
+		st.Lines = append(st.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	st.OpeningBalance = opening.InexactFloat64()
+	st.TotalDebit, st.TotalCredit = totalDebit.InexactFloat64(), totalCredit.InexactFloat64()
+	st.ClosingBalance = balance.InexactFloat64()
+	return st, nil
+}
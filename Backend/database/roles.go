@@ -0,0 +1,345 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetUserRoleAssignments lists every role a user holds, including site and department scoped ones.
+func (db *DB) GetUserRoleAssignments(userID int) ([]models.RoleAssignment, error) {
+	query := `
+		SELECT ur.role_id, r.name, ur.scope_type, ur.scope_value
+		FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.id
+		WHERE ur.user_id = $1
+		ORDER BY r.name, ur.scope_type, ur.scope_value`
+	rows, err := db.pool.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []models.RoleAssignment
+	for rows.Next() {
+		var a models.RoleAssignment
+		if err := rows.Scan(&a.RoleID, &a.RoleName, &a.ScopeType, &a.ScopeValue); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, nil
+}
+
+// AddUserRoleAssignment grants a role to a user, optionally limited to a scope.
+// Assigning the same role and scope twice is a no-op.
+func (db *DB) AddUserRoleAssignment(userID int, a *models.RoleAssignment) error {
+	query := `
+		INSERT INTO user_roles (user_id, role_id, scope_type, scope_value)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`
+	_, err := db.pool.Exec(context.Background(), query, userID, a.RoleID, a.ScopeType, a.ScopeValue)
+	return err
+}
+
+// RemoveUserRoleAssignment removes one role assignment. It returns pgx.ErrNoRows if it
+// did not exist and ErrLastAdmin if it would leave no administrator.
+func (db *DB) RemoveUserRoleAssignment(userID int, a *models.RoleAssignment) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	checkAdmins, err := guardLastAdmin(tx)
+	if err != nil {
+		return err
+	}
+	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2 AND scope_type = $3 AND scope_value = $4`
+	tag, err := tx.Exec(context.Background(), query, userID, a.RoleID, a.ScopeType, a.ScopeValue)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	if err := checkAdmins(); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// GetEffectivePermissions returns the union of the permissions granted by all of
+// a user's roles, listing for each the roles that grant it.
+func (db *DB) GetEffectivePermissions(userID int) ([]models.EffectivePermission, error) {
+	query := `
+		SELECT p.action, ur.scope_type, ur.scope_value, array_agg(DISTINCT r.name ORDER BY r.name)
+		FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.id
+		JOIN role_permissions rp ON rp.role_id = r.id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1
+		GROUP BY p.action, ur.scope_type, ur.scope_value
+		ORDER BY p.action, ur.scope_type, ur.scope_value`
+	rows, err := db.pool.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []models.EffectivePermission
+	for rows.Next() {
+		var p models.EffectivePermission
+		if err := rows.Scan(&p.Action, &p.ScopeType, &p.ScopeValue, &p.GrantedBy); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, nil
+}
+
+// adminGuardLockKey serialises transactions that can take manage:users away from someone.
+const adminGuardLockKey = 7316230420
+
+// guardLastAdmin must be called at the start of a transaction that can revoke
+// manage:users from a user. The returned check, run just before commit, fails
+// with ErrLastAdmin if the transaction removed the last active global administrator.
+func guardLastAdmin(tx pgx.Tx) (func() error, error) {
+	if _, err := tx.Exec(context.Background(), `SELECT pg_advisory_xact_lock($1)`, adminGuardLockKey); err != nil {
+		return nil, err
+	}
+	before, err := countAdmins(tx)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		after, err := countAdmins(tx)
+		if err != nil {
+			return err
+		}
+		if before > 0 && after == 0 {
+			return ErrLastAdmin
+		}
+		return nil
+	}, nil
+}
+
+func countAdmins(tx pgx.Tx) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(DISTINCT u.id)
+		FROM users u
+		JOIN user_roles ur ON ur.user_id = u.id AND ur.scope_type = ''
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE p.action = 'manage:users' AND u.status = 'active'`
+	err := tx.QueryRow(context.Background(), query).Scan(&count)
+	return count, err
+}
+
+// GetRolePermissionActions lists each action granted by any of the given roles.
+func (db *DB) GetRolePermissionActions(roleIDs []int) ([]string, error) {
+	query := `
+		SELECT DISTINCT p.action
+		FROM role_permissions rp
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE rp.role_id = ANY($1)
+		ORDER BY p.action`
+	rows, err := db.pool.Query(context.Background(), query, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []string
+	for rows.Next() {
+		var action string
+		if err := rows.Scan(&action); err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
+
+// GetRoleByID returns a single role with the number of users holding it.
+func (db *DB) GetRoleByID(roleID int) (*models.Role, error) {
+	var role models.Role
+	query := `
+		SELECT r.id, r.name, r.description, r.template, r.require_two_factor, r.created_at,
+			(SELECT COUNT(DISTINCT ur.user_id) FROM user_roles ur WHERE ur.role_id = r.id)
+		FROM roles r
+		WHERE r.id = $1`
+	err := db.pool.QueryRow(context.Background(), query, roleID).Scan(
+		&role.ID, &role.Name, &role.Description, &role.Template, &role.RequireTwoFactor, &role.CreatedAt, &role.UserCount)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// CreateRole adds a role with the given permission IDs and permission actions.
+// Duplicate names surface as a unique violation from the database.
+func (db *DB) CreateRole(name, description string, template *string, permissionIDs []int, actions []string) (*models.Role, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	role, err := createRole(tx, name, description, template, permissionIDs, actions)
+	if err != nil {
+		return nil, err
+	}
+	return role, tx.Commit(context.Background())
+}
+
+func createRole(tx pgx.Tx, name, description string, template *string, permissionIDs []int, actions []string) (*models.Role, error) {
+	role := models.Role{Name: name, Description: description, Template: template}
+	query := `
+		INSERT INTO roles (name, description, template)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+	if err := tx.QueryRow(context.Background(), query, name, description, template).Scan(&role.ID, &role.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	grantQuery := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, id FROM permissions WHERE id = ANY($2) OR action = ANY($3)
+		ON CONFLICT DO NOTHING`
+	if permissionIDs == nil {
+		permissionIDs = []int{}
+	}
+	if actions == nil {
+		actions = []string{}
+	}
+	if _, err := tx.Exec(context.Background(), grantQuery, role.ID, permissionIDs, actions); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// UpdateRole renames a role or changes its description. It returns pgx.ErrNoRows if the role does not exist.
+func (db *DB) UpdateRole(roleID int, req *models.UpdateRoleRequest) error {
+	query := `UPDATE roles SET name = $1, description = $2 WHERE id = $3`
+	tag, err := db.pool.Exec(context.Background(), query, req.Name, req.Description, roleID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteRole removes a role that no user holds. A role still assigned to users
+// returns a *RoleInUseError; an unknown role pgx.ErrNoRows.
+func (db *DB) DeleteRole(roleID int) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	var id int
+	if err := tx.QueryRow(context.Background(), `SELECT id FROM roles WHERE id = $1 FOR UPDATE`, roleID).Scan(&id); err != nil {
+		return err
+	}
+
+	var users int
+	err = tx.QueryRow(context.Background(), `SELECT COUNT(DISTINCT user_id) FROM user_roles WHERE role_id = $1`, roleID).Scan(&users)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return &RoleInUseError{Users: users}
+	}
+
+	if _, err := tx.Exec(context.Background(), `DELETE FROM roles WHERE id = $1`, roleID); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// CloneRole copies a role's permissions into a new role. It returns pgx.ErrNoRows if the source does not exist.
+func (db *DB) CloneRole(sourceRoleID int, req *models.CloneRoleRequest) (*models.Role, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var sourceDescription string
+	err = tx.QueryRow(context.Background(), `SELECT description FROM roles WHERE id = $1`, sourceRoleID).Scan(&sourceDescription)
+	if err != nil {
+		return nil, err
+	}
+	description := req.Description
+	if description == "" {
+		description = sourceDescription
+	}
+
+	role, err := createRole(tx, req.Name, description, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	copyQuery := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, permission_id FROM role_permissions WHERE role_id = $2`
+	if _, err := tx.Exec(context.Background(), copyQuery, role.ID, sourceRoleID); err != nil {
+		return nil, err
+	}
+	return role, tx.Commit(context.Background())
+}
+
+// SeedRoleTemplates creates each built-in role template the first time it is
+// seen. A template whose name is already taken by an existing role is recorded
+// as seeded without touching that role.
+func (db *DB) SeedRoleTemplates(templates []models.RoleTemplate) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	for _, t := range templates {
+		var seeded bool
+		err := tx.QueryRow(context.Background(), `SELECT EXISTS (SELECT 1 FROM role_template_seeds WHERE template = $1)`, t.Name).Scan(&seeded)
+		if err != nil {
+			return err
+		}
+		if seeded {
+			continue
+		}
+
+		var roleID *int
+		var existingID int
+		err = tx.QueryRow(context.Background(), `SELECT id FROM roles WHERE name = $1`, t.Name).Scan(&existingID)
+		switch {
+		case err == nil:
+			roleID = &existingID
+			log.Printf("Role template %q not seeded: a role with that name already exists\n", t.Name)
+		case errors.Is(err, pgx.ErrNoRows):
+			name := t.Name
+			role, err := createRole(tx, t.Name, t.Description, &name, nil, t.Permissions)
+			if err != nil {
+				return err
+			}
+			roleID = &role.ID
+			log.Printf("Seeded role template %q\n", t.Name)
+		default:
+			return err
+		}
+
+		_, err = tx.Exec(context.Background(), `INSERT INTO role_template_seeds (template, role_id) VALUES ($1, $2)`, t.Name, roleID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(context.Background())
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/solaris-hms/mrf-backend/billing"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+type rowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func queryRoundingRules(q rowsQuerier) ([]models.RoundingRule, error) {
+	rows, err := q.Query(context.Background(), `SELECT scope, places, mode, updated_by_user_id, updated_at FROM rounding_rules ORDER BY scope`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.RoundingRule
+	for rows.Next() {
+		var r models.RoundingRule
+		if err := rows.Scan(&r.Scope, &r.Places, &r.Mode, &r.UpdatedByUserID, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func (db *DB) GetRoundingRules() ([]models.RoundingRule, error) {
+	return queryRoundingRules(db.pool)
+}
+
+// SetRoundingRule changes how one sale figure is rounded. It returns
+// pgx.ErrNoRows for an unknown scope.
+func (db *DB) SetRoundingRule(scope string, req *models.UpdateRoundingRuleRequest, userID int) (*models.RoundingRule, error) {
+	r := models.RoundingRule{Scope: scope}
+	query := `
+		UPDATE rounding_rules SET places = $2, mode = $3, updated_by_user_id = $4, updated_at = NOW()
+		WHERE scope = $1
+		RETURNING places, mode, updated_by_user_id, updated_at`
+	err := db.pool.QueryRow(context.Background(), query, scope, *req.Places, req.Mode, userID).
+		Scan(&r.Places, &r.Mode, &r.UpdatedByUserID, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// deriveSaleAmounts computes the sale's amount, GST and total from its rate,
+// billing weight and GST percentage under the configured rounding rules,
+// after checking the client's figures agree. The request's figures are
+// replaced with the computed ones and the breakdown is returned as JSON for
+// storage with the sale. It must run after deriveSaleWeights.
+func deriveSaleAmounts(tx pgx.Tx, req *models.CreateMaterialSaleRequest) (*billing.SaleAmounts, []byte, error) {
+	configured, err := queryRoundingRules(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules := billing.Rules{}
+	for _, r := range configured {
+		rules[r.Scope] = billing.Rule{Places: r.Places, Mode: r.Mode}
+	}
+
+	// Rate and GST percentage are stored to two places; price what is stored.
+	rate := decimal.NewFromFloat(req.Rate).Round(2)
+	gstPercentage := decimal.NewFromFloat(req.GSTPercentage).Round(2)
+	billingTons := decimal.NewFromFloat(req.BillingWeightTons).Round(3)
+	if rate.IsNegative() || gstPercentage.IsNegative() {
+		return nil, nil, ErrInvalidSaleRate
+	}
+
+	amounts := billing.ComputeSaleAmounts(rate, billingTons, gstPercentage, rules)
+	if err := amounts.Check(req.Amount, req.GSTAmount, req.TotalAmount); err != nil {
+		return nil, nil, err
+	}
+	breakdown, err := json.Marshal(amounts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Rate, req.GSTPercentage = rate.InexactFloat64(), gstPercentage.InexactFloat64()
+	req.Amount = amounts.Amount.InexactFloat64()
+	req.GSTAmount = amounts.GSTAmount.InexactFloat64()
+	req.TotalAmount = amounts.TotalAmount.InexactFloat64()
+	return &amounts, breakdown, nil
+}
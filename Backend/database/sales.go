@@ -0,0 +1,463 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+var (
+	// ErrInvalidDeduction is returned for an unknown deduction type or a deduction larger than the weight.
+	ErrInvalidDeduction = errors.New("invalid deduction")
+	// ErrWithinTolerance is returned when opening a dispute for a buyer figure the tolerance already accepts.
+	ErrWithinTolerance = errors.New("buyer weight is within tolerance")
+	// ErrDisputeAlreadyOpen is returned when a sale already has an unresolved dispute.
+	ErrDisputeAlreadyOpen = errors.New("sale already has an unresolved dispute")
+	// ErrInvalidResolution is returned when resolving without a resolution, or settling without the agreed weight.
+	ErrInvalidResolution = errors.New("a resolution, and for settlements the agreed weight, is required")
+	// ErrEntryNotDispatch is returned when a sale is recorded against an entry that did not leave loaded.
+	ErrEntryNotDispatch = errors.New("only outgoing entries can be sold")
+	// ErrEntryFullyBilled is returned when the uncancelled sales of an entry already bill its net weight.
+	ErrEntryFullyBilled = errors.New("inward entry is already fully billed")
+	// ErrSaleCancelled is returned when cancelling a sale a credit note has already cancelled.
+	ErrSaleCancelled = errors.New("sale is already cancelled")
+	// ErrInvalidSaleRate is returned for a negative rate or GST percentage.
+	ErrInvalidSaleRate = errors.New("rate and GST percentage cannot be negative")
+)
+
+// SaleMismatchError is returned when a figure the client computed for a sale
+// differs from the one the server derives.
+type SaleMismatchError struct {
+	Field     string
+	Expected  float64
+	Submitted float64
+}
+
+func (e *SaleMismatchError) Error() string {
+	return fmt.Sprintf("%s is %.3f, submitted %.3f", e.Field, e.Expected, e.Submitted)
+}
+
+// WeightShareError is returned when a sale's share of a dispatch is not
+// positive or exceeds the weight earlier sales left unbilled.
+type WeightShareError struct {
+	Share        float64
+	UnbilledTons float64
+}
+
+func (e *WeightShareError) Error() string {
+	return fmt.Sprintf("weight share of %.3f t must be positive and at most the %.3f t unbilled", e.Share, e.UnbilledTons)
+}
+
+// DeductionToleranceError is returned when a sale deducts more than the material allows.
+type DeductionToleranceError struct {
+	Material   string
+	Percent    float64
+	MaxPercent float64
+}
+
+func (e *DeductionToleranceError) Error() string {
+	return fmt.Sprintf("deduction of %.2f%% exceeds the %.2f%% allowed for %s", e.Percent, e.MaxPercent, e.Material)
+}
+
+// saleWeightSlack is how far a client-computed weight may differ from the
+// server's figure, in tons, before the sale is rejected.
+const saleWeightSlack = 0.001
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// materialTolerance returns the tolerance row for material, or the default row.
+func materialTolerance(q querier, material string) (*models.MaterialTolerance, error) {
+	var t models.MaterialTolerance
+	query := `
+		SELECT material_name, max_deduction_percent, weight_tolerance_percent, weight_tolerance_kg, updated_by_user_id, updated_at
+		FROM material_tolerances
+		WHERE material_name IN ($1, $2)
+		ORDER BY material_name = $2
+		LIMIT 1`
+	err := q.QueryRow(context.Background(), query, material, models.DefaultToleranceMaterial).Scan(
+		&t.MaterialName, &t.MaxDeductionPercent, &t.WeightTolerancePercent, &t.WeightToleranceKg, &t.UpdatedByUserID, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (db *DB) GetMaterialTolerances() ([]models.MaterialTolerance, error) {
+	query := `
+		SELECT material_name, max_deduction_percent, weight_tolerance_percent, weight_tolerance_kg, updated_by_user_id, updated_at
+		FROM material_tolerances
+		ORDER BY material_name = '*' DESC, material_name`
+	rows, err := db.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tolerances []models.MaterialTolerance
+	for rows.Next() {
+		var t models.MaterialTolerance
+		if err := rows.Scan(&t.MaterialName, &t.MaxDeductionPercent, &t.WeightTolerancePercent, &t.WeightToleranceKg, &t.UpdatedByUserID, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		tolerances = append(tolerances, t)
+	}
+	return tolerances, rows.Err()
+}
+
+// SetMaterialTolerance creates or replaces a material's tolerances. Use "*"
+// for the default.
+func (db *DB) SetMaterialTolerance(material string, req *models.UpdateMaterialToleranceRequest, userID int) (*models.MaterialTolerance, error) {
+	query := `
+		INSERT INTO material_tolerances (material_name, max_deduction_percent, weight_tolerance_percent, weight_tolerance_kg, updated_by_user_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (material_name) DO UPDATE
+		SET max_deduction_percent = EXCLUDED.max_deduction_percent,
+		    weight_tolerance_percent = EXCLUDED.weight_tolerance_percent,
+		    weight_tolerance_kg = EXCLUDED.weight_tolerance_kg,
+		    updated_by_user_id = EXCLUDED.updated_by_user_id,
+		    updated_at = NOW()
+		RETURNING material_name, max_deduction_percent, weight_tolerance_percent, weight_tolerance_kg, updated_by_user_id, updated_at`
+	var t models.MaterialTolerance
+	err := db.pool.QueryRow(context.Background(), query, material, req.MaxDeductionPercent, req.WeightTolerancePercent, req.WeightToleranceKg, userID).Scan(
+		&t.MaterialName, &t.MaxDeductionPercent, &t.WeightTolerancePercent, &t.WeightToleranceKg, &t.UpdatedByUserID, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// deriveSaleWeights replaces the sale's original, deduction and billing
+// weights with figures derived from its share of the inward entry's net
+// weight, after checking the entry is a weighed dispatch with weight left to
+// bill, the client's figures agree and the deduction is within the material's
+// tolerance. It returns the tolerance that applies to the sale.
+func deriveSaleWeights(tx pgx.Tx, req *models.CreateMaterialSaleRequest) (*models.MaterialTolerance, error) {
+	var netKg *float64
+	var material *string
+	var status, entryType string
+	err := tx.QueryRow(context.Background(), `SELECT net_weight, material, status, entry_type FROM inward_entries WHERE id = $1 FOR UPDATE`, req.InwardEntryID).Scan(&netKg, &material, &status, &entryType)
+	if err != nil {
+		return nil, err
+	}
+	if netKg == nil || !slices.Contains(models.InwardWeighedStatuses, status) {
+		return nil, ErrEntryNotWeighed
+	}
+	if !models.IsOutgoingEntryType(entryType) {
+		return nil, ErrEntryNotDispatch
+	}
+	net := models.RoundTons(*netKg / 1000)
+	// Sales recorded before weight shares billed the whole net weight.
+	var billed float64
+	err = tx.QueryRow(context.Background(), `
+		SELECT COALESCE(SUM(COALESCE(original_weight_tons, $2)), 0)
+		FROM material_sales WHERE inward_entry_id = $1 AND cancelled_at IS NULL`, req.InwardEntryID, net).Scan(&billed)
+	if err != nil {
+		return nil, err
+	}
+	unbilled := models.RoundTons(net - billed)
+	if unbilled <= 0 {
+		return nil, ErrEntryFullyBilled
+	}
+	original := unbilled
+	if req.WeightShareTons != nil {
+		original = models.RoundTons(*req.WeightShareTons)
+		if original <= 0 || original > unbilled {
+			return nil, &WeightShareError{Share: original, UnbilledTons: unbilled}
+		}
+	}
+
+	materialName := ""
+	if material != nil {
+		materialName = *material
+	}
+	tolerance, err := materialTolerance(tx, materialName)
+	if err != nil {
+		return nil, err
+	}
+
+	var deduction float64
+	switch req.DeductionType {
+	case "", models.DeductionNone:
+		req.DeductionType, req.DeductionValue = models.DeductionNone, 0
+	case models.DeductionPercentage:
+		deduction = original * req.DeductionValue / 100
+	case models.DeductionFixed:
+		deduction = req.DeductionValue
+	default:
+		return nil, ErrInvalidDeduction
+	}
+	deduction = models.RoundTons(deduction)
+	if req.DeductionValue < 0 || deduction > original {
+		return nil, ErrInvalidDeduction
+	}
+	billing := models.RoundTons(original - deduction)
+
+	checks := []struct {
+		field     string
+		expected  float64
+		submitted float64
+	}{
+		{"original_weight_tons", original, req.OriginalWeightTons},
+		{"deduction_amount", deduction, req.DeductionAmount},
+		{"billing_weight_tons", billing, req.BillingWeightTons},
+	}
+	for _, c := range checks {
+		// Zero means the client left the figure for the server to fill in.
+		if c.submitted != 0 && math.Abs(c.submitted-c.expected) > saleWeightSlack {
+			return nil, &SaleMismatchError{Field: c.field, Expected: c.expected, Submitted: c.submitted}
+		}
+	}
+
+	if original > 0 {
+		percent := deduction / original * 100
+		if percent > tolerance.MaxDeductionPercent+1e-9 {
+			return nil, &DeductionToleranceError{Material: materialName, Percent: percent, MaxPercent: tolerance.MaxDeductionPercent}
+		}
+	}
+
+	req.OriginalWeightTons, req.DeductionAmount, req.BillingWeightTons = original, deduction, billing
+	return tolerance, nil
+}
+
+// openSaleDispute records a dispute when the buyer's figure is outside the
+// allowed variance from ours. It returns ErrWithinTolerance otherwise.
+func openSaleDispute(tx pgx.Tx, saleID int, ourTons, buyerTons float64, tolerance *models.MaterialTolerance, note string, userID int) (int, error) {
+	buyerTons = models.RoundTons(buyerTons)
+	allowed := tolerance.AllowedVarianceTons(ourTons)
+	if math.Abs(buyerTons-ourTons) <= allowed {
+		return 0, ErrWithinTolerance
+	}
+	var unresolved bool
+	err := tx.QueryRow(context.Background(), `SELECT EXISTS (SELECT 1 FROM sale_disputes WHERE sale_id = $1 AND status <> 'resolved')`, saleID).Scan(&unresolved)
+	if err != nil {
+		return 0, err
+	}
+	if unresolved {
+		return 0, ErrDisputeAlreadyOpen
+	}
+
+	var noteArg *string
+	if note = strings.TrimSpace(note); note != "" {
+		noteArg = &note
+	}
+	var disputeID int
+	query := `
+		INSERT INTO sale_disputes (sale_id, our_weight_tons, buyer_weight_tons, tolerance_tons, note, opened_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	err = tx.QueryRow(context.Background(), query, saleID, ourTons, buyerTons, allowed, noteArg, userID).Scan(&disputeID)
+	return disputeID, err
+}
+
+// OpenSaleDispute records a buyer's weighbridge figure reported after the
+// sale was made. It returns pgx.ErrNoRows when the sale does not exist.
+func (db *DB) OpenSaleDispute(saleID int, req *models.OpenSaleDisputeRequest, userID int) (*models.SaleDispute, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var ourTons float64
+	var material *string
+	var cancelled bool
+	query := `
+		SELECT COALESCE(ms.original_weight_tons, ie.net_weight / 1000), ie.material, ms.cancelled_at IS NOT NULL
+		FROM material_sales ms
+		JOIN inward_entries ie ON ms.inward_entry_id = ie.id
+		WHERE ms.id = $1
+		FOR UPDATE OF ms`
+	if err := tx.QueryRow(context.Background(), query, saleID).Scan(&ourTons, &material, &cancelled); err != nil {
+		return nil, err
+	}
+	if cancelled {
+		return nil, ErrSaleCancelled
+	}
+	materialName := ""
+	if material != nil {
+		materialName = *material
+	}
+	tolerance, err := materialTolerance(tx, materialName)
+	if err != nil {
+		return nil, err
+	}
+
+	disputeID, err := openSaleDispute(tx, saleID, ourTons, req.BuyerWeightTons, tolerance, req.Note, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(context.Background(), `UPDATE material_sales SET buyer_weight_tons = $1 WHERE id = $2`, models.RoundTons(req.BuyerWeightTons), saleID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return db.GetSaleDispute(disputeID)
+}
+
+const saleDisputeColumns = `
+	d.id, d.sale_id, ms.inward_entry_id, ie.vehicle_number, ie.material, p.name,
+	d.our_weight_tons, d.buyer_weight_tons, d.tolerance_tons, d.status, d.resolution, d.resolved_weight_tons,
+	d.note, d.resolution_note, ou.full_name, d.opened_at, ru.full_name, d.resolved_at`
+
+const saleDisputeFrom = `
+	FROM sale_disputes d
+	JOIN material_sales ms ON d.sale_id = ms.id
+	JOIN inward_entries ie ON ms.inward_entry_id = ie.id
+	LEFT JOIN partners p ON ms.party_id = p.id
+	LEFT JOIN users ou ON d.opened_by_user_id = ou.id
+	LEFT JOIN users ru ON d.resolved_by_user_id = ru.id`
+
+func scanSaleDispute(row pgx.Row) (*models.SaleDispute, error) {
+	var d models.SaleDispute
+	err := row.Scan(&d.ID, &d.SaleID, &d.InwardEntryID, &d.VehicleNumber, &d.MaterialName, &d.PartyName,
+		&d.OurWeightTons, &d.BuyerWeightTons, &d.ToleranceTons, &d.Status, &d.Resolution, &d.ResolvedWeightTons,
+		&d.Note, &d.ResolutionNote, &d.OpenedByName, &d.OpenedAt, &d.ResolvedByName, &d.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.DifferenceTons = models.RoundTons(d.BuyerWeightTons - d.OurWeightTons)
+	return &d, nil
+}
+
+// GetSaleDisputes lists disputes, newest first, optionally only those in status.
+func (db *DB) GetSaleDisputes(status string) ([]models.SaleDispute, error) {
+	query := `SELECT ` + saleDisputeColumns + saleDisputeFrom + `
+		WHERE $1 = '' OR d.status = $1
+		ORDER BY d.opened_at DESC, d.id DESC`
+	rows, err := db.pool.Query(context.Background(), query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var disputes []models.SaleDispute
+	for rows.Next() {
+		d, err := scanSaleDispute(rows)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, *d)
+	}
+	return disputes, rows.Err()
+}
+
+// GetSaleDispute returns a dispute with its attachments, or pgx.ErrNoRows.
+func (db *DB) GetSaleDispute(disputeID int) (*models.SaleDispute, error) {
+	d, err := scanSaleDispute(db.pool.QueryRow(context.Background(), `SELECT `+saleDisputeColumns+saleDisputeFrom+` WHERE d.id = $1`, disputeID))
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, dispute_id, file_name, original_name, file_size, COALESCE(file_type, ''), file_path, uploaded_by_user_id, uploaded_at
+		FROM sale_dispute_attachments
+		WHERE dispute_id = $1
+		ORDER BY uploaded_at, id`
+	rows, err := db.pool.Query(context.Background(), query, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	d.Attachments = []models.SaleDisputeAttachment{}
+	for rows.Next() {
+		var a models.SaleDisputeAttachment
+		if err := rows.Scan(&a.ID, &a.DisputeID, &a.FileName, &a.OriginalName, &a.FileSize, &a.FileType, &a.FilePath, &a.UploadedByUserID, &a.UploadedAt); err != nil {
+			return nil, err
+		}
+		d.Attachments = append(d.Attachments, a)
+	}
+	return d, rows.Err()
+}
+
+// UpdateSaleDispute moves a dispute under review or resolves it. Resolving
+// fixes the weight both sides agreed on; it does not change the sale itself.
+func (db *DB) UpdateSaleDispute(disputeID int, req *models.UpdateSaleDisputeRequest, userID int) (*models.SaleDispute, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var status string
+	var ourTons, buyerTons float64
+	err = tx.QueryRow(context.Background(), `SELECT status, our_weight_tons, buyer_weight_tons FROM sale_disputes WHERE id = $1 FOR UPDATE`, disputeID).Scan(&status, &ourTons, &buyerTons)
+	if err != nil {
+		return nil, err
+	}
+	if !models.CanTransitionDisputeStatus(status, req.Status) {
+		return nil, &TransitionError{Entity: "dispute", From: status, To: req.Status}
+	}
+
+	var noteArg *string
+	if note := strings.TrimSpace(req.Note); note != "" {
+		noteArg = &note
+	}
+	if req.Status == models.DisputeStatusUnderReview {
+		_, err = tx.Exec(context.Background(), `UPDATE sale_disputes SET status = $1, resolution_note = COALESCE($2, resolution_note) WHERE id = $3`,
+			req.Status, noteArg, disputeID)
+	} else {
+		var resolved float64
+		switch req.Resolution {
+		case models.DisputeResolutionWeightUpheld:
+			resolved = ourTons
+		case models.DisputeResolutionBuyerWeightAccepted:
+			resolved = buyerTons
+		case models.DisputeResolutionSettled:
+			if req.ResolvedWeightTons == nil || *req.ResolvedWeightTons <= 0 {
+				return nil, ErrInvalidResolution
+			}
+			resolved = models.RoundTons(*req.ResolvedWeightTons)
+		default:
+			return nil, ErrInvalidResolution
+		}
+		query := `
+			UPDATE sale_disputes
+			SET status = $1, resolution = $2, resolved_weight_tons = $3, resolution_note = COALESCE($4, resolution_note),
+			    resolved_by_user_id = $5, resolved_at = NOW()
+			WHERE id = $6`
+		_, err = tx.Exec(context.Background(), query, req.Status, req.Resolution, resolved, noteArg, userID, disputeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		return nil, err
+	}
+	return db.GetSaleDispute(disputeID)
+}
+
+func (db *DB) CreateSaleDisputeAttachment(a *models.SaleDisputeAttachment) (*models.SaleDisputeAttachment, error) {
+	query := `
+		INSERT INTO sale_dispute_attachments (dispute_id, file_name, original_name, file_size, file_type, file_path, uploaded_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, uploaded_at`
+	err := db.pool.QueryRow(context.Background(), query, a.DisputeID, a.FileName, a.OriginalName, a.FileSize, a.FileType, a.FilePath, a.UploadedByUserID).Scan(&a.ID, &a.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetSaleDisputeAttachment returns an attachment of the given dispute, or pgx.ErrNoRows.
+func (db *DB) GetSaleDisputeAttachment(disputeID, attachmentID int) (*models.SaleDisputeAttachment, error) {
+	var a models.SaleDisputeAttachment
+	query := `
+		SELECT id, dispute_id, file_name, original_name, file_size, COALESCE(file_type, ''), file_path, uploaded_by_user_id, uploaded_at
+		FROM sale_dispute_attachments
+		WHERE id = $1 AND dispute_id = $2`
+	err := db.pool.QueryRow(context.Background(), query, attachmentID, disputeID).Scan(
+		&a.ID, &a.DisputeID, &a.FileName, &a.OriginalName, &a.FileSize, &a.FileType, &a.FilePath, &a.UploadedByUserID, &a.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or its session was revoked.
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already-rotated refresh token is presented again.
+	// The whole session is revoked before this error is returned.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// CreateSession opens a new login session and stores the hash of its first refresh token.
+func (db *DB) CreateSession(userID int, refreshTokenHash string, expiresAt time.Time, ipAddress, userAgent string) (int, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var sessionID int
+	query := `
+		INSERT INTO user_sessions (user_id, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+	err = tx.QueryRow(context.Background(), query, userID, ipAddress, userAgent, expiresAt).Scan(&sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(context.Background(), `INSERT INTO session_refresh_tokens (session_id, token_hash) VALUES ($1, $2)`, sessionID, refreshTokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return sessionID, tx.Commit(context.Background())
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one within the same session.
+// Presenting a token that was already rotated revokes the session and returns ErrRefreshTokenReused.
+func (db *DB) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (*models.Session, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var tokenID int
+	var usedAt *time.Time
+	var session models.Session
+	query := `
+		SELECT t.id, t.used_at, s.id, s.user_id, s.expires_at, s.revoked_at
+		FROM session_refresh_tokens t
+		JOIN user_sessions s ON t.session_id = s.id
+		WHERE t.token_hash = $1
+		FOR UPDATE OF t, s`
+	err = tx.QueryRow(context.Background(), query, oldHash).Scan(&tokenID, &usedAt, &session.ID, &session.UserID, &session.ExpiresAt, &session.RevokedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if session.RevokedAt != nil || session.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if usedAt != nil {
+		_, err = tx.Exec(context.Background(), `
+			UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = 'Refresh token reuse detected'
+			WHERE id = $1`, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(context.Background()); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(context.Background(), `UPDATE session_refresh_tokens SET used_at = NOW() WHERE id = $1`, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(context.Background(), `INSERT INTO session_refresh_tokens (session_id, token_hash) VALUES ($1, $2)`, session.ID, newHash)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRow(context.Background(), `
+		UPDATE user_sessions SET last_used_at = NOW(), expires_at = $1
+		WHERE id = $2
+		RETURNING last_used_at, expires_at`, expiresAt, session.ID).Scan(&session.LastUsedAt, &session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &session, tx.Commit(context.Background())
+}
+
+// IsSessionActive reports whether the session exists for the user, has been
+// neither revoked nor expired, and the user account itself is still active.
+func (db *DB) IsSessionActive(sessionID int, userID int) (bool, error) {
+	var active bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_sessions s
+			JOIN users u ON s.user_id = u.id
+			WHERE s.id = $1 AND s.user_id = $2 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+				AND u.status = 'active'
+		)`
+	err := db.pool.QueryRow(context.Background(), query, sessionID, userID).Scan(&active)
+	return active, err
+}
+
+// RevokeSession ends a single session, e.g. on logout.
+func (db *DB) RevokeSession(sessionID int, reason string) error {
+	query := `UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = $1 WHERE id = $2 AND revoked_at IS NULL`
+	_, err := db.pool.Exec(context.Background(), query, reason, sessionID)
+	return err
+}
+
+// RevokeUserSessions ends every open session for a user and returns how many were revoked.
+func (db *DB) RevokeUserSessions(userID int, reason string) (int64, error) {
+	query := `UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+	tag, err := db.pool.Exec(context.Background(), query, reason, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetUserSessions lists a user's sessions, newest first.
+func (db *DB) GetUserSessions(userID int) ([]models.Session, error) {
+	query := `
+		SELECT id, user_id, ip_address, user_agent, created_at, last_used_at, expires_at, revoked_at, revoked_reason
+		FROM user_sessions
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 100`
+	rows, err := db.pool.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.IPAddress, &s.UserAgent, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.RevokedAt, &s.RevokedReason); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrInvalidLoginChallenge is returned when a two-factor login challenge is unknown,
+	// used, expired or has had too many wrong codes.
+	ErrInvalidLoginChallenge = errors.New("invalid or expired login challenge")
+	// ErrNoPendingTwoFactor is returned when confirming enrolment that was never started.
+	ErrNoPendingTwoFactor = errors.New("two-factor enrolment has not been started")
+)
+
+// GetTwoFactorState returns the user's TOTP configuration and whether any of their roles requires it.
+func (db *DB) GetTwoFactorState(userID int) (*models.TwoFactorState, error) {
+	var s models.TwoFactorState
+	query := `
+		SELECT u.totp_secret, u.totp_pending_secret, u.totp_enabled_at, u.totp_last_used_step,
+			EXISTS (
+				SELECT 1 FROM user_roles ur JOIN roles r ON ur.role_id = r.id
+				WHERE ur.user_id = u.id AND r.require_two_factor
+			)
+		FROM users u WHERE u.id = $1`
+	err := db.pool.QueryRow(context.Background(), query, userID).Scan(&s.Secret, &s.PendingSecret, &s.EnabledAt, &s.LastUsedStep, &s.Required)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes the user has left.
+func (db *DB) CountUnusedRecoveryCodes(userID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	err := db.pool.QueryRow(context.Background(), query, userID).Scan(&count)
+	return count, err
+}
+
+// SetPendingTOTPSecret stores a secret that becomes active once the user confirms a code from it.
+func (db *DB) SetPendingTOTPSecret(userID int, secret string) error {
+	_, err := db.pool.Exec(context.Background(), `UPDATE users SET totp_pending_secret = $1 WHERE id = $2`, secret, userID)
+	return err
+}
+
+// EnableTOTP activates the pending secret and replaces the user's recovery codes.
+// step is the time step of the code used to confirm, which cannot be reused.
+func (db *DB) EnableTOTP(userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `
+		UPDATE users
+		SET totp_secret = totp_pending_secret, totp_pending_secret = NULL, totp_enabled_at = NOW(), totp_last_used_step = $1
+		WHERE id = $2 AND totp_pending_secret IS NOT NULL`
+	tag, err := tx.Exec(context.Background(), query, step, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoPendingTwoFactor
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// DisableTOTP removes the user's TOTP secret and recovery codes.
+func (db *DB) DisableTOTP(userID int) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	query := `
+		UPDATE users
+		SET totp_secret = NULL, totp_pending_secret = NULL, totp_enabled_at = NULL, totp_last_used_step = NULL
+		WHERE id = $1`
+	if _, err := tx.Exec(context.Background(), query, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(context.Background(), `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes and stores new ones.
+func (db *DB) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+func replaceRecoveryCodes(tx pgx.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec(context.Background(), `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	query := `INSERT INTO user_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`
+	_, err := tx.Exec(context.Background(), query, userID, codeHashes)
+	return err
+}
+
+// UseRecoveryCode consumes a recovery code and reports whether it was valid.
+func (db *DB) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_recovery_codes SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM user_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+			FOR UPDATE
+		)`
+	tag, err := db.pool.Exec(context.Background(), query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// MarkTOTPStepUsed records the time step of an accepted code. It returns false
+// if that step, or a later one, was already used, so each code works only once.
+func (db *DB) MarkTOTPStepUsed(userID int, step int64) (bool, error) {
+	query := `
+		UPDATE users SET totp_last_used_step = $1
+		WHERE id = $2 AND (totp_last_used_step IS NULL OR totp_last_used_step < $1)`
+	tag, err := db.pool.Exec(context.Background(), query, step, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CreateLoginChallenge stores a second-factor challenge issued after a correct password.
+func (db *DB) CreateLoginChallenge(userID int, tokenHash string, expiresAt time.Time) error {
+	query := `INSERT INTO login_challenges (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`
+	_, err := db.pool.Exec(context.Background(), query, userID, tokenHash, expiresAt)
+	return err
+}
+
+// GetLoginChallengeUser returns the user a still-valid challenge belongs to.
+// Challenges with maxFailures wrong codes are no longer valid.
+func (db *DB) GetLoginChallengeUser(tokenHash string, maxFailures int) (int, error) {
+	var userID int
+	query := `
+		SELECT user_id FROM login_challenges
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() AND failed_attempts < $2`
+	err := db.pool.QueryRow(context.Background(), query, tokenHash, maxFailures).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrInvalidLoginChallenge
+	}
+	return userID, err
+}
+
+// RecordLoginChallengeFailure counts a wrong code against the challenge.
+func (db *DB) RecordLoginChallengeFailure(tokenHash string) error {
+	query := `UPDATE login_challenges SET failed_attempts = failed_attempts + 1 WHERE token_hash = $1`
+	_, err := db.pool.Exec(context.Background(), query, tokenHash)
+	return err
+}
+
+// ConsumeLoginChallenge marks a challenge as used. It returns ErrInvalidLoginChallenge
+// if another request used it first.
+func (db *DB) ConsumeLoginChallenge(tokenHash string) error {
+	query := `UPDATE login_challenges SET used_at = NOW() WHERE token_hash = $1 AND used_at IS NULL`
+	tag, err := db.pool.Exec(context.Background(), query, tokenHash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidLoginChallenge
+	}
+	return nil
+}
+
+// SetRoleTwoFactorRequired sets whether holders of the role must use two-factor
+// authentication. It returns pgx.ErrNoRows if the role does not exist.
+func (db *DB) SetRoleTwoFactorRequired(roleID int, required bool) error {
+	tag, err := db.pool.Exec(context.Background(), `UPDATE roles SET require_two_factor = $1 WHERE id = $2`, required, roleID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TransitionUserStatus moves a user to a new lifecycle state and records the
+// change. Disallowed moves return a *TransitionError; unknown users pgx.ErrNoRows;
+// deactivating the last administrator ErrLastAdmin.
+func (db *DB) TransitionUserStatus(userID int, toStatus string, reason string, actorID int) error {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	checkAdmins, err := guardLastAdmin(tx)
+	if err != nil {
+		return err
+	}
+	if err := transitionUserStatus(tx, userID, toStatus, reason, actorID); err != nil {
+		return err
+	}
+	if err := checkAdmins(); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+func transitionUserStatus(tx pgx.Tx, userID int, toStatus string, reason string, actorID int) error {
+	var fromStatus string
+	err := tx.QueryRow(context.Background(), `SELECT status FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&fromStatus)
+	if err != nil {
+		return err
+	}
+	if !models.CanTransitionUserStatus(fromStatus, toStatus) {
+		return &TransitionError{Entity: "user", From: fromStatus, To: toStatus}
+	}
+
+	var reasonArg *string
+	if reason != "" {
+		reasonArg = &reason
+	}
+
+	query := `
+		UPDATE users
+		SET status = $1, status_reason = $2, status_changed_at = NOW(), status_changed_by_user_id = $3,
+			is_approved = (is_approved OR $1 = 'active')
+		WHERE id = $4`
+	if _, err := tx.Exec(context.Background(), query, toStatus, reasonArg, actorID, userID); err != nil {
+		return err
+	}
+
+	historyQuery := `
+		INSERT INTO user_status_history (user_id, from_status, to_status, reason, changed_by_user_id)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err = tx.Exec(context.Background(), historyQuery, userID, fromStatus, toStatus, reasonArg, actorID)
+	return err
+}
+
+// GetUserStatusHistory lists a user's lifecycle changes, newest first.
+func (db *DB) GetUserStatusHistory(userID int) ([]models.UserStatusChange, error) {
+	query := `
+		SELECT h.id, h.user_id, h.from_status, h.to_status, h.reason, h.changed_by_user_id, u.full_name, h.created_at
+		FROM user_status_history h
+		LEFT JOIN users u ON h.changed_by_user_id = u.id
+		WHERE h.user_id = $1
+		ORDER BY h.created_at DESC`
+	rows, err := db.pool.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.UserStatusChange
+	for rows.Next() {
+		var ch models.UserStatusChange
+		if err := rows.Scan(&ch.ID, &ch.UserID, &ch.FromStatus, &ch.ToStatus, &ch.Reason, &ch.ChangedByUserID, &ch.ChangedByName, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, ch)
+	}
+	return changes, nil
+}
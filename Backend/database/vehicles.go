@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+var (
+	// ErrInvalidRegistration is returned when a registration number has no
+	// letters or digits, or more than maxRegistrationLength of them.
+	ErrInvalidRegistration = errors.New("registration number is empty or too long")
+	// ErrNoStoredTare is returned when completing with a stored tare the vehicle does not have, or that has expired.
+	ErrNoStoredTare = errors.New("vehicle has no valid stored tare")
+	// ErrStoredTareNotApplicable is returned when a stored tare cannot stand in for the entry's second weighing.
+	ErrStoredTareNotApplicable = errors.New("stored tare cannot replace this weighing")
+)
+
+// VehicleBlacklistedError is returned when a blacklisted vehicle arrives at the gate.
+type VehicleBlacklistedError struct {
+	RegistrationNumber string
+	Reason             string
+}
+
+func (e *VehicleBlacklistedError) Error() string {
+	return fmt.Sprintf("vehicle %s is blacklisted: %s", e.RegistrationNumber, e.Reason)
+}
+
+const vehicleColumns = `
+	v.id, v.registration_number, v.owner_partner_id, p.name, v.vehicle_type,
+	v.tare_weight, v.tare_valid_until, v.tare_recorded_at,
+	v.is_blacklisted, v.blacklist_reason, v.blacklisted_at, v.created_at, v.updated_at`
+
+const vehicleFrom = `
+	FROM vehicles v
+	LEFT JOIN partners p ON v.owner_partner_id = p.id`
+
+func scanVehicle(row pgx.Row) (*models.Vehicle, error) {
+	var v models.Vehicle
+	var tareKg *float64
+	err := row.Scan(&v.ID, &v.RegistrationNumber, &v.OwnerPartnerID, &v.OwnerName, &v.VehicleType,
+		&tareKg, &v.TareValidUntil, &v.TareRecordedAt,
+		&v.IsBlacklisted, &v.BlacklistReason, &v.BlacklistedAt, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if tareKg != nil {
+		tons := *tareKg / 1000
+		v.TareWeightTons = &tons
+	}
+	return &v, nil
+}
+
+// SearchVehicles returns vehicles whose registration number contains query,
+// ignoring spacing and case, with prefix matches first. An empty query lists
+// vehicles alphabetically.
+func (db *DB) SearchVehicles(query string, limit int) ([]models.Vehicle, error) {
+	sql := `SELECT ` + vehicleColumns + vehicleFrom + `
+		WHERE v.registration_number LIKE '%' || $1 || '%'
+		ORDER BY v.registration_number LIKE $1 || '%' DESC, v.registration_number
+		LIMIT $2`
+	rows, err := db.pool.Query(context.Background(), sql, models.NormalizeVehicleNumber(query), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vehicles []models.Vehicle
+	for rows.Next() {
+		v, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, *v)
+	}
+	return vehicles, rows.Err()
+}
+
+// GetVehicle returns a vehicle by ID, or pgx.ErrNoRows.
+func (db *DB) GetVehicle(vehicleID int) (*models.Vehicle, error) {
+	return scanVehicle(db.pool.QueryRow(context.Background(), `SELECT `+vehicleColumns+vehicleFrom+` WHERE v.id = $1`, vehicleID))
+}
+
+// GetVehicleByRegistration returns a vehicle by registration number in any
+// formatting, or pgx.ErrNoRows.
+func (db *DB) GetVehicleByRegistration(registration string) (*models.Vehicle, error) {
+	return scanVehicle(db.pool.QueryRow(context.Background(), `SELECT `+vehicleColumns+vehicleFrom+` WHERE v.registration_number = $1`,
+		models.NormalizeVehicleNumber(registration)))
+}
+
+// maxRegistrationLength is the size of vehicles.registration_number.
+const maxRegistrationLength = 20
+
+// normalizeRegistration normalizes a registration number and checks that it
+// fits the registry, returning ErrInvalidRegistration if not.
+func normalizeRegistration(registration string) (string, error) {
+	registration = models.NormalizeVehicleNumber(registration)
+	if registration == "" || len(registration) > maxRegistrationLength {
+		return "", ErrInvalidRegistration
+	}
+	return registration, nil
+}
+
+// CreateVehicle registers a vehicle under its normalized registration number.
+func (db *DB) CreateVehicle(req *models.CreateVehicleRequest, userID int) (*models.Vehicle, error) {
+	registration, err := normalizeRegistration(req.RegistrationNumber)
+	if err != nil {
+		return nil, err
+	}
+	var vehicleID int
+	query := `
+		INSERT INTO vehicles (registration_number, owner_partner_id, vehicle_type, created_by_user_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+	if err := db.pool.QueryRow(context.Background(), query, registration, req.OwnerPartnerID, req.VehicleType, userID).Scan(&vehicleID); err != nil {
+		return nil, err
+	}
+	return db.GetVehicle(vehicleID)
+}
+
+// UpdateVehicle changes a vehicle's owner and type. It returns pgx.ErrNoRows
+// when the vehicle does not exist.
+func (db *DB) UpdateVehicle(vehicleID int, req *models.UpdateVehicleRequest) (*models.Vehicle, error) {
+	query := `UPDATE vehicles SET owner_partner_id = $1, vehicle_type = $2, updated_at = NOW() WHERE id = $3`
+	tag, err := db.pool.Exec(context.Background(), query, req.OwnerPartnerID, req.VehicleType, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return db.GetVehicle(vehicleID)
+}
+
+// SetVehicleTare stores a vehicle's empty weight until validUntil (inclusive).
+func (db *DB) SetVehicleTare(vehicleID int, tareTons float64, validUntil time.Time, userID int) (*models.Vehicle, error) {
+	query := `
+		UPDATE vehicles
+		SET tare_weight = $1, tare_valid_until = $2, tare_recorded_at = NOW(), tare_recorded_by_user_id = $3, updated_at = NOW()
+		WHERE id = $4`
+	tag, err := db.pool.Exec(context.Background(), query, tareTons*1000, validUntil, userID, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return db.GetVehicle(vehicleID)
+}
+
+// SetVehicleBlacklist blacklists a vehicle with a reason, or clears the flag.
+func (db *DB) SetVehicleBlacklist(vehicleID int, blacklisted bool, reason string, userID int) (*models.Vehicle, error) {
+	var reasonArg *string
+	var actorArg *int
+	if blacklisted {
+		reasonArg, actorArg = &reason, &userID
+	}
+	query := `
+		UPDATE vehicles
+		SET is_blacklisted = $1, blacklist_reason = $2, blacklisted_by_user_id = $3,
+		    blacklisted_at = CASE WHEN $1 THEN NOW() END, updated_at = NOW()
+		WHERE id = $4`
+	tag, err := db.pool.Exec(context.Background(), query, blacklisted, reasonArg, actorArg, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return db.GetVehicle(vehicleID)
+}
+
+// admitVehicle registers a vehicle on its first visit and refuses blacklisted
+// ones. It returns the vehicle's ID.
+func admitVehicle(tx pgx.Tx, registration string, userID int) (int, error) {
+	registration, err := normalizeRegistration(registration)
+	if err != nil {
+		return 0, err
+	}
+	var vehicleID int
+	var blacklisted bool
+	var reason *string
+	query := `
+		INSERT INTO vehicles (registration_number, created_by_user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (registration_number) DO UPDATE SET registration_number = EXCLUDED.registration_number
+		RETURNING id, is_blacklisted, blacklist_reason`
+	if err := tx.QueryRow(context.Background(), query, registration, userID).Scan(&vehicleID, &blacklisted, &reason); err != nil {
+		return 0, err
+	}
+	if blacklisted {
+		e := &VehicleBlacklistedError{RegistrationNumber: registration}
+		if reason != nil {
+			e.Reason = *reason
+		}
+		return 0, e
+	}
+	return vehicleID, nil
+}
+
+// storedTareKg returns the entry vehicle's stored tare in kilograms when it
+// may replace the second weighing: the vehicle must leave empty, the tare
+// must not have expired and must be lighter than the first weighing.
+func storedTareKg(tx pgx.Tx, vehicleID *int, entryType string, grossWeightKg float64) (float64, error) {
+	if models.IsOutgoingEntryType(entryType) {
+		return 0, ErrStoredTareNotApplicable
+	}
+	if vehicleID == nil {
+		return 0, ErrNoStoredTare
+	}
+	v, err := scanVehicle(tx.QueryRow(context.Background(), `SELECT `+vehicleColumns+vehicleFrom+` WHERE v.id = $1`, *vehicleID))
+	if err != nil {
+		return 0, err
+	}
+	if !v.HasValidTare(time.Now()) {
+		return 0, ErrNoStoredTare
+	}
+	tareKg := *v.TareWeightTons * 1000
+	if tareKg >= grossWeightKg {
+		return 0, ErrStoredTareNotApplicable
+	}
+	return tareKg, nil
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeRegistration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"ts 08 ab-1234", "TS08AB1234", nil},
+		{"12345678901234567890", "12345678901234567890", nil},
+		{"1234-5678-9012-3456-7890", "12345678901234567890", nil},
+		{"123456789012345678901", "", ErrInvalidRegistration},
+		{" - . ", "", ErrInvalidRegistration},
+	}
+	for _, tt := range tests {
+		got, err := normalizeRegistration(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("normalizeRegistration(%q) = %q, %v; want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
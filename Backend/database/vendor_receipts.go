@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// checkVendorApproved fails with ErrVendorNotApproved unless the vendor exists
+// and has been approved.
+func checkVendorApproved(tx pgx.Tx, vendorID string) error {
+	var status string
+	err := tx.QueryRow(context.Background(), `SELECT status FROM vendors WHERE id = $1`, vendorID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVendorNotApproved
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(status, models.VendorStatusApproved) {
+		return ErrVendorNotApproved
+	}
+	return nil
+}
+
+// GetVendorReceipts totals the weighed inward entries linked to a vendor whose
+// second weighing falls in [from, to). A nil bound is open.
+func (db *DB) GetVendorReceipts(vendorID string, from, to *time.Time) (*models.VendorReceipts, error) {
+	summary := models.VendorReceipts{VendorID: vendorID, From: from, To: to, ByMaterial: []models.VendorMaterialReceipt{}}
+	err := db.pool.QueryRow(context.Background(), `SELECT vendor_name FROM vendors WHERE id = $1`, vendorID).Scan(&summary.VendorName)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT COALESCE(ie.material, ie.entry_type) AS material,
+		       COUNT(*), COALESCE(SUM(ie.gross_weight), 0), COALESCE(SUM(ie.net_weight), 0)
+		FROM inward_entries ie
+		WHERE ie.vendor_id = $1
+		  AND ie.status = ANY($2)
+		  AND ($3::timestamptz IS NULL OR ie.completed_at >= $3)
+		  AND ($4::timestamptz IS NULL OR ie.completed_at < $4)
+		GROUP BY 1
+		ORDER BY 1`
+	rows, err := db.pool.Query(context.Background(), query, vendorID, models.InwardWeighedStatuses, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.VendorMaterialReceipt
+		var grossKg, netKg float64
+		if err := rows.Scan(&m.Material, &m.Trips, &grossKg, &netKg); err != nil {
+			return nil, err
+		}
+		m.GrossWeightTons = grossKg / 1000
+		m.NetWeightTons = netKg / 1000
+		summary.Trips += m.Trips
+		summary.GrossWeightTons += m.GrossWeightTons
+		summary.NetWeightTons += m.NetWeightTons
+		summary.ByMaterial = append(summary.ByMaterial, m)
+	}
+	return &summary, rows.Err()
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// ErrEntryNotWeighed is returned when a slip is requested for an entry that
+// has not had its second weighing, or was cancelled.
+var ErrEntryNotWeighed = errors.New("inward entry has not been weighed out")
+
+// PrintWeighmentSlip numbers the entry's slip on its first print and counts
+// every print after that, then returns the slip as it should be printed.
+// It returns pgx.ErrNoRows when the entry does not exist.
+func (db *DB) PrintWeighmentSlip(entryID int, userID int) (*models.WeighmentSlip, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var status string
+	err = tx.QueryRow(context.Background(), `SELECT status FROM inward_entries WHERE id = $1 FOR UPDATE`, entryID).Scan(&status)
+	if err != nil {
+		return nil, err
+	}
+	weighed := false
+	for _, s := range models.InwardWeighedStatuses {
+		weighed = weighed || s == status
+	}
+	if !weighed {
+		return nil, ErrEntryNotWeighed
+	}
+
+	// The entry row lock serialises prints of one slip, and numbering only on
+	// insert means reprints never consume a number.
+	tag, err := tx.Exec(context.Background(), `
+		UPDATE weighment_slips
+		SET print_count = print_count + 1, last_printed_by_user_id = $2, last_printed_at = NOW()
+		WHERE inward_entry_id = $1`, entryID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		token := make([]byte, 24)
+		if _, err := rand.Read(token); err != nil {
+			return nil, err
+		}
+		// The counter row is locked until commit, so first prints queue up and a
+		// rolled back print releases its number: slip numbers run without gaps.
+		var seq int
+		err = tx.QueryRow(context.Background(), `
+			INSERT INTO weighment_slip_number_counters (prefix, last_number) VALUES ('WS', 1)
+			ON CONFLICT (prefix) DO UPDATE SET last_number = weighment_slip_number_counters.last_number + 1
+			RETURNING last_number`).Scan(&seq)
+		if err != nil {
+			return nil, err
+		}
+		query := `
+			INSERT INTO weighment_slips (inward_entry_id, slip_number, verification_token, print_count, first_printed_by_user_id, last_printed_by_user_id)
+			VALUES ($1, $2, $3, 1, $4, $4)`
+		if _, err := tx.Exec(context.Background(), query, entryID, fmt.Sprintf("WS-%06d", seq), hex.EncodeToString(token), userID); err != nil {
+			return nil, err
+		}
+	}
+
+	slip, err := getWeighmentSlip(tx, `ws.inward_entry_id = $1`, entryID)
+	if err != nil {
+		return nil, err
+	}
+	return slip, tx.Commit(context.Background())
+}
+
+// GetWeighmentSlipByToken looks up a printed slip from the token in its QR code.
+// It returns pgx.ErrNoRows for unknown tokens.
+func (db *DB) GetWeighmentSlipByToken(token string) (*models.WeighmentSlip, string, error) {
+	tx, err := db.pool.Begin(context.Background())
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback(context.Background())
+
+	slip, err := getWeighmentSlip(tx, `ws.verification_token = $1`, token)
+	if err != nil {
+		return nil, "", err
+	}
+	var status string
+	if err := tx.QueryRow(context.Background(), `SELECT status FROM inward_entries WHERE id = $1`, slip.InwardEntryID).Scan(&status); err != nil {
+		return nil, "", err
+	}
+	return slip, status, nil
+}
+
+// getWeighmentSlip loads a slip and the entry it belongs to. The heavier of
+// the two weighings is reported as gross, so outgoing vehicles that arrive
+// empty print the same way as incoming ones.
+func getWeighmentSlip(tx pgx.Tx, where string, arg any) (*models.WeighmentSlip, error) {
+	query := `
+		SELECT ws.slip_number, ws.verification_token, ws.print_count, ws.first_printed_at, ws.last_printed_at,
+		       ie.id, ie.vehicle_number, ie.entry_type, ie.material,
+		       COALESCE(s.name, d.name), p.name, v.vendor_name,
+		       ie.gross_weight, COALESCE(ie.tare_weight, 0), COALESCE(ie.net_weight, 0),
+		       ie.gross_weight_manual, COALESCE(ie.tare_weight_manual, false), ie.tare_weight_stored,
+		       ie.created_at, ie.completed_at,
+		       cu.full_name,
+		       (SELECT u.full_name FROM inward_entry_status_history h
+		        JOIN users u ON h.changed_by_user_id = u.id
+		        WHERE h.inward_entry_id = ie.id AND h.to_status = 'second_weighed'
+		        ORDER BY h.created_at DESC LIMIT 1),
+		       pu.full_name
+		FROM weighment_slips ws
+		JOIN inward_entries ie ON ws.inward_entry_id = ie.id
+		LEFT JOIN partners s ON ie.source_id = s.id
+		LEFT JOIN partners d ON ie.destination_id = d.id
+		LEFT JOIN partners p ON ie.party_id = p.id
+		LEFT JOIN vendors v ON ie.vendor_id = v.id
+		LEFT JOIN users cu ON ie.created_by_user_id = cu.id
+		LEFT JOIN users pu ON ws.last_printed_by_user_id = pu.id
+		WHERE ` + where
+
+	var slip models.WeighmentSlip
+	var firstKg, secondKg, netKg float64
+	err := tx.QueryRow(context.Background(), query, arg).Scan(
+		&slip.SlipNumber, &slip.VerificationToken, &slip.PrintCount, &slip.FirstPrintedAt, &slip.LastPrintedAt,
+		&slip.InwardEntryID, &slip.VehicleNumber, &slip.EntryType, &slip.Material,
+		&slip.SourceName, &slip.PartyName, &slip.VendorName,
+		&firstKg, &secondKg, &netKg,
+		&slip.GrossWeightManual, &slip.TareWeightManual, &slip.TareWeightStored,
+		&slip.FirstWeighedAt, &slip.SecondWeighedAt,
+		&slip.FirstOperator, &slip.SecondOperator, &slip.PrintedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	slip.GrossWeightTons, slip.TareWeightTons = firstKg/1000, secondKg/1000
+	if secondKg > firstKg {
+		slip.GrossWeightTons, slip.TareWeightTons = slip.TareWeightTons, slip.GrossWeightTons
+		slip.GrossWeightManual, slip.TareWeightManual = slip.TareWeightManual, slip.GrossWeightManual
+	}
+	slip.NetWeightTons = netKg / 1000
+	return &slip, nil
+}
@@ -0,0 +1,114 @@
+// Package documents renders the printable PDFs handed to drivers, parties and
+// customers.
+package documents
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// Letterhead identifies the plant at the top of every document.
+type Letterhead struct {
+	Name    string
+	Address string
+	Phone   string
+}
+
+// LetterheadFromEnv reads DOCUMENT_ORG_NAME, DOCUMENT_ORG_ADDRESS and
+// DOCUMENT_ORG_PHONE. The name falls back to "MRF".
+func LetterheadFromEnv() Letterhead {
+	lh := Letterhead{
+		Name:    os.Getenv("DOCUMENT_ORG_NAME"),
+		Address: os.Getenv("DOCUMENT_ORG_ADDRESS"),
+		Phone:   os.Getenv("DOCUMENT_ORG_PHONE"),
+	}
+	if lh.Name == "" {
+		lh.Name = "MRF"
+	}
+	return lh
+}
+
+// page wraps a gofpdf document with the translator needed for the built-in
+// fonts, which only cover Windows-1252.
+type page struct {
+	*gofpdf.Fpdf
+	tr func(string) string
+}
+
+func newPage(size string) *page {
+	pdf := gofpdf.New("P", "mm", size, "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetAutoPageBreak(true, 12)
+	pdf.SetCreator("MRF", false)
+	return &page{Fpdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+}
+
+// letterhead prints the plant name, address and a rule beneath them.
+func (p *page) letterhead(lh Letterhead, title string) {
+	width, _ := p.GetPageSize()
+	left, _, right, _ := p.GetMargins()
+	p.SetFont("Helvetica", "B", 14)
+	p.CellFormat(0, 7, p.tr(lh.Name), "", 1, "C", false, 0, "")
+	p.SetFont("Helvetica", "", 8)
+	if lh.Address != "" {
+		p.MultiCell(0, 4, p.tr(lh.Address), "", "C", false)
+	}
+	if lh.Phone != "" {
+		p.CellFormat(0, 4, p.tr("Phone: "+lh.Phone), "", 1, "C", false, 0, "")
+	}
+	p.Ln(1)
+	p.SetFont("Helvetica", "B", 11)
+	p.CellFormat(0, 6, p.tr(title), "", 1, "C", false, 0, "")
+	y := p.GetY() + 1
+	p.Line(left, y, width-right, y)
+	p.SetY(y + 2)
+}
+
+// row prints a label and its value on one line.
+func (p *page) row(label, value string, labelWidth float64) {
+	p.SetFont("Helvetica", "B", 9)
+	p.CellFormat(labelWidth, 6, p.tr(label), "", 0, "L", false, 0, "")
+	p.SetFont("Helvetica", "", 9)
+	p.CellFormat(0, 6, p.tr(value), "", 1, "L", false, 0, "")
+}
+
+// watermark stamps large, light, diagonal text across the page.
+func (p *page) watermark(text string) {
+	width, height := p.GetPageSize()
+	p.SetFont("Helvetica", "B", 48)
+	p.SetTextColor(200, 200, 200)
+	p.TransformBegin()
+	p.TransformRotate(35, width/2, height/2)
+	textWidth := p.GetStringWidth(text)
+	p.Text(width/2-textWidth/2, height/2, text)
+	p.TransformEnd()
+	p.SetTextColor(0, 0, 0)
+}
+
+// qrCode draws a QR code for content with its top-left corner at x, y.
+func (p *page) qrCode(name, content string, x, y, size float64) error {
+	png, err := qrcode.Encode(content, qrcode.Medium, 256)
+	if err != nil {
+		return err
+	}
+	opts := gofpdf.ImageOptions{ImageType: "PNG"}
+	p.RegisterImageOptionsReader(name, opts, bytes.NewReader(png))
+	p.ImageOptions(name, x, y, size, size, false, opts, 0, "")
+	return nil
+}
+
+func (p *page) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format("02 Jan 2006 15:04")
+}
@@ -0,0 +1,87 @@
+package documents
+
+import (
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+var statementTypeLabels = map[string]string{
+	models.StatementSale:       "Sale",
+	models.StatementCreditNote: "Credit note",
+	models.StatementPayment:    "Payment",
+	models.StatementTDS:        "TDS",
+}
+
+// PartyStatement renders a party's account statement on A4 pages.
+func PartyStatement(lh Letterhead, st *models.PartyStatement) ([]byte, error) {
+	p := newPage("A4")
+	p.AddPage()
+	p.letterhead(lh, "STATEMENT OF ACCOUNT")
+
+	const labelWidth = 32
+	p.row("Party", st.PartyName, labelWidth)
+	period := "All transactions"
+	switch {
+	case st.From != nil && st.To != nil:
+		// To is exclusive; show the last day it covers.
+		period = st.From.Format("02 Jan 2006") + " to " + st.To.AddDate(0, 0, -1).Format("02 Jan 2006")
+	case st.From != nil:
+		period = "From " + st.From.Format("02 Jan 2006")
+	case st.To != nil:
+		period = "Up to " + st.To.AddDate(0, 0, -1).Format("02 Jan 2006")
+	}
+	p.row("Period", period, labelWidth)
+	p.Ln(2)
+
+	widths := []float64{22, 22, 34, 52, 20, 20, 20}
+	headings := []string{"Date", "Type", "Reference", "Description", "Debit", "Credit", "Balance"}
+	p.SetFont("Helvetica", "B", 8)
+	p.SetFillColor(235, 235, 235)
+	for i, heading := range headings {
+		align := "L"
+		if i >= 4 {
+			align = "R"
+		}
+		p.CellFormat(widths[i], 6, heading, "1", 0, align, true, 0, "")
+	}
+	p.Ln(-1)
+
+	line := func(cells []string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		p.SetFont("Helvetica", style, 8)
+		for i, cell := range cells {
+			align := "L"
+			if i >= 4 {
+				align = "R"
+			}
+			// Long descriptions are cut to the column rather than wrapped.
+			text := p.tr(cell)
+			for text != "" && p.GetStringWidth(text) > widths[i]-2 {
+				text = text[:len(text)-1]
+			}
+			p.CellFormat(widths[i], 6, text, "1", 0, align, false, 0, "")
+		}
+		p.Ln(-1)
+	}
+	line([]string{"", "", "", "Opening balance", "", "", money(st.OpeningBalance)}, true)
+	for _, l := range st.Lines {
+		line([]string{l.Date.Format("02-01-2006"), statementTypeLabels[l.Type], l.Reference, l.Description,
+			blankZero(l.Debit), blankZero(l.Credit), money(l.Balance)}, false)
+	}
+	line([]string{"", "", "", "Total", money(st.TotalDebit), money(st.TotalCredit), ""}, true)
+	line([]string{"", "", "", "Closing balance", "", "", money(st.ClosingBalance)}, true)
+
+	p.Ln(3)
+	p.SetFont("Helvetica", "I", 7)
+	p.MultiCell(0, 3.5, "A positive balance is payable by the party; a negative balance is an advance held for the party.", "", "L", false)
+	return p.bytes()
+}
+
+func blankZero(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return money(v)
+}
@@ -0,0 +1,117 @@
+package documents
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/solaris-hms/mrf-backend/billing"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// TaxInvoice renders a GST tax invoice on an A4 page. Invoices whose sale
+// has been cancelled carry a CANCELLED watermark and the credit note number.
+func TaxInvoice(lh Letterhead, inv *models.TaxInvoice) ([]byte, error) {
+	p := newPage("A4")
+	p.AddPage()
+	if inv.CreditNoteNumber != nil {
+		p.watermark("CANCELLED")
+	}
+	lh.Name = inv.SellerName
+	p.letterhead(lh, "TAX INVOICE")
+
+	const labelWidth = 36
+	p.row("GSTIN", inv.SellerGSTIN, labelWidth)
+	p.row("Invoice No.", inv.InvoiceNumber, labelWidth)
+	p.row("Invoice Date", inv.InvoiceDate.Format("02 Jan 2006"), labelWidth)
+	p.row("Place of Supply", billing.StateLabel(inv.PlaceOfSupply), labelWidth)
+	if inv.VehicleNumber != nil {
+		p.row("Vehicle No.", *inv.VehicleNumber, labelWidth)
+	}
+	if inv.CreditNoteNumber != nil {
+		p.row("Cancelled by", "Credit note "+*inv.CreditNoteNumber, labelWidth)
+	}
+	p.Ln(2)
+
+	p.SetFont("Helvetica", "B", 9)
+	p.CellFormat(0, 6, "Bill To", "B", 1, "L", false, 0, "")
+	p.CellFormat(0, 5, p.tr(inv.BuyerName), "", 1, "L", false, 0, "")
+	p.SetFont("Helvetica", "", 9)
+	if inv.BuyerAddress != nil {
+		p.MultiCell(0, 4.5, p.tr(*inv.BuyerAddress), "", "L", false)
+	}
+	if place := strings.TrimSpace(valueOr(inv.BuyerLocation, "") + " " + valueOr(inv.BuyerPincode, "")); place != "" {
+		p.CellFormat(0, 4.5, p.tr(place), "", 1, "L", false, 0, "")
+	}
+	p.CellFormat(0, 4.5, p.tr("GSTIN: "+valueOr(inv.BuyerGSTIN, "Unregistered")), "", 1, "L", false, 0, "")
+	p.Ln(3)
+
+	// Item table.
+	widths := []float64{8, 62, 20, 24, 30, 46}
+	p.SetFont("Helvetica", "B", 9)
+	p.SetFillColor(235, 235, 235)
+	for i, heading := range []string{"#", "Description", "HSN", "Qty (MT)", "Rate / MT", "Taxable Value"} {
+		align := "R"
+		if i < 3 {
+			align = "L"
+		}
+		p.CellFormat(widths[i], 7, heading, "1", 0, align, true, 0, "")
+	}
+	p.Ln(-1)
+	p.SetFont("Helvetica", "", 9)
+	for i, cell := range []string{"1", inv.Material, inv.HSNCode, fmt.Sprintf("%.3f", inv.QuantityTons), money(inv.RatePerTon), money(inv.TaxableValue)} {
+		align := "R"
+		if i < 3 {
+			align = "L"
+		}
+		p.CellFormat(widths[i], 7, p.tr(cell), "1", 0, align, false, 0, "")
+	}
+	p.Ln(-1)
+
+	// Tax summary, aligned under the last two columns.
+	summaryLeft := widths[0] + widths[1] + widths[2] + widths[3]
+	summary := func(label, amount string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		p.SetFont("Helvetica", style, 9)
+		p.CellFormat(summaryLeft, 7, "", "", 0, "L", false, 0, "")
+		p.CellFormat(widths[4], 7, p.tr(label), "1", 0, "L", false, 0, "")
+		p.CellFormat(widths[5], 7, amount, "1", 1, "R", false, 0, "")
+	}
+	summary("Taxable Value", money(inv.TaxableValue), false)
+	if inv.Interstate {
+		summary(fmt.Sprintf("IGST @ %g%%", inv.IGSTRate), money(inv.IGSTAmount), false)
+	} else {
+		summary(fmt.Sprintf("CGST @ %g%%", inv.CGSTRate), money(inv.CGSTAmount), false)
+		summary(fmt.Sprintf("SGST @ %g%%", inv.SGSTRate), money(inv.SGSTAmount), false)
+	}
+	if inv.RoundOff != 0 {
+		summary("Round Off", money(inv.RoundOff), false)
+	}
+	summary("Invoice Total", money(inv.TotalAmount), true)
+	p.Ln(3)
+
+	p.SetFont("Helvetica", "B", 9)
+	p.CellFormat(0, 5, "Amount in words", "", 1, "L", false, 0, "")
+	p.SetFont("Helvetica", "", 9)
+	p.MultiCell(0, 4.5, p.tr(billing.AmountInWords(decimal.NewFromFloat(inv.TotalAmount))), "", "L", false)
+
+	width, _ := p.GetPageSize()
+	_, _, right, _ := p.GetMargins()
+	p.Ln(6)
+	p.SetFont("Helvetica", "B", 9)
+	p.SetX(width - right - 70)
+	p.CellFormat(70, 5, p.tr("For "+inv.SellerName), "", 1, "C", false, 0, "")
+	p.Ln(12)
+	p.SetFont("Helvetica", "", 8)
+	p.SetX(width - right - 70)
+	p.CellFormat(70, 5, "Authorised Signatory", "T", 1, "C", false, 0, "")
+
+	return p.bytes()
+}
+
+func money(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
@@ -0,0 +1,111 @@
+package documents
+
+import (
+	"fmt"
+
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// WeighmentSlip renders a slip for a weighed inward entry on an A5 page. The
+// QR code encodes verifyURL so the party can check the slip against the
+// server; reprints carry a DUPLICATE watermark.
+func WeighmentSlip(lh Letterhead, slip *models.WeighmentSlip, verifyURL string) ([]byte, error) {
+	p := newPage("A5")
+	p.AddPage()
+	if slip.IsDuplicate() {
+		p.watermark("DUPLICATE")
+	}
+	p.letterhead(lh, "WEIGHMENT SLIP")
+
+	width, _ := p.GetPageSize()
+	_, _, right, _ := p.GetMargins()
+	const qrSize = 30
+	top := p.GetY()
+	if err := p.qrCode("verify", verifyURL, width-right-qrSize, top, qrSize); err != nil {
+		return nil, err
+	}
+
+	const labelWidth = 32
+	p.row("Slip No.", slip.SlipNumber, labelWidth)
+	p.row("Entry No.", fmt.Sprintf("%d", slip.InwardEntryID), labelWidth)
+	p.row("Vehicle No.", slip.VehicleNumber, labelWidth)
+	p.row("Entry Type", slip.EntryType, labelWidth)
+	p.row("Material", valueOr(slip.Material, "-"), labelWidth)
+	if slip.SourceName != nil {
+		p.row("Source", *slip.SourceName, labelWidth)
+	}
+	if slip.PartyName != nil {
+		p.row("Party", *slip.PartyName, labelWidth)
+	}
+	if slip.VendorName != nil {
+		p.row("Vendor", *slip.VendorName, labelWidth)
+	}
+	if p.GetY() < top+qrSize {
+		p.SetY(top + qrSize)
+	}
+	p.Ln(3)
+
+	// Weights table.
+	p.SetFont("Helvetica", "B", 9)
+	p.SetFillColor(235, 235, 235)
+	p.CellFormat(34, 7, "", "1", 0, "L", true, 0, "")
+	p.CellFormat(34, 7, "Weight (kg)", "1", 0, "R", true, 0, "")
+	p.CellFormat(0, 7, "Captured", "1", 1, "C", true, 0, "")
+	weightRow := func(label string, tons float64, manual *bool, stored bool) {
+		p.SetFont("Helvetica", "B", 9)
+		p.CellFormat(34, 7, label, "1", 0, "L", false, 0, "")
+		p.SetFont("Helvetica", "", 9)
+		p.CellFormat(34, 7, fmt.Sprintf("%.0f", tons*1000), "1", 0, "R", false, 0, "")
+		captured := ""
+		if manual != nil {
+			captured = "Weighbridge"
+			if *manual {
+				captured = "Manual entry"
+			}
+		}
+		if stored {
+			captured = "Stored tare"
+		}
+		p.CellFormat(0, 7, captured, "1", 1, "C", false, 0, "")
+	}
+	weightRow("Gross", slip.GrossWeightTons, &slip.GrossWeightManual, false)
+	weightRow("Tare", slip.TareWeightTons, &slip.TareWeightManual, slip.TareWeightStored)
+	weightRow("Net", slip.NetWeightTons, nil, false)
+	p.Ln(3)
+
+	p.row("First Weighing", formatTime(slip.FirstWeighedAt)+operatorSuffix(slip.FirstOperator), labelWidth)
+	if slip.SecondWeighedAt != nil {
+		p.row("Second Weighing", formatTime(*slip.SecondWeighedAt)+operatorSuffix(slip.SecondOperator), labelWidth)
+	}
+	printed := formatTime(slip.LastPrintedAt) + operatorSuffix(slip.PrintedBy)
+	if slip.IsDuplicate() {
+		printed += fmt.Sprintf(" (copy %d, original %s)", slip.PrintCount, formatTime(slip.FirstPrintedAt))
+	}
+	p.row("Printed", printed, labelWidth)
+
+	p.Ln(12)
+	p.SetFont("Helvetica", "", 8)
+	p.CellFormat(60, 5, "Driver's Signature", "T", 0, "C", false, 0, "")
+	p.CellFormat(0, 5, "", "", 0, "C", false, 0, "")
+	p.SetX(width - right - 60)
+	p.CellFormat(60, 5, "Weighbridge Operator", "T", 1, "C", false, 0, "")
+	p.Ln(2)
+	p.SetFont("Helvetica", "I", 7)
+	p.MultiCell(0, 3.5, p.tr("Scan the QR code or open "+verifyURL+" to verify this slip."), "", "L", false)
+
+	return p.bytes()
+}
+
+func valueOr(s *string, fallback string) string {
+	if s == nil || *s == "" {
+		return fallback
+	}
+	return *s
+}
+
+func operatorSuffix(name *string) string {
+	if name == nil || *name == "" {
+		return ""
+	}
+	return " by " + *name
+}
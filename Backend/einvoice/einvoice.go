@@ -0,0 +1,158 @@
+// Package einvoice converts tax invoices to the government e-invoice JSON
+// schema (version 1.1) for upload to the invoice registration portal.
+package einvoice
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// ErrNotB2B is returned for invoices to buyers without a GSTIN, which are
+// outside the e-invoice scheme.
+var ErrNotB2B = errors.New("e-invoices are only issued to registered buyers")
+
+// Unit quantity code for metric tons.
+const unitMetricTons = "MTS"
+
+// Document is one e-invoice. Field names follow the schema.
+type Document struct {
+	Version    string   `json:"Version"`
+	TranDtls   TranDtls `json:"TranDtls"`
+	DocDtls    DocDtls  `json:"DocDtls"`
+	SellerDtls Party    `json:"SellerDtls"`
+	BuyerDtls  Buyer    `json:"BuyerDtls"`
+	ItemList   []Item   `json:"ItemList"`
+	ValDtls    ValDtls  `json:"ValDtls"`
+}
+
+type TranDtls struct {
+	TaxSch      string `json:"TaxSch"`
+	SupTyp      string `json:"SupTyp"`
+	RegRev      string `json:"RegRev"`
+	IgstOnIntra string `json:"IgstOnIntra"`
+}
+
+type DocDtls struct {
+	Typ string `json:"Typ"`
+	No  string `json:"No"`
+	Dt  string `json:"Dt"`
+}
+
+type Party struct {
+	Gstin string `json:"Gstin"`
+	LglNm string `json:"LglNm"`
+	Addr1 string `json:"Addr1"`
+	Loc   string `json:"Loc"`
+	Pin   int    `json:"Pin"`
+	Stcd  string `json:"Stcd"`
+}
+
+type Buyer struct {
+	Party
+	// Pos is the place of supply's state code.
+	Pos string `json:"Pos"`
+}
+
+type Item struct {
+	SlNo       string  `json:"SlNo"`
+	PrdDesc    string  `json:"PrdDesc"`
+	IsServc    string  `json:"IsServc"`
+	HsnCd      string  `json:"HsnCd"`
+	Qty        float64 `json:"Qty"`
+	Unit       string  `json:"Unit"`
+	UnitPrice  float64 `json:"UnitPrice"`
+	TotAmt     float64 `json:"TotAmt"`
+	AssAmt     float64 `json:"AssAmt"`
+	GstRt      float64 `json:"GstRt"`
+	IgstAmt    float64 `json:"IgstAmt"`
+	CgstAmt    float64 `json:"CgstAmt"`
+	SgstAmt    float64 `json:"SgstAmt"`
+	TotItemVal float64 `json:"TotItemVal"`
+}
+
+type ValDtls struct {
+	AssVal    float64 `json:"AssVal"`
+	CgstVal   float64 `json:"CgstVal"`
+	SgstVal   float64 `json:"SgstVal"`
+	IgstVal   float64 `json:"IgstVal"`
+	RndOffAmt float64 `json:"RndOffAmt"`
+	TotInvVal float64 `json:"TotInvVal"`
+}
+
+// FromTaxInvoice builds the e-invoice for a B2B tax invoice.
+func FromTaxInvoice(inv *models.TaxInvoice) (*Document, error) {
+	if inv.BuyerGSTIN == nil {
+		return nil, ErrNotB2B
+	}
+	tax := inv.CGSTAmount + inv.SGSTAmount + inv.IGSTAmount
+	return &Document{
+		Version:  "1.1",
+		TranDtls: TranDtls{TaxSch: "GST", SupTyp: "B2B", RegRev: "N", IgstOnIntra: "N"},
+		DocDtls:  DocDtls{Typ: "INV", No: inv.InvoiceNumber, Dt: inv.InvoiceDate.Format("02/01/2006")},
+		SellerDtls: Party{
+			Gstin: inv.SellerGSTIN,
+			LglNm: inv.SellerName,
+			Addr1: value(inv.SellerAddress),
+			Loc:   value(inv.SellerLocation),
+			Pin:   pincode(inv.SellerPincode),
+			Stcd:  inv.SellerGSTIN[:2],
+		},
+		BuyerDtls: Buyer{
+			Party: Party{
+				Gstin: *inv.BuyerGSTIN,
+				LglNm: inv.BuyerName,
+				Addr1: value(inv.BuyerAddress),
+				Loc:   value(inv.BuyerLocation),
+				Pin:   pincode(inv.BuyerPincode),
+				Stcd:  (*inv.BuyerGSTIN)[:2],
+			},
+			Pos: inv.PlaceOfSupply,
+		},
+		ItemList: []Item{{
+			SlNo:       "1",
+			PrdDesc:    inv.Material,
+			IsServc:    "N",
+			HsnCd:      inv.HSNCode,
+			Qty:        inv.QuantityTons,
+			Unit:       unitMetricTons,
+			UnitPrice:  inv.RatePerTon,
+			TotAmt:     inv.TaxableValue,
+			AssAmt:     inv.TaxableValue,
+			GstRt:      inv.GSTRate,
+			IgstAmt:    inv.IGSTAmount,
+			CgstAmt:    inv.CGSTAmount,
+			SgstAmt:    inv.SGSTAmount,
+			TotItemVal: round2(inv.TaxableValue + tax),
+		}},
+		ValDtls: ValDtls{
+			AssVal:    inv.TaxableValue,
+			CgstVal:   inv.CGSTAmount,
+			SgstVal:   inv.SGSTAmount,
+			IgstVal:   inv.IGSTAmount,
+			RndOffAmt: inv.RoundOff,
+			TotInvVal: inv.TotalAmount,
+		},
+	}, nil
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func pincode(s *string) int {
+	if s == nil {
+		return 0
+	}
+	pin, _ := strconv.Atoi(*s)
+	return pin
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
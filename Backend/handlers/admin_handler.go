@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/middleware"
 	"github.com/solaris-hms/mrf-backend/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 func (h *Handlers) GetPendingUsers(c *gin.Context) {
@@ -61,13 +67,27 @@ func (h *Handlers) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
 		return
 	}
+	if len(req.AllRoleIDs()) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one role is required"})
+		return
+	}
 
+	before := h.userSnapshot(userID)
 	err = h.DB.UpdateUser(userID, &req)
 	if err != nil {
+		if errors.Is(err, database.ErrLastAdmin) {
+			respondLastAdmin(c)
+			return
+		}
+		if isForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "One or more roles do not exist"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
+	middleware.AuditChange(c, "user.update", "user", strconv.Itoa(userID), before, h.userSnapshot(userID))
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 }
 
@@ -81,16 +101,30 @@ func (h *Handlers) ApproveUser(c *gin.Context) {
 
 	var req models.ApprovalRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body, role_id is required"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	roleIDs := req.AllRoleIDs()
+	if len(roleIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body, role_ids is required"})
 		return
 	}
 
-	err = h.DB.ApproveUserInDB(userID, req.RoleID)
+	before := h.userSnapshot(userID)
+	actorID, _ := c.Get("userID")
+	err = h.DB.ApproveUserInDB(userID, roleIDs, actorID.(int))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve user: " + err.Error()})
+		if isForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "One or more roles do not exist"})
+			return
+		}
+		if !h.respondUserStatusError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve user: " + err.Error()})
+		}
 		return
 	}
 
+	middleware.AuditChange(c, "user.approve", "user", strconv.Itoa(userID), before, h.userSnapshot(userID))
 	c.JSON(http.StatusOK, gin.H{"message": "User approved successfully"})
 }
 
@@ -102,15 +136,384 @@ func (h *Handlers) RejectUser(c *gin.Context) {
 		return
 	}
 
-	err = h.DB.DeleteUser(userID)
+	// The reason is optional, so an empty body is fine.
+	var req models.RejectUserRequest
+	c.ShouldBindJSON(&req)
+
+	before := h.userSnapshot(userID)
+	actorID, _ := c.Get("userID")
+	err = h.DB.TransitionUserStatus(userID, models.UserStatusRejected, req.Reason, actorID.(int))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject user"})
+		if !h.respondUserStatusError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject user"})
+		}
 		return
 	}
 
+	middleware.AuditChange(c, "user.reject", "user", strconv.Itoa(userID), before, h.userSnapshot(userID))
 	c.JSON(http.StatusOK, gin.H{"message": "User request rejected successfully"})
 }
 
+// UpdateUserStatus moves a user between lifecycle states (suspend, reactivate, offboard, ...).
+// Leaving the active state signs the user out everywhere.
+func (h *Handlers) UpdateUserStatus(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	actorID, _ := c.Get("userID")
+	if userID == actorID.(int) && req.Status != models.UserStatusActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change the status of your own account"})
+		return
+	}
+
+	before := h.userSnapshot(userID)
+	err = h.DB.TransitionUserStatus(userID, req.Status, req.Reason, actorID.(int))
+	if err != nil {
+		if !h.respondUserStatusError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
+		}
+		return
+	}
+
+	if req.Status != models.UserStatusActive {
+		if _, err := h.DB.RevokeUserSessions(userID, "Account "+req.Status); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Status updated but sessions could not be revoked"})
+			return
+		}
+	}
+
+	middleware.AuditChange(c, "user.status", "user", strconv.Itoa(userID), before, h.userSnapshot(userID))
+	c.JSON(http.StatusOK, gin.H{"message": "User status updated successfully"})
+}
+
+// GetUserStatusHistory lists every lifecycle change made to a user.
+func (h *Handlers) GetUserStatusHistory(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	history, err := h.DB.GetUserStatusHistory(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve status history"})
+		return
+	}
+	if history == nil {
+		c.JSON(http.StatusOK, []models.UserStatusChange{})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// respondUserStatusError writes the response for lifecycle errors the client
+// can act on and reports whether it did so.
+func (h *Handlers) respondUserStatusError(c *gin.Context, err error) bool {
+	var transitionErr *database.TransitionError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("User is %s and cannot be moved to %s", transitionErr.From, transitionErr.To)})
+	case errors.Is(err, database.ErrLastAdmin):
+		respondLastAdmin(c)
+	default:
+		return false
+	}
+	return true
+}
+
+// GetUserSessions lists a user's login sessions so an admin can see where they are signed in.
+func (h *Handlers) GetUserSessions(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	sessions, err := h.DB.GetUserSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+	if sessions == nil {
+		c.JSON(http.StatusOK, []models.Session{})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeUserSessions signs a user out everywhere, e.g. when a phone is lost or they leave.
+func (h *Handlers) RevokeUserSessions(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	revoked, err := h.DB.RevokeUserSessions(userID, "Revoked by administrator")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	middleware.AuditChange(c, "user.revoke_sessions", "user", strconv.Itoa(userID), nil, gin.H{"revoked_sessions": revoked})
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": revoked})
+}
+
+// GetLockedAccounts lists users currently locked out after repeated failed logins.
+func (h *Handlers) GetLockedAccounts(c *gin.Context) {
+	accounts, err := h.DB.GetLockedAccounts(accountLockoutAfter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve locked accounts"})
+		return
+	}
+	if accounts == nil {
+		c.JSON(http.StatusOK, []models.LockedAccount{})
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+// UnlockAccount clears a user's lockout and failed-attempt counter.
+func (h *Handlers) UnlockAccount(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.DB.UnlockUser(userID); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+	middleware.AuditChange(c, "user.unlock", "user", strconv.Itoa(userID), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked successfully"})
+}
+
+// GetLoginAttempts lists recorded logins. Supports email, ip, success, from, to
+// (YYYY-MM-DD or RFC3339), limit and offset query parameters.
+func (h *Handlers) GetLoginAttempts(c *gin.Context) {
+	filter := models.LoginAttemptFilter{
+		Email:     c.Query("email"),
+		IPAddress: c.Query("ip"),
+		Limit:     100,
+	}
+
+	if v := c.Query("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid success filter"})
+			return
+		}
+		filter.Success = &success
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := c.Query(param); v != "" {
+			t, err := parseTimeParam(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " date"})
+				return
+			}
+			*target = &t
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return
+		}
+		filter.Offset = offset
+	}
+
+	attempts, err := h.DB.GetLoginAttempts(&filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve login attempts"})
+		return
+	}
+	if attempts == nil {
+		c.JSON(http.StatusOK, []models.LoginAttempt{})
+		return
+	}
+	c.JSON(http.StatusOK, attempts)
+}
+
+// parseTimeParam accepts either a plain date or a full RFC3339 timestamp.
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseDateRange reads the ?from= and ?to= query parameters as a half-open
+// range. A plain-date "to" covers that whole day. It writes a 400 response
+// and returns ok=false when either value is malformed or the range is empty.
+func parseDateRange(c *gin.Context) (from, to *time.Time, ok bool) {
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return nil, nil, false
+		}
+		from = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return nil, nil, false
+		}
+		if len(v) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return nil, nil, false
+	}
+	return from, to, true
+}
+
+// GetUserRoleAssignments lists a user's roles including any site or department scope.
+func (h *Handlers) GetUserRoleAssignments(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	assignments, err := h.DB.GetUserRoleAssignments(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user roles"})
+		return
+	}
+	if assignments == nil {
+		c.JSON(http.StatusOK, []models.RoleAssignment{})
+		return
+	}
+	c.JSON(http.StatusOK, assignments)
+}
+
+// AddUserRoleAssignment grants a user a role, optionally limited to a site or department.
+func (h *Handlers) AddUserRoleAssignment(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.RoleAssignment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if (req.ScopeType == "") != (req.ScopeValue == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope_type and scope_value must be given together"})
+		return
+	}
+
+	before := h.userSnapshot(userID)
+	if err := h.DB.AddUserRoleAssignment(userID, &req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+		return
+	}
+	middleware.AuditChange(c, "user.role.assign", "user", strconv.Itoa(userID), before, h.userSnapshot(userID))
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully"})
+}
+
+// RemoveUserRoleAssignment removes one role assignment. Scoped assignments are
+// identified with scope_type and scope_value query parameters.
+func (h *Handlers) RemoveUserRoleAssignment(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	roleID, err := strconv.Atoi(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	assignment := models.RoleAssignment{RoleID: roleID, ScopeType: c.Query("scope_type"), ScopeValue: c.Query("scope_value")}
+	before := h.userSnapshot(userID)
+	if err := h.DB.RemoveUserRoleAssignment(userID, &assignment); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role assignment not found"})
+			return
+		}
+		if errors.Is(err, database.ErrLastAdmin) {
+			respondLastAdmin(c)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role"})
+		return
+	}
+	middleware.AuditChange(c, "user.role.remove", "user", strconv.Itoa(userID), before, h.userSnapshot(userID))
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed successfully"})
+}
+
+// GetUserAccess returns the user's role assignments and the union of the
+// permissions they grant, with the roles each permission comes from.
+func (h *Handlers) GetUserAccess(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	summary := models.UserAccessSummary{UserID: userID}
+	summary.Roles, err = h.DB.GetUserRoleAssignments(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user roles"})
+		return
+	}
+	summary.Permissions, err = h.DB.GetEffectivePermissions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user permissions"})
+		return
+	}
+	if summary.Roles == nil {
+		summary.Roles = []models.RoleAssignment{}
+	}
+	if summary.Permissions == nil {
+		summary.Permissions = []models.EffectivePermission{}
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
 func (h *Handlers) GetAllPermissions(c *gin.Context) {
 	permissions, err := h.DB.GetAllPermissions()
 	if err != nil {
@@ -150,11 +553,35 @@ func (h *Handlers) UpdatePermissionsForRole(c *gin.Context) {
 		return
 	}
 
+	before := h.roleSnapshot(roleID)
 	err = h.DB.UpdatePermissionsForRole(roleID, req.PermissionIDs)
 	if err != nil {
+		if errors.Is(err, database.ErrLastAdmin) {
+			respondLastAdmin(c)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role permissions"})
 		return
 	}
 
+	middleware.AuditChange(c, "role.permissions.update", "role", strconv.Itoa(roleID), before, h.roleSnapshot(roleID))
 	c.JSON(http.StatusOK, gin.H{"message": "Permissions updated successfully"})
 }
+
+// userSnapshot returns the user's current state for the audit log, or nil if it cannot be read.
+func (h *Handlers) userSnapshot(userID int) interface{} {
+	snapshot, err := h.DB.GetUserSnapshot(userID)
+	if err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// roleSnapshot returns the role's current state for the audit log, or nil if it cannot be read.
+func (h *Handlers) roleSnapshot(roleID int) interface{} {
+	snapshot, err := h.DB.GetRoleSnapshot(roleID)
+	if err != nil {
+		return nil
+	}
+	return snapshot
+}
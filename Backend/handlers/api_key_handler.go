@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/config"
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/middleware"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// GetServiceAccounts lists every service account.
+func (h *Handlers) GetServiceAccounts(c *gin.Context) {
+	accounts, err := h.DB.GetServiceAccounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service accounts"})
+		return
+	}
+	if accounts == nil {
+		c.JSON(http.StatusOK, []models.ServiceAccount{})
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+// CreateServiceAccount adds a non-human account for machine integrations. Its
+// roles, status and sessions are managed through the regular user endpoints.
+// The caller may only assign roles whose permissions they hold globally, so
+// manage:service_accounts cannot be used to mint a more privileged account.
+func (h *Handlers) CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	actions, err := h.DB.GetRolePermissionActions(req.RoleIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load role permissions"})
+		return
+	}
+	grants, _ := middleware.GrantsFromContext(c)
+	var missing []string
+	for _, action := range actions {
+		if !grants.Has(action) {
+			missing = append(missing, action)
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The selected roles grant permissions you do not hold", "permissions": missing})
+		return
+	}
+
+	// Service accounts never log in, so their password is random and discarded.
+	id := make([]byte, 6)
+	password := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+	if _, err := rand.Read(password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(base64.RawStdEncoding.EncodeToString(password)), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	email := "svc-" + hex.EncodeToString(id) + "@service-accounts.invalid"
+
+	actorID, _ := c.Get("userID")
+	account, err := h.DB.CreateServiceAccount(&req, email, string(passwordHash), actorID.(int))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "One or more roles do not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+
+	middleware.AuditChange(c, "service_account.create", "user", strconv.Itoa(account.ID), nil, account)
+	c.JSON(http.StatusCreated, account)
+}
+
+// GetAPIKeys lists a service account's keys. The keys themselves are never returned.
+func (h *Handlers) GetAPIKeys(c *gin.Context) {
+	accountID, ok := h.serviceAccountParam(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.DB.GetAPIKeys(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys"})
+		return
+	}
+	if keys == nil {
+		c.JSON(http.StatusOK, []models.APIKey{})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey issues a new key for a service account. The response is the
+// only time the key is shown.
+func (h *Handlers) CreateAPIKey(c *gin.Context) {
+	accountID, ok := h.serviceAccountParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !isKnownPermission(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission in scopes: " + scope})
+			return
+		}
+	}
+
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+
+	actorID, _ := c.Get("userID")
+	createdBy := actorID.(int)
+	issued := models.IssuedAPIKey{
+		APIKey: models.APIKey{UserID: accountID, Name: req.Name, Prefix: prefix, Scopes: req.Scopes, CreatedByUserID: &createdBy},
+		Key:    key,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		issued.ExpiresAt = &expiresAt
+	}
+
+	if err := h.DB.CreateAPIKey(&issued.APIKey, database.HashAPIKey(key)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	middleware.AuditChange(c, "api_key.create", "api_key", strconv.Itoa(issued.ID), nil, issued.APIKey)
+	c.JSON(http.StatusCreated, issued)
+}
+
+// RotateAPIKey replaces a key with a new one. The old key keeps working for the
+// requested grace period so the integration can be switched over.
+func (h *Handlers) RotateAPIKey(c *gin.Context) {
+	accountID, ok := h.serviceAccountParam(c)
+	if !ok {
+		return
+	}
+	keyID, err := strconv.Atoi(c.Param("keyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key ID"})
+		return
+	}
+
+	// The grace period is optional, so an empty body is fine.
+	var req models.RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+	}
+
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+
+	actorID, _ := c.Get("userID")
+	createdBy := actorID.(int)
+	issued := models.IssuedAPIKey{APIKey: models.APIKey{Prefix: prefix, CreatedByUserID: &createdBy}, Key: key}
+	err = h.DB.RotateAPIKey(accountID, keyID, &issued.APIKey, database.HashAPIKey(key), time.Duration(req.GraceHours)*time.Hour)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found or no longer active"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate API key"})
+		return
+	}
+
+	middleware.AuditChange(c, "api_key.rotate", "api_key", strconv.Itoa(keyID), gin.H{"key_id": keyID}, issued.APIKey)
+	c.JSON(http.StatusCreated, issued)
+}
+
+// RevokeAPIKey disables a key immediately.
+func (h *Handlers) RevokeAPIKey(c *gin.Context) {
+	accountID, ok := h.serviceAccountParam(c)
+	if !ok {
+		return
+	}
+	keyID, err := strconv.Atoi(c.Param("keyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key ID"})
+		return
+	}
+
+	if err := h.DB.RevokeAPIKey(accountID, keyID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found or already revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	middleware.AuditChange(c, "api_key.revoke", "api_key", strconv.Itoa(keyID), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// serviceAccountParam reads :accountId and checks it names a service account.
+// On failure it writes the response and returns false.
+func (h *Handlers) serviceAccountParam(c *gin.Context) (int, bool) {
+	accountID, err := strconv.Atoi(c.Param("accountId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return 0, false
+	}
+	isService, err := h.DB.IsServiceAccount(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service account"})
+		return 0, false
+	}
+	if !isService {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return 0, false
+	}
+	return accountID, true
+}
+
+// newAPIKey returns a random key and its public prefix, e.g. mrf_1a2b3c4d_<secret>.
+func newAPIKey() (string, string, error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix := database.APIKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+func isKnownPermission(action string) bool {
+	for _, p := range config.AllPermissions {
+		if p == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLog lists admin audit log entries, newest first. Supports actor_id,
+// action, target_type, target_id, from, to (YYYY-MM-DD or RFC3339), limit and
+// offset query parameters; the response includes the total number of matches.
+func (h *Handlers) GetAuditLog(c *gin.Context) {
+	filter, ok := parseAuditLogFilter(c)
+	if !ok {
+		return
+	}
+	filter.Limit = 100
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return
+		}
+		filter.Offset = offset
+	}
+
+	entries, total, err := h.DB.GetAuditLog(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditLogEntry{}
+	}
+	c.JSON(http.StatusOK, models.AuditLogPage{Entries: entries, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+// ExportAuditLog downloads every audit log entry matching the same filters as GetAuditLog as CSV.
+func (h *Handlers) ExportAuditLog(c *gin.Context) {
+	filter, ok := parseAuditLogFilter(c)
+	if !ok {
+		return
+	}
+
+	entries, _, err := h.DB.GetAuditLog(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-log-%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "created_at", "actor_user_id", "actor_name", "action", "target_type", "target_id",
+		"before", "after", "method", "path", "query", "status_code", "ip_address", "user_agent"})
+	for _, e := range entries {
+		actorID := ""
+		if e.ActorUserID != nil {
+			actorID = strconv.Itoa(*e.ActorUserID)
+		}
+		record := []string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.Format(time.RFC3339),
+			actorID,
+			derefString(e.ActorName),
+			e.Action,
+			derefString(e.TargetType),
+			derefString(e.TargetID),
+			string(e.Before),
+			string(e.After),
+			e.Method,
+			e.Path,
+			derefString(e.Query),
+			strconv.Itoa(e.StatusCode),
+			derefString(e.IPAddress),
+			derefString(e.UserAgent),
+		}
+		for i := range record {
+			record[i] = csvCell(record[i])
+		}
+		w.Write(record)
+	}
+	w.Flush()
+}
+
+// csvCell stops spreadsheet applications from evaluating a value as a formula
+// by prefixing values that start with a formula character with a quote.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// parseAuditLogFilter reads the filter query parameters shared by the list and export endpoints.
+// On invalid input it writes the response and returns false.
+func parseAuditLogFilter(c *gin.Context) (*models.AuditLogFilter, bool) {
+	filter := models.AuditLogFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+	if v := c.Query("actor_id"); v != "" {
+		actorID, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor_id"})
+			return nil, false
+		}
+		filter.ActorUserID = &actorID
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := c.Query(param); v != "" {
+			t, err := parseTimeParam(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " date"})
+				return nil, false
+			}
+			*target = &t
+		}
+	}
+	return &filter, true
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
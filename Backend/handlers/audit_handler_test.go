@@ -0,0 +1,23 @@
+package handlers
+
+import "testing"
+
+func TestCSVCellNeutralizesFormulas(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"GET":                      "GET",
+		"/api/admin/users":         "/api/admin/users",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+1+1":                     "'+1+1",
+		"-2+3":                     "'-2+3",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\t=1":                     "'\t=1",
+		"a=b":                      "a=b",
+		`{"name": "=1+1"}`:         `{"name": "=1+1"}`,
+	}
+	for in, want := range tests {
+		if got := csvCell(in); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"net/http"
 	"time"
 
+	"github.com/solaris-hms/mrf-backend/database"
 	"github.com/solaris-hms/mrf-backend/models"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +18,11 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
 func (h *Handlers) RegisterUser(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -49,49 +59,227 @@ func (h *Handlers) LoginUser(c *gin.Context) {
 		return
 	}
 
+	if !h.checkIPThrottle(c, req.Email) {
+		return
+	}
+
 	user, err := h.DB.GetUserByEmail(req.Email)
 	if err != nil {
+		h.recordLoginAttempt(c, req.Email, nil, "unknown_email")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	if !user.IsApproved {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Your account is awaiting approval"})
+	if !h.checkLoginAllowed(c, user) {
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
+		h.registerFailedLogin(c, user, "bad_password")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	roles, err := h.DB.GetUserRoles(user.ID)
+	h.continueLogin(c, user)
+}
+
+// checkLoginAllowed rejects logins to locked, service or inactive accounts. It
+// writes the response and returns false when the login must stop.
+func (h *Handlers) checkLoginAllowed(c *gin.Context, user *models.User) bool {
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		h.recordLoginAttempt(c, user.Email, &user.ID, "locked")
+		if user.FailedLoginCount >= accountLockoutAfter {
+			tooManyAttempts(c, time.Until(*user.LockedUntil), "Account is temporarily locked after repeated failed logins. Contact an administrator or try again later.")
+		} else {
+			tooManyAttempts(c, time.Until(*user.LockedUntil), "Too many failed login attempts. Please wait before trying again.")
+		}
+		return false
+	}
+
+	if user.AccountType == models.AccountTypeService {
+		h.recordLoginAttempt(c, user.Email, &user.ID, "service_account")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Service accounts must authenticate with an API key"})
+		return false
+	}
+
+	if user.Status != models.UserStatusActive {
+		h.recordLoginAttempt(c, user.Email, &user.ID, "status_"+user.Status)
+		switch user.Status {
+		case models.UserStatusPending:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your account is awaiting approval"})
+		case models.UserStatusSuspended:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your account has been suspended. Please contact an administrator."})
+		default:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your account is no longer active"})
+		}
+		return false
+	}
+	return true
+}
+
+// continueLogin runs after the first factor succeeded: users with two-factor
+// enabled get a challenge, everyone else is logged in.
+func (h *Handlers) continueLogin(c *gin.Context, user *models.User) {
+	twoFactor, err := h.DB.GetTwoFactorState(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve user roles"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return
+	}
+	if twoFactor.Enabled() {
+		h.issueLoginChallenge(c, user)
 		return
 	}
 
-	permissions, err := h.DB.GetUserPermissions(user.ID)
+	h.completeLogin(c, user)
+}
+
+// completeLogin clears the failure counter, records the successful login and
+// returns the session tokens.
+func (h *Handlers) completeLogin(c *gin.Context, user *models.User) {
+	if err := h.DB.ResetFailedLogins(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update login state"})
+		return
+	}
+	h.recordLoginAttempt(c, user.Email, &user.ID, "")
+
+	tokens, err := h.startSession(c, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve user permissions"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start session"})
 		return
 	}
 
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair.
+func (h *Handlers) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+
+	session, err := h.DB.RotateRefreshToken(hashToken(req.RefreshToken), refreshHash, time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidRefreshToken) || errors.Is(err, database.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired, please log in again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh session"})
+		return
+	}
+
+	user, err := h.DB.GetUserByID(session.UserID)
+	if err != nil || user.Status != models.UserStatusActive {
+		h.DB.RevokeSession(session.ID, "User no longer active")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired, please log in again"})
+		return
+	}
+
+	accessToken, err := h.signAccessToken(user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+	})
+}
+
+// LogoutUser revokes the session behind the current access token.
+func (h *Handlers) LogoutUser(c *gin.Context) {
+	sessionID, ok := c.Get("sessionID")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only login sessions can be logged out"})
+		return
+	}
+	if err := h.DB.RevokeSession(sessionID.(int), "Logged out"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// startSession opens a server-side session for the user and returns the token pair for the client.
+func (h *Handlers) startSession(c *gin.Context, user *models.User) (gin.H, error) {
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := h.DB.CreateSession(user.ID, refreshHash, time.Now().Add(refreshTokenTTL), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := h.signAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":                accessToken,
+		"refresh_token":        refreshToken,
+		"expires_in":           int(accessTokenTTL.Seconds()),
+		"must_change_password": user.MustChangePassword,
+	}, nil
+}
+
+// signAccessToken builds the short-lived JWT carrying the user's roles, permissions and session ID.
+func (h *Handlers) signAccessToken(user *models.User, sessionID int) (string, error) {
+	roles, err := h.DB.GetUserRoles(user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	grants, err := h.DB.GetUserPermissions(user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	twoFactor, err := h.DB.GetTwoFactorState(user.ID)
+	if err != nil {
+		return "", err
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id":     user.ID,
+		"sid":         sessionID,
 		"email":       user.Email,
 		"full_name":   user.FullName,
 		"roles":       roles,
-		"permissions": permissions,
-		"exp":         time.Now().Add(time.Hour * 24 * 7).Unix(),
+		"permissions": grants.Actions(),
+		"grants":      grants,
+		"pwd_change":  user.MustChangePassword,
+		"tfa_enroll":  twoFactor.Required && !twoFactor.Enabled(),
+		"iat":         time.Now().Unix(),
+		"exp":         time.Now().Add(accessTokenTTL).Unix(),
 	})
 
-	tokenString, err := token.SignedString([]byte(h.JWTSecret))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
-		return
+	return token.SignedString([]byte(h.JWTSecret))
+}
+
+// newRefreshToken returns a random opaque refresh token and the hash stored for it.
+func newRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
 	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
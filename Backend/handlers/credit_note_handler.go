@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// CancelMaterialSale cancels a sale by issuing a credit note for it.
+func (h *Handlers) CancelMaterialSale(c *gin.Context) {
+	saleID, err := strconv.Atoi(c.Param("saleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sale ID"})
+		return
+	}
+	var req models.CreateSaleCreditNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	note, err := h.DB.CreateSaleCreditNote(saleID, &req, c.GetInt("userID"))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return
+	case errors.Is(err, database.ErrSaleCancelled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Sale has already been cancelled"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue credit note"})
+		return
+	}
+	c.JSON(http.StatusCreated, note)
+}
+
+// GetSaleCreditNotes lists credit notes, optionally within ?from= and ?to=.
+func (h *Handlers) GetSaleCreditNotes(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	notes, err := h.DB.GetSaleCreditNotes(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credit notes"})
+		return
+	}
+	if notes == nil {
+		c.JSON(http.StatusOK, []models.SaleCreditNote{})
+		return
+	}
+	c.JSON(http.StatusOK, notes)
+}
+
+func (h *Handlers) GetSaleCreditNote(c *gin.Context) {
+	noteID, err := strconv.Atoi(c.Param("creditNoteId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credit note ID"})
+		return
+	}
+	note, err := h.DB.GetSaleCreditNote(noteID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Credit note not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credit note"})
+		return
+	}
+	c.JSON(http.StatusOK, note)
+}
@@ -2,12 +2,57 @@ package handlers
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/solaris-hms/mrf-backend/middleware"
 	"github.com/solaris-hms/mrf-backend/models"
 )
 
+// departmentScope returns the departments the caller holds action for. It
+// returns nil when the action is granted globally, so queries cover every
+// department, and an empty slice when it is granted for none.
+func departmentScope(c *gin.Context, action string) []string {
+	grants, _ := middleware.GrantsFromContext(c)
+	departments, global := grants.Scopes(action, models.ScopeDepartment)
+	if global {
+		return nil
+	}
+	if departments == nil {
+		departments = []string{}
+	}
+	return departments
+}
+
+// inDepartments reports whether department is covered by a scope from departmentScope.
+func inDepartments(scope []string, department string) bool {
+	return scope == nil || (department != "" && slices.Contains(scope, department))
+}
+
+// employeesInScope checks that every listed employee exists and belongs to a
+// department the caller holds action for, responding with an error if not.
+func (h *Handlers) employeesInScope(c *gin.Context, action string, ids []int) bool {
+	departments, err := h.DB.GetEmployeeDepartments(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load employees"})
+		return false
+	}
+	scope := departmentScope(c, action)
+	for _, id := range ids {
+		department, ok := departments[id]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+			return false
+		}
+		if !inDepartments(scope, department) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to manage employees in this department"})
+			return false
+		}
+	}
+	return true
+}
+
 // --- Employee Handlers ---
 
 func (h *Handlers) CreateEmployee(c *gin.Context) {
@@ -16,6 +61,10 @@ func (h *Handlers) CreateEmployee(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	if !inDepartments(departmentScope(c, "manage:employees"), req.Department) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to manage employees in this department"})
+		return
+	}
 
 	employee, err := h.DB.CreateEmployee(&req)
 	if err != nil {
@@ -27,7 +76,7 @@ func (h *Handlers) CreateEmployee(c *gin.Context) {
 }
 
 func (h *Handlers) GetEmployees(c *gin.Context) {
-	employees, err := h.DB.GetAllActiveEmployees()
+	employees, err := h.DB.GetAllActiveEmployees(departmentScope(c, "manage:employees"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
 		return
@@ -46,6 +95,13 @@ func (h *Handlers) UpdateEmployee(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	if !h.employeesInScope(c, "manage:employees", []int{employeeID}) {
+		return
+	}
+	if req.Department != "" && !inDepartments(departmentScope(c, "manage:employees"), req.Department) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to manage employees in this department"})
+		return
+	}
 
 	err := h.DB.UpdateEmployee(employeeID, &req)
 	if err != nil {
@@ -58,6 +114,9 @@ func (h *Handlers) UpdateEmployee(c *gin.Context) {
 
 func (h *Handlers) DeleteEmployee(c *gin.Context) {
 	employeeID, _ := strconv.Atoi(c.Param("id"))
+	if !h.employeesInScope(c, "manage:employees", []int{employeeID}) {
+		return
+	}
 	err := h.DB.DeactivateEmployee(employeeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete employee"})
@@ -75,7 +134,7 @@ func (h *Handlers) GetAttendance(c *gin.Context) {
 		return
 	}
 
-	records, err := h.DB.GetMonthlyAttendance(month)
+	records, err := h.DB.GetMonthlyAttendance(month, departmentScope(c, "manage:attendance"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendance records"})
 		return
@@ -95,6 +154,14 @@ func (h *Handlers) SaveAttendance(c *gin.Context) {
 		return
 	}
 
+	ids := make([]int, len(req.Records))
+	for i, record := range req.Records {
+		ids[i] = record.EmployeeID
+	}
+	if !h.employeesInScope(c, "manage:attendance", ids) {
+		return
+	}
+
 	userID, _ := c.Get("userID")
 
 	err := h.DB.SaveMonthlyAttendance(req.Date, req.Records, userID.(int))
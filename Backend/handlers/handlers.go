@@ -2,18 +2,29 @@ package handlers
 
 import (
 	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/identity"
+	"github.com/solaris-hms/mrf-backend/notify"
+	"github.com/solaris-hms/mrf-backend/weighbridge"
 )
 
 // Handlers struct holds dependencies like the database connection and JWT secret.
 type Handlers struct {
 	DB        *database.DB
 	JWTSecret string
+	Notifier  notify.Notifier
+	// Identity is the external login provider, nil when only local passwords are enabled.
+	Identity identity.Provider
+	// Weighbridge reads the indicator, nil when weights are keyed manually.
+	Weighbridge *weighbridge.Reader
 }
 
 // New creates a new Handlers struct with its dependencies.
-func New(db *database.DB, jwtSecret string) *Handlers {
+func New(db *database.DB, jwtSecret string, notifier notify.Notifier, identityProvider identity.Provider, scale *weighbridge.Reader) *Handlers {
 	return &Handlers{
-		DB:        db,
-		JWTSecret: jwtSecret,
+		DB:          db,
+		JWTSecret:   jwtSecret,
+		Notifier:    notifier,
+		Identity:    identityProvider,
+		Weighbridge: scale,
 	}
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInventoryPostingRules lists how each entry type changes stock on completion.
+func (h *Handlers) GetInventoryPostingRules(c *gin.Context) {
+	rules, err := h.DB.GetInventoryPostingRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posting rules"})
+		return
+	}
+	if rules == nil {
+		c.JSON(http.StatusOK, []models.InventoryPostingRule{})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetInventoryPostingRule creates or replaces the posting rule for an entry type.
+func (h *Handlers) SetInventoryPostingRule(c *gin.Context) {
+	entryType := strings.TrimSpace(c.Param("entryType"))
+	if entryType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Entry type is required"})
+		return
+	}
+	var req models.UpdateInventoryPostingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	rule, err := h.DB.SetInventoryPostingRule(entryType, &req, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save posting rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// GetInventoryMovements lists recent stock movements, optionally filtered by ?material=.
+func (h *Handlers) GetInventoryMovements(c *gin.Context) {
+	limit := 200
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		limit = n
+	}
+
+	movements, err := h.DB.GetInventoryMovements(c.Query("material"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock movements"})
+		return
+	}
+	if movements == nil {
+		c.JSON(http.StatusOK, []models.InventoryMovement{})
+		return
+	}
+	c.JSON(http.StatusOK, movements)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/identity"
+	"github.com/solaris-hms/mrf-backend/middleware"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// oidcLoginTTL is how long the user has to finish logging in at the identity provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcBindingCookie holds the random value tying a login state to the browser that
+// started it. Without it, anyone could complete an OIDC callback in a victim's
+// browser and log them in to the attacker's account (login CSRF).
+const (
+	oidcBindingCookie     = "oidc_login_binding"
+	oidcBindingCookiePath = "/api/auth/oidc"
+)
+
+// GetOIDCConfig tells the login page whether to offer single sign-on.
+func (h *Handlers) GetOIDCConfig(c *gin.Context) {
+	if h.Identity == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "name": h.Identity.Name()})
+}
+
+// StartOIDCLogin returns the identity provider URL to send the browser to.
+// The provider redirects back to the frontend, which posts the code to OIDCCallback.
+// The response also sets the cookie the callback needs to prove it runs in the same browser.
+func (h *Handlers) StartOIDCLogin(c *gin.Context) {
+	if h.Identity == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	req, err := identity.NewAuthRequest()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start login"})
+		return
+	}
+	authURL, err := h.Identity.AuthCodeURL(c.Request.Context(), req)
+	if err != nil {
+		log.Printf("OIDC: could not build authorization URL: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+	binding, bindingHash, err := newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start login"})
+		return
+	}
+	if err := h.DB.CreateOIDCLoginState(hashToken(req.State), bindingHash, req.Nonce, req.CodeVerifier, time.Now().Add(oidcLoginTTL)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start login"})
+		return
+	}
+
+	setOIDCBindingCookie(c, binding, int(oidcLoginTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL, "expires_in": int(oidcLoginTTL.Seconds())})
+}
+
+// OIDCCallback redeems the authorization code and logs in the linked user.
+// Unknown identities are linked by verified email to an existing account, or
+// otherwise registered as a pending user awaiting approval.
+func (h *Handlers) OIDCCallback(c *gin.Context) {
+	if h.Identity == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	var req models.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	binding, err := c.Cookie(oidcBindingCookie)
+	if err != nil || binding == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login was not started in this browser, please try again"})
+		return
+	}
+	setOIDCBindingCookie(c, "", -1)
+
+	if !h.checkIPThrottle(c, "") {
+		return
+	}
+
+	nonce, codeVerifier, err := h.DB.ConsumeOIDCLoginState(hashToken(req.State), hashToken(binding))
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidOIDCState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Login has expired, please try again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return
+	}
+
+	claims, err := h.Identity.Exchange(c.Request.Context(), req.Code, identity.AuthRequest{State: req.State, Nonce: nonce, CodeVerifier: codeVerifier})
+	if err != nil {
+		log.Printf("OIDC: login failed: %v", err)
+		h.recordLoginAttempt(c, "", nil, "oidc_invalid")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Could not verify your identity provider login"})
+		return
+	}
+
+	userID, err := h.DB.GetUserIDByIdentity(claims.Issuer, claims.Subject, claims.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var done bool
+		userID, done = h.linkOrRegisterIdentity(c, claims)
+		if done {
+			return
+		}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return
+	}
+
+	user, err := h.DB.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return
+	}
+	if !h.checkLoginAllowed(c, user) {
+		return
+	}
+	h.continueLogin(c, user)
+}
+
+// setOIDCBindingCookie sets, or with maxAge -1 clears, the login binding cookie.
+// SameSite=Lax keeps it off cross-site POSTs, so a forged callback arrives without it.
+func setOIDCBindingCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcBindingCookie,
+		Value:    value,
+		Path:     oidcBindingCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// linkOrRegisterIdentity handles the first login of an external identity. It
+// returns the linked user, or done=true when it has already written the response.
+func (h *Handlers) linkOrRegisterIdentity(c *gin.Context, claims *identity.Claims) (int, bool) {
+	if claims.Email == "" {
+		h.recordLoginAttempt(c, "", nil, "oidc_no_email")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your identity provider did not share an email address"})
+		return 0, true
+	}
+
+	existing, err := h.DB.GetUserByEmail(claims.Email)
+	if err == nil {
+		// Only a verified email proves the IdP account belongs to the local user.
+		if !claims.EmailVerified {
+			h.recordLoginAttempt(c, existing.Email, &existing.ID, "oidc_unverified_email")
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists. Your identity provider has not verified the address, so sign in with your password instead."})
+			return 0, true
+		}
+		if err := h.DB.LinkUserIdentity(existing.ID, claims.Issuer, claims.Subject, claims.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not link your account"})
+			return 0, true
+		}
+		return existing.ID, false
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return 0, true
+	}
+
+	// The pending account gets a random password so only the identity provider
+	// (or a later admin-issued reset) can be used to sign in.
+	randomPassword, _, err := newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
+		return 0, true
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return 0, true
+	}
+	fullName := claims.Name
+	if fullName == "" {
+		fullName = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	userID, err := h.DB.CreatePendingExternalUser(fullName, claims.Email, string(passwordHash), claims.Issuer, claims.Subject)
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists."})
+			return 0, true
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
+		return 0, true
+	}
+
+	h.recordLoginAttempt(c, claims.Email, &userID, "status_"+models.UserStatusPending)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Application submitted successfully. Awaiting approval."})
+	return 0, true
+}
+
+// GetUserIdentities lists the external identities linked to a user.
+func (h *Handlers) GetUserIdentities(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	identities, err := h.DB.GetUserIdentities(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve linked identities"})
+		return
+	}
+	if identities == nil {
+		identities = []models.UserIdentity{}
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+// UnlinkUserIdentity removes an external identity so it can no longer log in as the user.
+func (h *Handlers) UnlinkUserIdentity(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	identityID, err := strconv.Atoi(c.Param("identityId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+		return
+	}
+
+	if err := h.DB.UnlinkUserIdentity(userID, identityID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Linked identity not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+
+	middleware.AuditChange(c, "user.identity.unlink", "user", strconv.Itoa(userID), gin.H{"identity_id": identityID}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/identity"
+	"github.com/solaris-hms/mrf-backend/identity/oidctest"
+	"github.com/solaris-hms/mrf-backend/migrations"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type oidcTestServer struct {
+	t      *testing.T
+	h      *Handlers
+	router *gin.Engine
+	iss    *oidctest.Issuer
+	ip     string
+}
+
+// newOIDCTestServer wires the OIDC handlers to a mock issuer. db may be nil for
+// tests that are rejected before the database is reached.
+func newOIDCTestServer(t *testing.T, db *database.DB) *oidcTestServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	iss := oidctest.NewIssuer(t, "mrf")
+	h := &Handlers{
+		DB:        db,
+		JWTSecret: "oidc-test-secret",
+		Identity: identity.NewOIDCProvider(identity.OIDCConfig{
+			Issuer:      iss.URL,
+			ClientID:    iss.ClientID,
+			RedirectURL: "http://localhost:5173/login/oidc",
+		}),
+	}
+	r := gin.New()
+	r.GET("/api/auth/oidc/authorize", h.StartOIDCLogin)
+	r.POST("/api/auth/oidc/callback", h.OIDCCallback)
+	return &oidcTestServer{
+		t:      t,
+		h:      h,
+		router: r,
+		iss:    iss,
+		// Each test gets its own client address, so failed logins recorded by
+		// earlier tests or runs do not trip the per-IP throttle.
+		ip: fmt.Sprintf("10.%d.%d.%d", rand.IntN(256), rand.IntN(256), rand.IntN(254)+1),
+	}
+}
+
+// testDB connects to TEST_DB_SOURCE and applies the migrations. Tests that need
+// Postgres are skipped when it is not set.
+func testDB(t *testing.T) *database.DB {
+	t.Helper()
+	source := os.Getenv("TEST_DB_SOURCE")
+	if source == "" {
+		t.Skip("TEST_DB_SOURCE is not set")
+	}
+	t.Setenv("DB_SOURCE", source)
+	db := database.New()
+	t.Cleanup(db.Close)
+
+	schema, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if err := db.MigrateUp(schema); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	return db
+}
+
+func (s *oidcTestServer) do(req *http.Request) *httptest.ResponseRecorder {
+	req.RemoteAddr = s.ip + ":40000"
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// start begins a login and returns the authorization URL and binding cookie.
+func (s *oidcTestServer) start() (string, *http.Cookie) {
+	s.t.Helper()
+	w := s.do(httptest.NewRequest(http.MethodGet, "/api/auth/oidc/authorize", nil))
+	if w.Code != http.StatusOK {
+		s.t.Fatalf("StartOIDCLogin status = %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		s.t.Fatal(err)
+	}
+
+	var binding *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcBindingCookie {
+			binding = cookie
+		}
+	}
+	if binding == nil || binding.Value == "" {
+		s.t.Fatal("StartOIDCLogin did not set the binding cookie")
+	}
+	if !binding.HttpOnly || binding.SameSite != http.SameSiteLaxMode || binding.Path != oidcBindingCookiePath {
+		s.t.Fatalf("binding cookie attributes = %+v", binding)
+	}
+	return body.AuthorizationURL, binding
+}
+
+func (s *oidcTestServer) callback(binding *http.Cookie, code, state string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	payload, _ := json.Marshal(models.OIDCCallbackRequest{Code: code, State: state})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/oidc/callback", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if binding != nil {
+		req.AddCookie(&http.Cookie{Name: binding.Name, Value: binding.Value})
+	}
+	return s.do(req)
+}
+
+// login runs a complete login as the given IdP account.
+func (s *oidcTestServer) login(login oidctest.Login) *httptest.ResponseRecorder {
+	s.t.Helper()
+	authURL, binding := s.start()
+	code, state := s.iss.Authorize(s.t, authURL, login)
+	return s.callback(binding, code, state)
+}
+
+// createActiveUser registers a local password user and approves it.
+func createActiveUser(t *testing.T, db *database.DB, email string) *models.User {
+	t.Helper()
+	req := &models.RegisterRequest{FullName: "OIDC Test", Username: email, Designation: "Operator", Email: email}
+	if err := db.RegisterUserInDB(req, "unused-hash"); err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+	user, err := db.GetUserByEmail(email)
+	if err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if err := db.ApproveUserInDB(user.ID, nil, user.ID); err != nil {
+		t.Fatalf("approve user: %v", err)
+	}
+	return user
+}
+
+func uniqueEmail(prefix string) string {
+	return fmt.Sprintf("%s-%d@example.com", prefix, time.Now().UnixNano())
+}
+
+func assertStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status = %d, want %d: %s", w.Code, want, w.Body)
+	}
+}
+
+func TestOIDCCallbackRequiresBindingCookie(t *testing.T) {
+	s := newOIDCTestServer(t, nil)
+	w := s.callback(nil, "code", "state")
+	assertStatus(t, w, http.StatusBadRequest)
+}
+
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	db := testDB(t)
+	s := newOIDCTestServer(t, db)
+	email := uniqueEmail("link")
+	user := createActiveUser(t, db, email)
+
+	w := s.login(oidctest.Login{Subject: email, Email: email, EmailVerified: true})
+	assertStatus(t, w, http.StatusOK)
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil || tokens.Token == "" {
+		t.Fatalf("login response has no token: %s", w.Body)
+	}
+
+	identities, err := db.GetUserIdentities(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].Subject != email || identities[0].Issuer != s.iss.URL {
+		t.Fatalf("linked identities = %+v", identities)
+	}
+}
+
+func TestOIDCLoginWithLinkedIdentity(t *testing.T) {
+	db := testDB(t)
+	s := newOIDCTestServer(t, db)
+	email := uniqueEmail("linked")
+	user := createActiveUser(t, db, email)
+
+	assertStatus(t, s.login(oidctest.Login{Subject: email, Email: email, EmailVerified: true}), http.StatusOK)
+	// The identity is now linked, so the email no longer has to be verified.
+	assertStatus(t, s.login(oidctest.Login{Subject: email, Email: email}), http.StatusOK)
+
+	identities, err := db.GetUserIdentities(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 {
+		t.Fatalf("got %d linked identities, want 1", len(identities))
+	}
+}
+
+func TestOIDCLoginRejectsUnverifiedEmailForExistingUser(t *testing.T) {
+	db := testDB(t)
+	s := newOIDCTestServer(t, db)
+	email := uniqueEmail("unverified")
+	createActiveUser(t, db, email)
+
+	assertStatus(t, s.login(oidctest.Login{Subject: email, Email: email}), http.StatusConflict)
+}
+
+func TestOIDCCallbackStateMismatch(t *testing.T) {
+	db := testDB(t)
+	s := newOIDCTestServer(t, db)
+	login := oidctest.Login{Subject: uniqueEmail("state")}
+
+	authURL, binding := s.start()
+	code, state := s.iss.Authorize(t, authURL, login)
+	assertStatus(t, s.callback(binding, code, state+"x"), http.StatusBadRequest)
+
+	// A code and state obtained in another browser must not complete with this browser's cookie.
+	otherURL, _ := s.start()
+	otherCode, otherState := s.iss.Authorize(t, otherURL, login)
+	_, ownBinding := s.start()
+	assertStatus(t, s.callback(ownBinding, otherCode, otherState), http.StatusBadRequest)
+}
+
+func TestOIDCCallbackRejectsBadNonce(t *testing.T) {
+	db := testDB(t)
+	s := newOIDCTestServer(t, db)
+	email := uniqueEmail("nonce")
+	createActiveUser(t, db, email)
+
+	w := s.login(oidctest.Login{Subject: email, Email: email, EmailVerified: true, Nonce: "forged"})
+	assertStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestOIDCLoginRejectsInactiveUser(t *testing.T) {
+	db := testDB(t)
+	s := newOIDCTestServer(t, db)
+	email := uniqueEmail("inactive")
+	user := createActiveUser(t, db, email)
+
+	assertStatus(t, s.login(oidctest.Login{Subject: email, Email: email, EmailVerified: true}), http.StatusOK)
+	if err := db.TransitionUserStatus(user.ID, models.UserStatusSuspended, "OIDC test", user.ID); err != nil {
+		t.Fatalf("suspend user: %v", err)
+	}
+	assertStatus(t, s.login(oidctest.Login{Subject: email, Email: email, EmailVerified: true}), http.StatusForbidden)
+}
@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/solaris-hms/mrf-backend/database"
 	"github.com/solaris-hms/mrf-backend/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 // Inward Entry Handlers
@@ -16,8 +22,25 @@ func (h *Handlers) CreateInwardEntry(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
 		return
 	}
+	var ok bool
+	req.GrossWeightTons, req.ManualWeight, ok = h.captureWeight(c, req.GrossWeightTons, req.ManualWeight)
+	if !ok {
+		return
+	}
 	userID, _ := c.Get("userID")
 	entry, err := h.DB.CreateInwardEntry(&req, userID.(int))
+	var blacklisted *database.VehicleBlacklistedError
+	switch {
+	case errors.Is(err, database.ErrVendorNotApproved):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vendor must exist and be approved"})
+		return
+	case errors.Is(err, database.ErrInvalidRegistration):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle number"})
+		return
+	case errors.As(err, &blacklisted):
+		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle " + blacklisted.RegistrationNumber + " is blacklisted", "reason": blacklisted.Reason})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create entry"})
 		return
@@ -25,48 +48,221 @@ func (h *Handlers) CreateInwardEntry(c *gin.Context) {
 	c.JSON(http.StatusCreated, entry)
 }
 
+// GetPendingInwardEntries lists entries waiting for their second weighing.
+// See parseInwardEntryFilter for the query parameters.
 func (h *Handlers) GetPendingInwardEntries(c *gin.Context) {
-	entries, err := h.DB.GetPendingInwardEntries()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending entries"})
+	filter, ok := parseInwardEntryFilter(c)
+	if !ok {
 		return
 	}
-	if entries == nil {
-		c.JSON(http.StatusOK, []models.InwardEntry{})
+	entries, total, err := h.DB.GetPendingInwardEntries(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending entries"})
 		return
 	}
-	c.JSON(http.StatusOK, entries)
+	c.JSON(http.StatusOK, newInwardEntryPage(entries, total, filter, func(e *models.InwardEntry) time.Time { return e.CreatedAt }))
 }
 
+// GetCompletedInwardEntries lists weighed entries.
+// See parseInwardEntryFilter for the query parameters.
 func (h *Handlers) GetCompletedInwardEntries(c *gin.Context) {
-	entries, err := h.DB.GetCompletedInwardEntries()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completed entries"})
+	filter, ok := parseInwardEntryFilter(c)
+	if !ok {
 		return
 	}
-	if entries == nil {
-		c.JSON(http.StatusOK, []models.InwardEntry{})
+	entries, total, err := h.DB.GetCompletedInwardEntries(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completed entries"})
 		return
 	}
-	c.JSON(http.StatusOK, entries)
+	// Matches the database's sort key, which falls back to arrival for entries without completed_at.
+	c.JSON(http.StatusOK, newInwardEntryPage(entries, total, filter, func(e *models.InwardEntry) time.Time {
+		if e.CompletedAt == nil {
+			return e.CreatedAt
+		}
+		return *e.CompletedAt
+	}))
+}
+
+const (
+	defaultInwardEntryPageSize = 50
+	maxInwardEntryPageSize     = 500
+)
+
+// parseInwardEntryFilter reads the list query parameters: from, to, vehicle
+// (exact), q (partial vehicle number), party_id, source_id, material,
+// entry_type, min_weight and max_weight in tons, cursor and limit.
+// On invalid input it writes the response and returns false.
+func parseInwardEntryFilter(c *gin.Context) (*models.InwardEntryFilter, bool) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return nil, false
+	}
+	filter := models.InwardEntryFilter{
+		From:          from,
+		To:            to,
+		VehicleNumber: strings.TrimSpace(c.Query("vehicle")),
+		Search:        strings.TrimSpace(c.Query("q")),
+		Material:      strings.TrimSpace(c.Query("material")),
+		EntryType:     strings.TrimSpace(c.Query("entry_type")),
+		Limit:         defaultInwardEntryPageSize,
+	}
+	for param, target := range map[string]**int{"party_id": &filter.PartyID, "source_id": &filter.SourceID} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return nil, false
+			}
+			*target = &id
+		}
+	}
+	for param, target := range map[string]**float64{"min_weight": &filter.MinWeightTons, "max_weight": &filter.MaxWeightTons} {
+		if v := c.Query(param); v != "" {
+			tons, err := strconv.ParseFloat(v, 64)
+			if err != nil || tons < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return nil, false
+			}
+			*target = &tons
+		}
+	}
+	if filter.MinWeightTons != nil && filter.MaxWeightTons != nil && *filter.MinWeightTons > *filter.MaxWeightTons {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_weight must not exceed max_weight"})
+		return nil, false
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxInwardEntryPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxInwardEntryPageSize)})
+			return nil, false
+		}
+		filter.Limit = n
+	}
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := models.ParseInwardEntryCursor(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return nil, false
+		}
+		filter.After = cursor
+	}
+	return &filter, true
+}
+
+// newInwardEntryPage trims the extra row the database fetched past the limit
+// and turns it into the cursor for the next page.
+func newInwardEntryPage(entries []models.InwardEntry, total int, filter *models.InwardEntryFilter, sortTime func(*models.InwardEntry) time.Time) models.InwardEntryPage {
+	page := models.InwardEntryPage{Entries: entries, Total: total, Limit: filter.Limit}
+	if len(entries) > filter.Limit {
+		page.Entries = entries[:filter.Limit]
+		last := &page.Entries[len(page.Entries)-1]
+		next := models.InwardEntryCursor{At: sortTime(last), ID: last.ID}.String()
+		page.NextCursor = &next
+	}
+	if page.Entries == nil {
+		page.Entries = []models.InwardEntry{}
+	}
+	return page
 }
 
 func (h *Handlers) CompleteInwardEntry(c *gin.Context) {
 	entryIDStr := c.Param("entryId")
-	entryID, _ := strconv.Atoi(entryIDStr)
+	entryID, err := strconv.Atoi(entryIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
 	var req models.CompleteInwardEntryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
-	_, err := h.DB.CompleteInwardEntry(entryID, &req)
+	if !req.UseStoredTare {
+		var ok bool
+		req.TareWeightTons, req.ManualWeight, ok = h.captureWeight(c, req.TareWeightTons, req.ManualWeight)
+		if !ok {
+			return
+		}
+	}
+	userID, _ := c.Get("userID")
+	_, err = h.DB.CompleteInwardEntry(entryID, &req, userID.(int))
 	if err != nil {
+		if respondInwardEntryError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete entry"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Entry completed successfully"})
 }
 
+// TransitionInwardEntry moves an entry to the next weighbridge step, e.g. gate-out or cancellation.
+func (h *Handlers) TransitionInwardEntry(c *gin.Context) {
+	entryID, err := strconv.Atoi(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+	var req models.TransitionInwardEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := h.DB.TransitionInwardEntry(entryID, req.Status, req.Note, userID.(int)); err != nil {
+		if respondInwardEntryError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update entry status"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Entry status updated successfully"})
+}
+
+// GetInwardEntryHistory lists every weighbridge step an entry went through.
+func (h *Handlers) GetInwardEntryHistory(c *gin.Context) {
+	entryID, err := strconv.Atoi(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	history, err := h.DB.GetInwardEntryHistory(entryID)
+	if err != nil {
+		if respondInwardEntryError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve entry history"})
+		return
+	}
+	if history == nil {
+		c.JSON(http.StatusOK, []models.InwardStatusChange{})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// respondInwardEntryError writes the response for lifecycle errors the client
+// can act on and reports whether it did so.
+func respondInwardEntryError(c *gin.Context, err error) bool {
+	var transitionErr *database.TransitionError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entry not found"})
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Entry is %s and cannot be moved to %s", transitionErr.From, transitionErr.To)})
+	case errors.Is(err, database.ErrNoStoredTare):
+		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle has no stored tare, or it has expired"})
+	case errors.Is(err, database.ErrStoredTareNotApplicable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Stored tare can only replace the empty weighing of a vehicle that arrived loaded"})
+	default:
+		return false
+	}
+	return true
+}
+
 // Partner Handlers
 func (h *Handlers) GetAllPartners(c *gin.Context) {
 	partners, err := h.DB.GetAllPartners()
@@ -105,6 +301,12 @@ func (h *Handlers) CreateSortingLog(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
 		return
 	}
+	for _, entry := range req.Entries {
+		if strings.EqualFold(strings.TrimSpace(entry.Material), models.UnsortedMaterial) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sorted materials cannot be logged as " + models.UnsortedMaterial})
+			return
+		}
+	}
 	userID, _ := c.Get("userID")
 	err := h.DB.CreateSortingLog(&req, userID.(int))
 	if err != nil {
@@ -179,6 +381,31 @@ func (h *Handlers) CreateCashbookTransaction(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "Transaction created successfully"})
 }
 
+// ApproveCashbookTransaction signs off a cashbook entry made by another user.
+func (h *Handlers) ApproveCashbookTransaction(c *gin.Context) {
+	transactionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+	userID, _ := c.Get("userID")
+	err = h.DB.ApproveCashbookTransaction(transactionID, userID.(int))
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		case errors.Is(err, database.ErrAlreadyApproved):
+			c.JSON(http.StatusConflict, gin.H{"error": "Transaction is already approved"})
+		case errors.Is(err, database.ErrSelfApproval):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot approve a transaction you created"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve transaction"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction approved successfully"})
+}
+
 // Material Sale Handlers
 func (h *Handlers) CreateMaterialSale(c *gin.Context) {
 	var req models.CreateMaterialSaleRequest
@@ -192,6 +419,9 @@ func (h *Handlers) CreateMaterialSale(c *gin.Context) {
 
 	sale, err := h.DB.CreateMaterialSale(&req, req.CreatedByUserID)
 	if err != nil {
+		if respondSaleError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create material sale"})
 		return
 	}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/middleware"
+	"github.com/solaris-hms/mrf-backend/models"
+	"github.com/solaris-hms/mrf-backend/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// passwordHistoryDepth is how many recent passwords, including the current one, cannot be reused.
+	passwordHistoryDepth = 5
+	passwordResetTTL     = time.Hour
+)
+
+// ChangePassword lets a signed-in user rotate their own password. All of the
+// user's sessions are revoked and a fresh token pair is returned.
+func (h *Handlers) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	user, err := h.DB.GetUserByID(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load user"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, ok := h.hashNewPassword(c, user.ID, req.NewPassword)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.UpdateUserPassword(user.ID, hashedPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	if _, err := h.DB.RevokeUserSessions(user.ID, "Password changed"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke existing sessions"})
+		return
+	}
+
+	user.MustChangePassword = false
+	tokens, err := h.startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start session"})
+		return
+	}
+	tokens["message"] = "Password changed successfully"
+	c.JSON(http.StatusOK, tokens)
+}
+
+// ResetPassword redeems an admin-issued reset token and sets a new password.
+func (h *Handlers) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	tokenHash := hashToken(req.Token)
+	userID, err := h.DB.GetPasswordResetTokenUser(tokenHash)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token is invalid or has expired"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	hashedPassword, ok := h.hashNewPassword(c, userID, req.NewPassword)
+	if !ok {
+		return
+	}
+
+	if _, err := h.DB.ResetPasswordWithToken(tokenHash, hashedPassword); err != nil {
+		if errors.Is(err, database.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token is invalid or has expired"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	if _, err := h.DB.RevokeUserSessions(userID, "Password reset"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke existing sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully. Please log in with your new password."})
+}
+
+// IssuePasswordReset creates a one-time reset token for a user and delivers it through the notifier.
+func (h *Handlers) IssuePasswordReset(c *gin.Context) {
+	if h.Notifier == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No notification channel is configured, so reset codes cannot be delivered"})
+		return
+	}
+
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.DB.GetUserByID(userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	token, tokenHash, err := newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate reset token"})
+		return
+	}
+
+	actorID, _ := c.Get("userID")
+	expiresAt := time.Now().Add(passwordResetTTL)
+	if err := h.DB.CreatePasswordResetToken(user.ID, tokenHash, expiresAt, actorID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		return
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nA password reset was requested for your MRF account. Your reset code is:\n\n%s\n\nIt expires at %s.",
+		user.FullName, token, expiresAt.Format("02 Jan 2006 15:04 MST"))
+	if baseURL := os.Getenv("APP_BASE_URL"); baseURL != "" {
+		body += fmt.Sprintf("\n\nOr open %s/reset-password?token=%s", baseURL, token)
+	}
+	if err := h.Notifier.Send(notify.Message{To: user.Email, Subject: "Password reset", Body: body}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Reset token created but could not be delivered"})
+		return
+	}
+
+	middleware.AuditChange(c, "user.password_reset.issue", "user", strconv.Itoa(userID), nil, gin.H{"expires_at": expiresAt})
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset code issued to the configured notification channel", "expires_at": expiresAt})
+}
+
+// ForcePasswordChange sets or clears the "must change password on next login" flag.
+// Setting it signs the user out so the flag takes effect immediately.
+func (h *Handlers) ForcePasswordChange(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.ForcePasswordChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	before := h.userSnapshot(userID)
+	if err := h.DB.SetMustChangePassword(userID, *req.MustChangePassword); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if *req.MustChangePassword {
+		if _, err := h.DB.RevokeUserSessions(userID, "Password change required"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+	}
+
+	middleware.AuditChange(c, "user.force_password_change", "user", strconv.Itoa(userID), before, h.userSnapshot(userID))
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
+
+// hashNewPassword rejects recently used passwords and returns the bcrypt hash of
+// the new one. On failure it writes the response and returns false.
+func (h *Handlers) hashNewPassword(c *gin.Context, userID int, newPassword string) (string, bool) {
+	recent, err := h.DB.GetRecentPasswordHashes(userID, passwordHistoryDepth-1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check password history"})
+		return "", false
+	}
+	for _, hash := range recent {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(newPassword)) == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("New password must differ from your last %d passwords", passwordHistoryDepth)})
+			return "", false
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return "", false
+	}
+	return string(hashedPassword), true
+}
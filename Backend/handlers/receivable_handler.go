@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/documents"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// respondPaymentError writes the response for payment allocation errors the
+// client can act on and reports whether it did so.
+func respondPaymentError(c *gin.Context, err error) bool {
+	var overSale *database.AllocationError
+	switch {
+	case errors.Is(err, database.ErrPaymentPartyMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Payments can only be allocated to the paying party's sales"})
+	case errors.Is(err, database.ErrSaleCancelled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payments cannot be allocated to a cancelled sale"})
+	case errors.Is(err, database.ErrOverAllocated):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Allocations exceed the payment's unallocated amount"})
+	case errors.As(err, &overSale):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Sale %d has only %.2f outstanding", overSale.SaleID, overSale.Outstanding), "sale_id": overSale.SaleID, "outstanding": overSale.Outstanding})
+	default:
+		return false
+	}
+	return true
+}
+
+// CreateSalePayment records money received from a party against its sales.
+func (h *Handlers) CreateSalePayment(c *gin.Context) {
+	var req models.CreateSalePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", req.PaymentDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment_date must be YYYY-MM-DD"})
+		return
+	}
+	if req.Amount+req.TDSAmount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount or TDS amount is required"})
+		return
+	}
+
+	payment, err := h.DB.CreateSalePayment(&req, c.GetInt("userID"))
+	if err != nil {
+		switch {
+		case respondPaymentError(c, err):
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Party or sale not found"})
+		case errors.Is(err, database.ErrCashbookNeedsCash):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only cash payments can be posted to the cashbook"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, payment)
+}
+
+// AllocatePayment applies a payment's unallocated amount, such as an advance, to sales.
+func (h *Handlers) AllocatePayment(c *gin.Context) {
+	paymentID, err := strconv.Atoi(c.Param("paymentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+	var req models.AllocatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	payment, err := h.DB.AllocatePayment(paymentID, &req, c.GetInt("userID"))
+	if err != nil {
+		switch {
+		case respondPaymentError(c, err):
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment or sale not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to allocate payment"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// optionalPartyID reads ?party_id=, writing the error response and returning
+// false when it is not a number.
+func optionalPartyID(c *gin.Context) (*int, bool) {
+	v := c.Query("party_id")
+	if v == "" {
+		return nil, true
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid party_id"})
+		return nil, false
+	}
+	return &id, true
+}
+
+// GetSalePayments lists payments, optionally filtered by ?party_id=, ?from= and ?to=.
+func (h *Handlers) GetSalePayments(c *gin.Context) {
+	partyID, ok := optionalPartyID(c)
+	if !ok {
+		return
+	}
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	payments, err := h.DB.GetSalePayments(partyID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+		return
+	}
+	if payments == nil {
+		c.JSON(http.StatusOK, []models.SalePayment{})
+		return
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+func (h *Handlers) GetSalePayment(c *gin.Context) {
+	paymentID, err := strconv.Atoi(c.Param("paymentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+	payment, err := h.DB.GetSalePayment(paymentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment"})
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// GetReceivables returns outstanding balances and ageing per party, optionally for one ?party_id=.
+func (h *Handlers) GetReceivables(c *gin.Context) {
+	partyID, ok := optionalPartyID(c)
+	if !ok {
+		return
+	}
+	report, err := h.DB.GetReceivables(partyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receivables"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetPartyStatement returns a party's statement of account for ?from= and
+// ?to=, as JSON or, with ?format=pdf or ?format=csv, as a download.
+func (h *Handlers) GetPartyStatement(c *gin.Context) {
+	partyID, err := strconv.Atoi(c.Param("partyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid party ID"})
+		return
+	}
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "pdf" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, pdf or csv"})
+		return
+	}
+
+	st, err := h.DB.GetPartyStatement(partyID, from, to)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Party not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build statement"})
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%d-%s", partyID, time.Now().Format("20060102"))
+	switch format {
+	case "pdf":
+		pdf, err := documents.PartyStatement(documents.LetterheadFromEnv(), st)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate statement"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".pdf"))
+		c.Data(http.StatusOK, "application/pdf", pdf)
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+		c.Status(http.StatusOK)
+
+		amount := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"date", "type", "reference", "description", "debit", "credit", "balance"})
+		w.Write([]string{"", "opening_balance", "", "", "", "", amount(st.OpeningBalance)})
+		for _, l := range st.Lines {
+			w.Write([]string{l.Date.Format("2006-01-02"), l.Type, l.Reference, l.Description, amount(l.Debit), amount(l.Credit), amount(l.Balance)})
+		}
+		w.Write([]string{"", "closing_balance", "", "", amount(st.TotalDebit), amount(st.TotalCredit), amount(st.ClosingBalance)})
+		w.Flush()
+	default:
+		c.JSON(http.StatusOK, st)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/solaris-hms/mrf-backend/config"
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/middleware"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// GetRoleTemplates lists the built-in role templates a new role can start from.
+func (h *Handlers) GetRoleTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, config.RoleTemplates)
+}
+
+// CreateRole adds a role, optionally pre-filled with a template's permissions.
+func (h *Handlers) CreateRole(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	var template *string
+	var actions []string
+	if req.Template != "" {
+		t, ok := findRoleTemplate(req.Template)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role template: " + req.Template})
+			return
+		}
+		template = &t.Name
+		actions = t.Permissions
+		if req.Description == "" {
+			req.Description = t.Description
+		}
+	}
+
+	role, err := h.DB.CreateRole(req.Name, req.Description, template, req.PermissionIDs, actions)
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A role with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+	middleware.AuditChange(c, "role.create", "role", strconv.Itoa(role.ID), nil, h.roleSnapshot(role.ID))
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole renames a role or changes its description.
+func (h *Handlers) UpdateRole(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	before := h.roleSnapshot(roleID)
+	if err := h.DB.UpdateRole(roleID, &req); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		case isUniqueViolation(err):
+			c.JSON(http.StatusConflict, gin.H{"error": "A role with this name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		}
+		return
+	}
+	middleware.AuditChange(c, "role.update", "role", strconv.Itoa(roleID), before, h.roleSnapshot(roleID))
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
+}
+
+// DeleteRole removes a role that is no longer assigned to anyone.
+func (h *Handlers) DeleteRole(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	before := h.roleSnapshot(roleID)
+	if err := h.DB.DeleteRole(roleID); err != nil {
+		var inUseErr *database.RoleInUseError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		case errors.As(err, &inUseErr):
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Role is still assigned to %d user(s); reassign them first", inUseErr.Users)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		}
+		return
+	}
+	middleware.AuditChange(c, "role.delete", "role", strconv.Itoa(roleID), before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// CloneRole creates a new role with the same permissions as an existing one.
+func (h *Handlers) CloneRole(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req models.CloneRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	role, err := h.DB.CloneRole(roleID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		case isUniqueViolation(err):
+			c.JSON(http.StatusConflict, gin.H{"error": "A role with this name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clone role"})
+		}
+		return
+	}
+	middleware.AuditChange(c, "role.clone", "role", strconv.Itoa(role.ID), gin.H{"cloned_from_role_id": roleID}, h.roleSnapshot(role.ID))
+	c.JSON(http.StatusCreated, role)
+}
+
+func findRoleTemplate(name string) (models.RoleTemplate, bool) {
+	for _, t := range config.RoleTemplates {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return models.RoleTemplate{}, false
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+func respondLastAdmin(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{"error": "This change would leave no active user with manage:users"})
+}
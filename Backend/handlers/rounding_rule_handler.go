@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+func (h *Handlers) GetRoundingRules(c *gin.Context) {
+	rules, err := h.DB.GetRoundingRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rounding rules"})
+		return
+	}
+	if rules == nil {
+		c.JSON(http.StatusOK, []models.RoundingRule{})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetRoundingRule changes how sale amounts, GST or totals are rounded. It
+// applies to sales recorded afterwards; existing sales keep their breakdown.
+func (h *Handlers) SetRoundingRule(c *gin.Context) {
+	var req models.UpdateRoundingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	rule, err := h.DB.SetRoundingRule(c.Param("scope"), &req, c.GetInt("userID"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rounding scope must be amount, gst or total"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rounding rule"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/billing"
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Dispute evidence is kept outside the publicly served uploads directory.
+const saleDisputeAttachmentDir = "attachments/sale_disputes"
+
+// respondSaleError writes the response for sale validation errors the client
+// can act on and reports whether it did so.
+func respondSaleError(c *gin.Context, err error) bool {
+	var mismatch *database.SaleMismatchError
+	var overDeduction *database.DeductionToleranceError
+	var amountMismatch *billing.MismatchError
+	var badShare *database.WeightShareError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inward entry not found"})
+	case errors.Is(err, database.ErrEntryNotWeighed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Inward entry has no net weight yet"})
+	case errors.Is(err, database.ErrEntryNotDispatch):
+		c.JSON(http.StatusConflict, gin.H{"error": "Only outgoing entries can be sold"})
+	case errors.Is(err, database.ErrEntryFullyBilled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Inward entry is already fully billed"})
+	case errors.As(err, &badShare):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Weight share must be positive and at most the %.3f t left unbilled", badShare.UnbilledTons), "unbilled_weight_tons": badShare.UnbilledTons})
+	case errors.Is(err, database.ErrInvalidDeduction):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Deduction type must be none, percentage or fixed, and cannot exceed the weight"})
+	case errors.As(err, &mismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Submitted " + mismatch.Field + " does not match the weighed entry", "field": mismatch.Field, "expected": mismatch.Expected, "submitted": mismatch.Submitted})
+	case errors.Is(err, database.ErrInvalidSaleRate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rate and GST percentage cannot be negative"})
+	case errors.As(err, &amountMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Submitted " + amountMismatch.Field + " does not match the computed figure", "field": amountMismatch.Field, "expected": amountMismatch.Expected, "submitted": amountMismatch.Submitted})
+	case errors.As(err, &overDeduction):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Deduction of %.2f%% exceeds the %.2f%% allowed", overDeduction.Percent, overDeduction.MaxPercent), "max_deduction_percent": overDeduction.MaxPercent})
+	default:
+		return false
+	}
+	return true
+}
+
+func (h *Handlers) GetMaterialTolerances(c *gin.Context) {
+	tolerances, err := h.DB.GetMaterialTolerances()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tolerances"})
+		return
+	}
+	if tolerances == nil {
+		c.JSON(http.StatusOK, []models.MaterialTolerance{})
+		return
+	}
+	c.JSON(http.StatusOK, tolerances)
+}
+
+// SetMaterialTolerance creates or replaces a material's tolerances; "*" is the default.
+func (h *Handlers) SetMaterialTolerance(c *gin.Context) {
+	material := strings.TrimSpace(c.Param("material"))
+	if material == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Material is required"})
+		return
+	}
+	var req models.UpdateMaterialToleranceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	tolerance, err := h.DB.SetMaterialTolerance(material, &req, c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tolerance"})
+		return
+	}
+	c.JSON(http.StatusOK, tolerance)
+}
+
+// OpenSaleDispute records a buyer's weighbridge figure reported after the
+// sale. Figures within tolerance are refused rather than opened as disputes.
+func (h *Handlers) OpenSaleDispute(c *gin.Context) {
+	saleID, err := strconv.Atoi(c.Param("saleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sale ID"})
+		return
+	}
+	var req models.OpenSaleDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	dispute, err := h.DB.OpenSaleDispute(saleID, &req, c.GetInt("userID"))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return
+	case errors.Is(err, database.ErrWithinTolerance):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Buyer weight is within the material's tolerance"})
+		return
+	case errors.Is(err, database.ErrDisputeAlreadyOpen):
+		c.JSON(http.StatusConflict, gin.H{"error": "Sale already has an unresolved dispute"})
+		return
+	case errors.Is(err, database.ErrSaleCancelled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Sale has been cancelled"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open dispute"})
+		return
+	}
+	c.JSON(http.StatusCreated, dispute)
+}
+
+// GetSaleDisputes lists disputes, optionally filtered by ?status=.
+func (h *Handlers) GetSaleDisputes(c *gin.Context) {
+	disputes, err := h.DB.GetSaleDisputes(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disputes"})
+		return
+	}
+	if disputes == nil {
+		c.JSON(http.StatusOK, []models.SaleDispute{})
+		return
+	}
+	c.JSON(http.StatusOK, disputes)
+}
+
+func (h *Handlers) GetSaleDispute(c *gin.Context) {
+	disputeID, ok := disputeIDParam(c)
+	if !ok {
+		return
+	}
+	dispute, err := h.DB.GetSaleDispute(disputeID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dispute"})
+		return
+	}
+	c.JSON(http.StatusOK, dispute)
+}
+
+// UpdateSaleDispute moves a dispute under review or resolves it.
+func (h *Handlers) UpdateSaleDispute(c *gin.Context) {
+	disputeID, ok := disputeIDParam(c)
+	if !ok {
+		return
+	}
+	var req models.UpdateSaleDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	dispute, err := h.DB.UpdateSaleDispute(disputeID, &req, c.GetInt("userID"))
+	var transitionErr *database.TransitionError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		return
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Dispute is %s and cannot be moved to %s", transitionErr.From, transitionErr.To)})
+		return
+	case errors.Is(err, database.ErrInvalidResolution):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Resolving needs a resolution, and a settlement needs resolved_weight_tons"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dispute"})
+		return
+	}
+	c.JSON(http.StatusOK, dispute)
+}
+
+// UploadSaleDisputeAttachments stores the "attachments" files of a multipart
+// form as evidence for the dispute.
+func (h *Handlers) UploadSaleDisputeAttachments(c *gin.Context) {
+	disputeID, ok := disputeIDParam(c)
+	if !ok {
+		return
+	}
+	if _, err := h.DB.GetSaleDispute(disputeID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
+		return
+	}
+	files := form.File["attachments"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No files uploaded"})
+		return
+	}
+	if err := os.MkdirAll(saleDisputeAttachmentDir, 0750); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create attachment directory"})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	var saved []models.SaleDisputeAttachment
+	for i, fileHeader := range files {
+		ext := filepath.Ext(fileHeader.Filename)
+		filename := fmt.Sprintf("dispute_%d_%d_%d%s", disputeID, time.Now().UnixNano(), i, ext)
+		dst := filepath.Join(saleDisputeAttachmentDir, filename)
+		if err := c.SaveUploadedFile(fileHeader, dst); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save file"})
+			return
+		}
+
+		attachment, err := h.DB.CreateSaleDisputeAttachment(&models.SaleDisputeAttachment{
+			DisputeID:        disputeID,
+			FileName:         filename,
+			OriginalName:     filepath.Base(fileHeader.Filename),
+			FileSize:         fileHeader.Size,
+			FileType:         fileHeader.Header.Get("Content-Type"),
+			FilePath:         dst,
+			UploadedByUserID: &userID,
+		})
+		if err != nil {
+			os.Remove(dst)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save attachment info to database"})
+			return
+		}
+		saved = append(saved, *attachment)
+	}
+	c.JSON(http.StatusCreated, saved)
+}
+
+func (h *Handlers) DownloadSaleDisputeAttachment(c *gin.Context) {
+	disputeID, ok := disputeIDParam(c)
+	if !ok {
+		return
+	}
+	attachmentID, err := strconv.Atoi(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	attachment, err := h.DB.GetSaleDisputeAttachment(disputeID, attachmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	if _, err := os.Stat(attachment.FilePath); os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found on server"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.OriginalName))
+	c.Header("Content-Type", attachment.FileType)
+	c.File(attachment.FilePath)
+}
+
+func disputeIDParam(c *gin.Context) (int, bool) {
+	disputeID, err := strconv.Atoi(c.Param("disputeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispute ID"})
+		return 0, false
+	}
+	return disputeID, true
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/solaris-hms/mrf-backend/billing"
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/documents"
+	"github.com/solaris-hms/mrf-backend/einvoice"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// UpdatePartnerTaxDetails sets the GSTIN and address a partner is invoiced at.
+func (h *Handlers) UpdatePartnerTaxDetails(c *gin.Context) {
+	partnerID, err := strconv.Atoi(c.Param("partnerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid partner ID"})
+		return
+	}
+	var req models.UpdatePartnerTaxDetailsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	partner, err := h.DB.UpdatePartnerTaxDetails(partnerID, &req)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partner not found"})
+		return
+	case errors.Is(err, database.ErrInvalidGSTIN):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "GSTIN is not valid"})
+		return
+	case errors.Is(err, database.ErrInvalidStateCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "State code is not valid"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update partner"})
+		return
+	}
+	c.JSON(http.StatusOK, partner)
+}
+
+func (h *Handlers) GetMaterialHSNCodes(c *gin.Context) {
+	codes, err := h.DB.GetMaterialHSNCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch HSN codes"})
+		return
+	}
+	if codes == nil {
+		c.JSON(http.StatusOK, []models.MaterialHSNCode{})
+		return
+	}
+	c.JSON(http.StatusOK, codes)
+}
+
+func (h *Handlers) SetMaterialHSNCode(c *gin.Context) {
+	material := strings.TrimSpace(c.Param("material"))
+	if material == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Material is required"})
+		return
+	}
+	var req models.UpdateMaterialHSNCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	code, err := h.DB.SetMaterialHSNCode(material, &req, c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save HSN code"})
+		return
+	}
+	c.JSON(http.StatusOK, code)
+}
+
+// IssueTaxInvoice issues the next numbered tax invoice for a sale.
+func (h *Handlers) IssueTaxInvoice(c *gin.Context) {
+	saleID, err := strconv.Atoi(c.Param("saleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sale ID"})
+		return
+	}
+	seller, err := billing.SellerFromEnv()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Invoicing is not configured: INVOICE_SELLER_GSTIN is missing or invalid"})
+		return
+	}
+
+	invoice, err := h.DB.IssueTaxInvoice(saleID, seller, c.GetInt("userID"))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return
+	case errors.Is(err, database.ErrSaleAlreadyInvoiced):
+		c.JSON(http.StatusConflict, gin.H{"error": "Sale already has a tax invoice"})
+		return
+	case errors.Is(err, database.ErrSaleCancelled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Sale has been cancelled"})
+		return
+	case errors.Is(err, database.ErrMissingHSNCode):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Set an HSN code for the sale's material first"})
+		return
+	case errors.Is(err, database.ErrBuyerStateUnknown):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Set the party's GSTIN or state code first"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue invoice"})
+		return
+	}
+	c.JSON(http.StatusCreated, invoice)
+}
+
+// GetTaxInvoices lists invoices, optionally dated within ?from= and ?to=.
+func (h *Handlers) GetTaxInvoices(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	invoices, err := h.DB.GetTaxInvoices(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
+		return
+	}
+	if invoices == nil {
+		c.JSON(http.StatusOK, []models.TaxInvoice{})
+		return
+	}
+	c.JSON(http.StatusOK, invoices)
+}
+
+// taxInvoiceParam loads the invoice named by :invoiceId, writing the error
+// response and returning nil when it cannot.
+func (h *Handlers) taxInvoiceParam(c *gin.Context) *models.TaxInvoice {
+	invoiceID, err := strconv.Atoi(c.Param("invoiceId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return nil
+	}
+	invoice, err := h.DB.GetTaxInvoice(invoiceID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return nil
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoice"})
+		return nil
+	}
+	return invoice
+}
+
+func (h *Handlers) GetTaxInvoice(c *gin.Context) {
+	if invoice := h.taxInvoiceParam(c); invoice != nil {
+		c.JSON(http.StatusOK, invoice)
+	}
+}
+
+func (h *Handlers) DownloadTaxInvoice(c *gin.Context) {
+	invoice := h.taxInvoiceParam(c)
+	if invoice == nil {
+		return
+	}
+	pdf, err := documents.TaxInvoice(documents.LetterheadFromEnv(), invoice)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invoice"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoiceFileName(invoice)+".pdf"))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// ExportEInvoice downloads one invoice in the e-invoice schema.
+func (h *Handlers) ExportEInvoice(c *gin.Context) {
+	invoice := h.taxInvoiceParam(c)
+	if invoice == nil {
+		return
+	}
+	doc, err := einvoice.FromTaxInvoice(invoice)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "E-invoices are only issued to buyers with a GSTIN"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoiceFileName(invoice)+".json"))
+	c.JSON(http.StatusOK, doc)
+}
+
+// ExportEInvoices downloads the invoices dated within ?from= and ?to= as one
+// bulk upload file. Invoices to unregistered buyers and cancelled invoices
+// are left out.
+func (h *Handlers) ExportEInvoices(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	invoices, err := h.DB.GetTaxInvoices(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
+		return
+	}
+	docs := []*einvoice.Document{}
+	for i := range invoices {
+		if invoices[i].CreditNoteNumber != nil {
+			continue
+		}
+		if doc, err := einvoice.FromTaxInvoice(&invoices[i]); err == nil {
+			docs = append(docs, doc)
+		}
+	}
+	c.Header("Content-Disposition", `attachment; filename="e-invoices.json"`)
+	c.JSON(http.StatusOK, docs)
+}
+
+func invoiceFileName(invoice *models.TaxInvoice) string {
+	return strings.ReplaceAll(invoice.InvoiceNumber, "/", "-")
+}
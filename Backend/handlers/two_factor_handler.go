@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/middleware"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// loginChallengeTTL is how long a user has to enter their code after the password step.
+	loginChallengeTTL = 5 * time.Minute
+	// maxChallengeFailures wrong codes invalidate a login challenge.
+	maxChallengeFailures = 5
+	recoveryCodeCount    = 10
+	totpPeriod           = 30
+)
+
+var totpOpts = totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+// issueLoginChallenge answers a correct password for a user with two-factor
+// enabled: instead of tokens the client gets a short-lived challenge to complete
+// with LoginTwoFactor.
+func (h *Handlers) issueLoginChallenge(c *gin.Context, user *models.User) {
+	challenge, challengeHash, err := newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+	if err := h.DB.CreateLoginChallenge(user.ID, challengeHash, time.Now().Add(loginChallengeTTL)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start login"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"two_factor_required": true,
+		"challenge_token":     challenge,
+		"expires_in":          int(loginChallengeTTL.Seconds()),
+	})
+}
+
+// LoginTwoFactor completes a login challenge with an authenticator or recovery code.
+func (h *Handlers) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either code or recovery_code"})
+		return
+	}
+
+	challengeHash := hashToken(req.ChallengeToken)
+	userID, err := h.DB.GetLoginChallengeUser(challengeHash, maxChallengeFailures)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidLoginChallenge) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login has expired, please sign in again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return
+	}
+
+	user, err := h.DB.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return
+	}
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		tooManyAttempts(c, time.Until(*user.LockedUntil), "Too many failed login attempts. Please wait before trying again.")
+		return
+	}
+	if user.Status != models.UserStatusActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account is no longer active"})
+		return
+	}
+
+	state, err := h.DB.GetTwoFactorState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return
+	}
+
+	var valid bool
+	if req.RecoveryCode != "" {
+		valid, err = h.DB.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(req.RecoveryCode)))
+	} else if state.Enabled() {
+		valid, err = h.acceptTOTPCode(userID, *state.Secret, req.Code, state.LastUsedStep)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login"})
+		return
+	}
+	if !valid {
+		h.DB.RecordLoginChallengeFailure(challengeHash)
+		h.registerFailedLogin(c, user, "bad_second_factor")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	if err := h.DB.ConsumeLoginChallenge(challengeHash); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login has expired, please sign in again"})
+		return
+	}
+	h.completeLogin(c, user)
+}
+
+// GetTwoFactorStatus reports whether the caller has two-factor enabled and whether their roles require it.
+func (h *Handlers) GetTwoFactorStatus(c *gin.Context) {
+	userID := c.GetInt("userID")
+	state, err := h.DB.GetTwoFactorState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve two-factor status"})
+		return
+	}
+	status := models.TwoFactorStatus{Enabled: state.Enabled(), EnabledAt: state.EnabledAt, Required: state.Required}
+	if status.Enabled {
+		status.RecoveryCodesRemaining, err = h.DB.CountUnusedRecoveryCodes(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve two-factor status"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollTwoFactor starts TOTP enrolment and returns the secret and provisioning
+// URI for the authenticator app. Enrolment completes with ConfirmTwoFactor.
+func (h *Handlers) EnrollTwoFactor(c *gin.Context) {
+	userID := c.GetInt("userID")
+	state, err := h.DB.GetTwoFactorState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrolment"})
+		return
+	}
+	if state.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	user, err := h.DB.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrolment"})
+		return
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "MRF Management"
+	}
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: issuer, AccountName: user.Email, Period: totpPeriod})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := h.DB.SetPendingTOTPSecret(userID, key.Secret()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrolment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorEnrollment{Secret: key.Secret(), ProvisioningURI: key.String()})
+}
+
+// ConfirmTwoFactor activates TOTP once the user proves their app produces valid
+// codes. It returns the recovery codes, shown only this once, and fresh tokens
+// since the old ones may carry the enrolment-required flag.
+func (h *Handlers) ConfirmTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	state, err := h.DB.GetTwoFactorState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm enrolment"})
+		return
+	}
+	if state.PendingSecret == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Start enrolment before confirming it"})
+		return
+	}
+	step, ok := matchTOTPCode(*state.PendingSecret, req.Code, nil)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := h.DB.EnableTOTP(userID, step, hashes); err != nil {
+		if errors.Is(err, database.ErrNoPendingTwoFactor) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Start enrolment before confirming it"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm enrolment"})
+		return
+	}
+
+	tokens, ok := h.restartSession(c, userID, "Two-factor authentication enabled")
+	if !ok {
+		return
+	}
+	tokens["message"] = "Two-factor authentication enabled"
+	tokens["recovery_codes"] = codes
+	c.JSON(http.StatusOK, tokens)
+}
+
+// DisableTwoFactor turns TOTP off after re-checking the password and a current
+// code. Users whose roles require two-factor cannot disable it.
+func (h *Handlers) DisableTwoFactor(c *gin.Context) {
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	state, err := h.DB.GetTwoFactorState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	if !state.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if state.Required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role requires two-factor authentication"})
+		return
+	}
+
+	user, err := h.DB.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	valid, err := h.acceptTOTPCode(userID, *state.Secret, req.Code, state.LastUsedStep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	if err := h.DB.DisableTOTP(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes after checking a current code.
+func (h *Handlers) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	state, err := h.DB.GetTwoFactorState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+	if !state.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	valid, err := h.acceptTOTPCode(userID, *state.Secret, req.Code, state.LastUsedStep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := h.DB.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor lets an admin remove a user's TOTP setup, e.g. after a lost
+// phone. The user is signed out and, if their role requires it, must enrol again.
+func (h *Handlers) ResetUserTwoFactor(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.DB.DisableTOTP(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	if _, err := h.DB.RevokeUserSessions(userID, "Two-factor authentication reset"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	middleware.AuditChange(c, "user.two_factor.reset", "user", strconv.Itoa(userID), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// SetRoleTwoFactorPolicy sets whether holders of a role must use two-factor authentication.
+func (h *Handlers) SetRoleTwoFactorPolicy(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req models.RoleTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	before := h.roleSnapshot(roleID)
+	if err := h.DB.SetRoleTwoFactorRequired(roleID, *req.Required); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	middleware.AuditChange(c, "role.two_factor_policy", "role", strconv.Itoa(roleID), before, h.roleSnapshot(roleID))
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor policy updated successfully"})
+}
+
+// restartSession revokes the caller's sessions and opens a new one. On failure
+// it writes the response and returns false.
+func (h *Handlers) restartSession(c *gin.Context, userID int, reason string) (gin.H, bool) {
+	user, err := h.DB.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start session"})
+		return nil, false
+	}
+	if _, err := h.DB.RevokeUserSessions(userID, reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke existing sessions"})
+		return nil, false
+	}
+	tokens, err := h.startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start session"})
+		return nil, false
+	}
+	return tokens, true
+}
+
+// acceptTOTPCode checks a code and records its time step so it cannot be replayed.
+func (h *Handlers) acceptTOTPCode(userID int, secret, code string, lastUsedStep *int64) (bool, error) {
+	step, ok := matchTOTPCode(secret, code, lastUsedStep)
+	if !ok {
+		return false, nil
+	}
+	return h.DB.MarkTOTPStepUsed(userID, step)
+}
+
+// matchTOTPCode accepts codes from the current time step and one step either
+// side for clock drift, skipping steps at or before lastUsedStep. It returns the matching step.
+func matchTOTPCode(secret, code string, lastUsedStep *int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	now := time.Now()
+	for _, offset := range []int{-1, 0, 1} {
+		t := now.Add(time.Duration(offset*totpPeriod) * time.Second)
+		step := t.Unix() / totpPeriod
+		if lastUsedStep != nil && step <= *lastUsedStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, t, totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// newRecoveryCodes returns fresh recovery codes (xxxxx-xxxxx) and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < recoveryCodeCount; i++ {
+		var b strings.Builder
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, nil, err
+			}
+			b.WriteByte(recoveryCodeAlphabet[n.Int64()])
+		}
+		code := b.String()
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
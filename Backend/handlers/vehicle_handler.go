@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetVehicles looks vehicles up for the gate autocomplete. ?q= matches any
+// part of the registration number regardless of spacing; ?limit= caps the
+// result (default 20).
+func (h *Handlers) GetVehicles(c *gin.Context) {
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+			return
+		}
+		limit = n
+	}
+
+	vehicles, err := h.DB.SearchVehicles(c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vehicles"})
+		return
+	}
+	if vehicles == nil {
+		c.JSON(http.StatusOK, []models.Vehicle{})
+		return
+	}
+	c.JSON(http.StatusOK, vehicles)
+}
+
+// GetVehicleByRegistration returns the vehicle with the given registration
+// number in any formatting, so the gate can show its tare and blacklist state.
+func (h *Handlers) GetVehicleByRegistration(c *gin.Context) {
+	vehicle, err := h.DB.GetVehicleByRegistration(c.Param("registration"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not registered"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vehicle"})
+		return
+	}
+	c.JSON(http.StatusOK, vehicle)
+}
+
+func (h *Handlers) CreateVehicle(c *gin.Context) {
+	var req models.CreateVehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	vehicle, err := h.DB.CreateVehicle(&req, c.GetInt("userID"))
+	if err != nil {
+		respondVehicleError(c, err, "Failed to register vehicle")
+		return
+	}
+	c.JSON(http.StatusCreated, vehicle)
+}
+
+func (h *Handlers) UpdateVehicle(c *gin.Context) {
+	vehicleID, ok := vehicleIDParam(c)
+	if !ok {
+		return
+	}
+	var req models.UpdateVehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	vehicle, err := h.DB.UpdateVehicle(vehicleID, &req)
+	if err != nil {
+		respondVehicleError(c, err, "Failed to update vehicle")
+		return
+	}
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// SetVehicleTare stores the vehicle's empty weight, captured from the
+// weighbridge unless manual_weight is set, valid through valid_until.
+func (h *Handlers) SetVehicleTare(c *gin.Context) {
+	vehicleID, ok := vehicleIDParam(c)
+	if !ok {
+		return
+	}
+	var req models.SetVehicleTareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	validUntil, err := time.Parse("2006-01-02", req.ValidUntil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_until must be a date (YYYY-MM-DD)"})
+		return
+	}
+	y, m, d := time.Now().Date()
+	if validUntil.Before(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_until must not be in the past"})
+		return
+	}
+	tareTons, _, ok := h.captureWeight(c, req.TareWeightTons, req.ManualWeight)
+	if !ok {
+		return
+	}
+	if tareTons <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tare weight must be positive"})
+		return
+	}
+
+	vehicle, err := h.DB.SetVehicleTare(vehicleID, tareTons, validUntil, c.GetInt("userID"))
+	if err != nil {
+		respondVehicleError(c, err, "Failed to store tare weight")
+		return
+	}
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// SetVehicleBlacklist blacklists a vehicle so it is refused at gate-in, or
+// lifts the blacklist. A reason is required when blacklisting.
+func (h *Handlers) SetVehicleBlacklist(c *gin.Context) {
+	vehicleID, ok := vehicleIDParam(c)
+	if !ok {
+		return
+	}
+	var req models.SetVehicleBlacklistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Blacklisted && req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to blacklist a vehicle"})
+		return
+	}
+
+	vehicle, err := h.DB.SetVehicleBlacklist(vehicleID, req.Blacklisted, req.Reason, c.GetInt("userID"))
+	if err != nil {
+		respondVehicleError(c, err, "Failed to update blacklist")
+		return
+	}
+	c.JSON(http.StatusOK, vehicle)
+}
+
+func vehicleIDParam(c *gin.Context) (int, bool) {
+	vehicleID, err := strconv.Atoi(c.Param("vehicleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
+		return 0, false
+	}
+	return vehicleID, true
+}
+
+func respondVehicleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+	case errors.Is(err, database.ErrInvalidRegistration):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid registration number"})
+	case isUniqueViolation(err):
+		c.JSON(http.StatusConflict, gin.H{"error": "A vehicle with this registration number is already registered"})
+	case isForeignKeyViolation(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Owner partner does not exist"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/solaris-hms/mrf-backend/models"
 )
 
@@ -65,6 +67,27 @@ func (h *Handlers) GetVendor(c *gin.Context) {
 	c.JSON(http.StatusOK, vendor)
 }
 
+// GetVendorReceipts summarises tonnage and trips received from a vendor,
+// optionally limited to ?from= and ?to=.
+func (h *Handlers) GetVendorReceipts(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	receipts, err := h.DB.GetVendorReceipts(c.Param("id"), from, to)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vendor not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vendor receipts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, receipts)
+}
+
 // UpdateVendor handles updating vendor information
 func (h *Handlers) UpdateVendor(c *gin.Context) {
 	vendorID := c.Param("id")
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/solaris-hms/mrf-backend/weighbridge"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWeighbridgeReading returns the indicator's current weight.
+func (h *Handlers) GetWeighbridgeReading(c *gin.Context) {
+	if h.Weighbridge == nil {
+		c.JSON(http.StatusOK, gin.H{"connected": false, "configured": false})
+		return
+	}
+	reading, err := h.Weighbridge.Current()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"connected": false, "configured": true})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"connected": true, "configured": true, "reading": reading})
+}
+
+// StreamWeighbridgeReadings pushes every indicator reading as a server-sent event.
+func (h *Handlers) StreamWeighbridgeReadings(c *gin.Context) {
+	if h.Weighbridge == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No weighbridge is connected"})
+		return
+	}
+
+	readings, stop := h.Weighbridge.Subscribe()
+	defer stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case reading := <-readings:
+			c.SSEvent("reading", reading)
+			return true
+		}
+	})
+}
+
+// captureWeight decides the weight to record for a weighing. Without a
+// weighbridge, or when the operator overrides it, the keyed weight is used and
+// flagged as manual; otherwise the locked indicator reading is captured. It
+// writes the response and returns ok=false when the weighing cannot go ahead.
+func (h *Handlers) captureWeight(c *gin.Context, keyedTons float64, manual bool) (tons float64, isManual bool, ok bool) {
+	if h.Weighbridge == nil || manual {
+		if keyedTons <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A weight greater than zero is required"})
+			return 0, false, false
+		}
+		return keyedTons, true, true
+	}
+
+	reading, err := h.Weighbridge.Locked()
+	switch {
+	case errors.Is(err, weighbridge.ErrNoReading):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The weighbridge is not responding. Key the weight manually if needed."})
+		return 0, false, false
+	case errors.Is(err, weighbridge.ErrUnstable):
+		c.JSON(http.StatusConflict, gin.H{"error": "The weighbridge reading is not stable yet", "reading": reading})
+		return 0, false, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the weighbridge"})
+		return 0, false, false
+	}
+	if reading.WeightKg <= 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The weighbridge shows no vehicle on the platform", "reading": reading})
+		return 0, false, false
+	}
+	return reading.WeightTons, false, true
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/documents"
+	"github.com/solaris-hms/mrf-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// DownloadWeighmentSlip returns the PDF weighment slip for a weighed entry.
+// The first download numbers the slip; later ones are marked DUPLICATE.
+func (h *Handlers) DownloadWeighmentSlip(c *gin.Context) {
+	entryID, err := strconv.Atoi(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	slip, err := h.DB.PrintWeighmentSlip(entryID, c.GetInt("userID"))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entry not found"})
+		return
+	case errors.Is(err, database.ErrEntryNotWeighed):
+		c.JSON(http.StatusConflict, gin.H{"error": "A slip can only be printed after the second weighing"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record slip print"})
+		return
+	}
+
+	verifyURL := publicBaseURL(c) + "/api/verify/weighment-slips/" + slip.VerificationToken
+	pdf, err := documents.WeighmentSlip(documents.LetterheadFromEnv(), slip, verifyURL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate slip"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", slip.SlipNumber+".pdf"))
+	c.Header("X-Slip-Number", slip.SlipNumber)
+	c.Header("X-Slip-Print-Count", strconv.Itoa(slip.PrintCount))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// VerifyWeighmentSlip is the public page behind a slip's QR code. It shows
+// only what is printed on the slip, so a party can confirm it is genuine.
+func (h *Handlers) VerifyWeighmentSlip(c *gin.Context) {
+	slip, status, err := h.DB.GetWeighmentSlipByToken(c.Param("token"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown weighment slip"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify slip"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WeighmentSlipVerification{
+		SlipNumber:      slip.SlipNumber,
+		VehicleNumber:   slip.VehicleNumber,
+		Material:        slip.Material,
+		NetWeightTons:   slip.NetWeightTons,
+		SecondWeighedAt: slip.SecondWeighedAt,
+		PrintCount:      slip.PrintCount,
+		Status:          status,
+	})
+}
+
+// publicBaseURL is APP_BASE_URL, or the scheme and host the request arrived on.
+func publicBaseURL(c *gin.Context) string {
+	if baseURL := os.Getenv("APP_BASE_URL"); baseURL != "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
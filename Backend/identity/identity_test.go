@@ -0,0 +1,114 @@
+package identity_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/solaris-hms/mrf-backend/identity"
+	"github.com/solaris-hms/mrf-backend/identity/oidctest"
+)
+
+func newProvider(t *testing.T) (*oidctest.Issuer, *identity.OIDCProvider) {
+	t.Helper()
+	iss := oidctest.NewIssuer(t, "mrf")
+	provider := identity.NewOIDCProvider(identity.OIDCConfig{
+		Issuer:       iss.URL,
+		ClientID:     iss.ClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:5173/login/oidc",
+	})
+	return iss, provider
+}
+
+// startLogin runs the first leg of a login and returns the request and code.
+func startLogin(t *testing.T, iss *oidctest.Issuer, provider *identity.OIDCProvider, login oidctest.Login) (identity.AuthRequest, string) {
+	t.Helper()
+	req, err := identity.NewAuthRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(context.Background(), req)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state := iss.Authorize(t, authURL, login)
+	if state != req.State {
+		t.Fatalf("issuer returned state %q, want %q", state, req.State)
+	}
+	return req, code
+}
+
+func TestOIDCExchange(t *testing.T) {
+	iss, provider := newProvider(t)
+	req, code := startLogin(t, iss, provider, oidctest.Login{
+		Subject:       "user-1",
+		Email:         " operator@example.com ",
+		EmailVerified: true,
+		Name:          "Plant Operator",
+	})
+
+	claims, err := provider.Exchange(context.Background(), code, req)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := identity.Claims{
+		Issuer:        iss.URL,
+		Subject:       "user-1",
+		Email:         "operator@example.com",
+		EmailVerified: true,
+		Name:          "Plant Operator",
+	}
+	if *claims != want {
+		t.Fatalf("claims = %+v, want %+v", *claims, want)
+	}
+}
+
+func TestOIDCExchangeUnverifiedEmail(t *testing.T) {
+	iss, provider := newProvider(t)
+	req, code := startLogin(t, iss, provider, oidctest.Login{Subject: "user-2", Email: "new@example.com"})
+
+	claims, err := provider.Exchange(context.Background(), code, req)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.EmailVerified {
+		t.Fatal("email reported verified although the issuer did not verify it")
+	}
+}
+
+func TestOIDCExchangeRejectsBadNonce(t *testing.T) {
+	iss, provider := newProvider(t)
+	req, code := startLogin(t, iss, provider, oidctest.Login{Subject: "user-1", Nonce: "replayed-nonce"})
+
+	_, err := provider.Exchange(context.Background(), code, req)
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("Exchange error = %v, want nonce mismatch", err)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	iss, provider := newProvider(t)
+	req, code := startLogin(t, iss, provider, oidctest.Login{Subject: "user-1"})
+
+	other, err := identity.NewAuthRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.CodeVerifier = other.CodeVerifier
+	if _, err := provider.Exchange(context.Background(), code, req); err == nil {
+		t.Fatal("Exchange succeeded with another login's PKCE verifier")
+	}
+}
+
+func TestOIDCExchangeCodeIsSingleUse(t *testing.T) {
+	iss, provider := newProvider(t)
+	req, code := startLogin(t, iss, provider, oidctest.Login{Subject: "user-1"})
+
+	if _, err := provider.Exchange(context.Background(), code, req); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, req); err == nil {
+		t.Fatal("second Exchange with the same code succeeded")
+	}
+}
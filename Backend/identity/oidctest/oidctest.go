@@ -0,0 +1,208 @@
+// Package oidctest runs a minimal OpenID Connect issuer for tests. It serves
+// discovery, a JWKS and a token endpoint that hands out RS256-signed id_tokens,
+// so identity.OIDCProvider and the login handlers can be exercised end to end
+// without a real identity provider.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "oidctest-key"
+
+// Login is the account the test user signs in with at the issuer.
+type Login struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Nonce replaces the nonce from the authorization URL in the id_token when set.
+	Nonce string
+}
+
+type grant struct {
+	login     Login
+	nonce     string
+	challenge string
+}
+
+// Issuer is a running mock identity provider.
+type Issuer struct {
+	// URL is the issuer identifier and base URL of the server.
+	URL      string
+	ClientID string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// NewIssuer starts an issuer accepting clientID. It is shut down when the test ends.
+func NewIssuer(t testing.TB, clientID string) *Issuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate signing key: %v", err)
+	}
+	iss := &Issuer{ClientID: clientID, key: key, grants: make(map[string]grant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", iss.serveDiscovery)
+	mux.HandleFunc("GET /jwks", iss.serveJWKS)
+	mux.HandleFunc("POST /token", iss.serveToken)
+	iss.server = httptest.NewServer(mux)
+	iss.URL = iss.server.URL
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+// Authorize plays the user's visit to authURL: it signs in as login and returns
+// the code and state the issuer would redirect back with.
+func (iss *Issuer) Authorize(t testing.TB, authURL string, login Login) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	q := u.Query()
+	if got := u.Scheme + "://" + u.Host + u.Path; got != iss.URL+"/authorize" {
+		t.Fatalf("authorization URL points at %s, want %s/authorize", got, iss.URL)
+	}
+	if q.Get("client_id") != iss.ClientID {
+		t.Fatalf("authorization URL has client_id %q, want %q", q.Get("client_id"), iss.ClientID)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL has no S256 PKCE challenge: %s", authURL)
+	}
+
+	nonce := q.Get("nonce")
+	if login.Nonce != "" {
+		nonce = login.Nonce
+	}
+	code = randomString(t)
+	iss.mu.Lock()
+	iss.grants[code] = grant{login: login, nonce: nonce, challenge: q.Get("code_challenge")}
+	iss.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (iss *Issuer) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                iss.URL,
+		"authorization_endpoint":                iss.URL + "/authorize",
+		"token_endpoint":                        iss.URL + "/token",
+		"jwks_uri":                              iss.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (iss *Issuer) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := iss.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// serveToken redeems a code once, checking the client and the PKCE verifier.
+func (iss *Issuer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	clientID, _, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != iss.ClientID {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	iss.mu.Lock()
+	g, found := iss.grants[code]
+	delete(iss.grants, code)
+	iss.mu.Unlock()
+	if !found || r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            iss.URL,
+		"sub":            g.login.Subject,
+		"aud":            iss.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          g.nonce,
+		"email_verified": g.login.EmailVerified,
+	}
+	if g.login.Email != "" {
+		claims["email"] = g.login.Email
+	}
+	if g.login.Name != "" {
+		claims["name"] = g.login.Name
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(iss.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access-" + code,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString(t testing.TB) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("random code: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
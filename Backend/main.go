@@ -2,8 +2,11 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -11,7 +14,12 @@ import (
 	"github.com/solaris-hms/mrf-backend/config"
 	"github.com/solaris-hms/mrf-backend/database"
 	"github.com/solaris-hms/mrf-backend/handlers"
+	"github.com/solaris-hms/mrf-backend/identity"
 	"github.com/solaris-hms/mrf-backend/middleware"
+	"github.com/solaris-hms/mrf-backend/migrations"
+	"github.com/solaris-hms/mrf-backend/models"
+	"github.com/solaris-hms/mrf-backend/notify"
+	"github.com/solaris-hms/mrf-backend/weighbridge"
 )
 
 func main() {
@@ -21,13 +29,93 @@ func main() {
 	db := database.New()
 	defer db.Close()
 
-	if err := db.SyncPermissions(config.AllPermissions); err != nil {
+	schema, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(db, schema, os.Args[2:]); err != nil {
+			log.Fatalf("Migration command failed: %v", err)
+		}
+		return
+	}
+
+	if err := db.MigrateUp(schema); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	if err := db.SyncPermissions(config.AllPermissions, config.PermissionDefaults); err != nil {
 		log.Fatalf("Failed to sync permissions: %v", err)
 	}
 
+	if err := db.SeedRoleTemplates(config.RoleTemplates); err != nil {
+		log.Fatalf("Failed to seed role templates: %v", err)
+	}
+
 	jwtSecret := os.Getenv("JWT_SECRET_KEY")
-	h := handlers.New(db, jwtSecret)
+	identityProvider, err := identity.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid identity provider configuration: %v", err)
+	}
+	scale, err := weighbridge.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid weighbridge configuration: %v", err)
+	}
+	if scale != nil {
+		go scale.Run(context.Background())
+	}
+	notifier, err := notify.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid notifier configuration: %v", err)
+	}
+	if notifier == nil {
+		log.Println("NOTIFIER is not set; password resets cannot be issued until a delivery sink is configured")
+	}
+	h := handlers.New(db, jwtSecret, notifier, identityProvider, scale)
+	r, registry := setupRouter(db, h)
+
+	if unguarded := registry.Unguarded(r, publicRoutes); len(unguarded) > 0 {
+		log.Fatalf("Routes registered without a permission requirement: %v", unguarded)
+	}
+
+	log.Println("Server starting on port 8080...")
+	if err := r.Run(":8080"); err != nil {
+		log.Fatalf("Failed to run server: %v", err)
+	}
+}
+
+// publicRoutes are reachable without a permission check. Every other route must
+// be registered through a middleware.GuardedGroup; main refuses to start otherwise.
+var publicRoutes = []string{
+	"POST /api/auth/register",
+	"POST /api/auth/login",
+	"POST /api/auth/refresh",
+	"POST /api/auth/reset-password",
+	"POST /api/auth/login/2fa",
+	"GET /api/auth/oidc",
+	"GET /api/auth/oidc/authorize",
+	"POST /api/auth/oidc/callback",
+	// Only require a valid session, not a specific permission.
+	"POST /api/auth/logout",
+	"POST /api/auth/change-password",
+	"GET /api/auth/2fa",
+	"POST /api/auth/2fa/enroll",
+	"POST /api/auth/2fa/confirm",
+	"POST /api/auth/2fa/disable",
+	"POST /api/auth/2fa/recovery-codes",
+	// Behind the QR code on printed weighment slips.
+	"GET /api/verify/weighment-slips/:token",
+	// Uploaded asset images and vendor documents served as static files.
+	"GET /uploads/*filepath",
+	"HEAD /uploads/*filepath",
+}
+
+// setupRouter registers every route and returns the engine together with the
+// registry of permission requirements for the guarded ones.
+func setupRouter(db *database.DB, h *handlers.Handlers) (*gin.Engine, *middleware.RouteRegistry) {
 	r := gin.Default()
+	registry := middleware.NewRouteRegistry()
 
 	r.MaxMultipartMemory = 100 << 20 // 100 MB
 
@@ -36,88 +124,239 @@ func main() {
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{"http://localhost:5173", "http://13.234.119.98", "http://mrf-management.duckdns.org", "https://mrf-management.duckdns.org"}
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-API-Key"}
 	r.Use(cors.New(corsConfig))
 
 	public := r.Group("/api/auth")
 	{
 		public.POST("/register", h.RegisterUser)
 		public.POST("/login", h.LoginUser)
+		public.POST("/login/2fa", h.LoginTwoFactor)
+		public.GET("/oidc", h.GetOIDCConfig)
+		public.GET("/oidc/authorize", h.StartOIDCLogin)
+		public.POST("/oidc/callback", h.OIDCCallback)
+		public.POST("/refresh", h.RefreshToken)
+		public.POST("/logout", middleware.AuthMiddleware(db), h.LogoutUser)
+		public.POST("/change-password", middleware.AuthMiddleware(db), h.ChangePassword)
+		public.POST("/reset-password", h.ResetPassword)
+		public.GET("/2fa", middleware.AuthMiddleware(db), h.GetTwoFactorStatus)
+		public.POST("/2fa/enroll", middleware.AuthMiddleware(db), h.EnrollTwoFactor)
+		public.POST("/2fa/confirm", middleware.AuthMiddleware(db), h.ConfirmTwoFactor)
+		public.POST("/2fa/disable", middleware.AuthMiddleware(db), h.DisableTwoFactor)
+		public.POST("/2fa/recovery-codes", middleware.AuthMiddleware(db), h.RegenerateRecoveryCodes)
 	}
 
-	admin := r.Group("/api/admin")
-	admin.Use(middleware.AuthMiddleware(db), middleware.PermissionMiddleware("manage:users"))
+	r.GET("/api/verify/weighment-slips/:token", h.VerifyWeighmentSlip)
+
+	adminGroup := r.Group("/api/admin")
+	adminGroup.Use(middleware.AuthMiddleware(db), middleware.PasswordRotationMiddleware(), middleware.TwoFactorEnrollmentMiddleware(), middleware.AuditMiddleware(db))
+	admin := registry.Guard(adminGroup, middleware.Perm("manage:users"))
+	{
+		admin.GET("/pending-users", nil, h.GetPendingUsers)
+		admin.POST("/approve/:userId", nil, h.ApproveUser)
+		admin.DELETE("/reject/:userId", nil, h.RejectUser)
+		admin.GET("/users", nil, h.GetAllUsers)
+		admin.PUT("/users/:userId", nil, h.UpdateUser)
+		admin.PUT("/users/:userId/status", nil, h.UpdateUserStatus)
+		admin.GET("/users/:userId/status-history", nil, h.GetUserStatusHistory)
+		admin.GET("/users/:userId/roles", nil, h.GetUserRoleAssignments)
+		admin.POST("/users/:userId/roles", nil, h.AddUserRoleAssignment)
+		admin.DELETE("/users/:userId/roles/:roleId", nil, h.RemoveUserRoleAssignment)
+		admin.GET("/users/:userId/permissions", nil, h.GetUserAccess)
+		admin.GET("/users/:userId/sessions", nil, h.GetUserSessions)
+		admin.POST("/users/:userId/revoke-sessions", nil, h.RevokeUserSessions)
+		admin.POST("/users/:userId/password-reset", nil, h.IssuePasswordReset)
+		admin.PUT("/users/:userId/force-password-change", nil, h.ForcePasswordChange)
+		admin.POST("/users/:userId/reset-two-factor", nil, h.ResetUserTwoFactor)
+		admin.GET("/users/:userId/identities", nil, h.GetUserIdentities)
+		admin.DELETE("/users/:userId/identities/:identityId", nil, h.UnlinkUserIdentity)
+		admin.GET("/locked-accounts", nil, h.GetLockedAccounts)
+		admin.POST("/locked-accounts/:userId/unlock", nil, h.UnlockAccount)
+		admin.GET("/login-attempts", nil, h.GetLoginAttempts)
+	}
+
+	// Role lists are needed both to assign roles to users and to edit the roles themselves.
+	roleReaders := registry.Guard(adminGroup, middleware.AnyOf(middleware.Perm("manage:users"), middleware.Perm("manage:roles")))
 	{
-		admin.GET("/pending-users", h.GetPendingUsers)
-		admin.POST("/approve/:userId", h.ApproveUser)
-		admin.DELETE("/reject/:userId", h.RejectUser)
-		admin.GET("/roles", h.GetAllRoles)
-		admin.GET("/users", h.GetAllUsers)
-		admin.PUT("/users/:userId", h.UpdateUser)
-		admin.GET("/permissions", h.GetAllPermissions)
-		admin.GET("/roles/:roleId/permissions", h.GetPermissionsForRole)
-		admin.PUT("/roles/:roleId/permissions", h.UpdatePermissionsForRole)
-	}
-
-	ops := r.Group("/api/operations")
-	ops.Use(middleware.AuthMiddleware(db))
+		roleReaders.GET("/roles", nil, h.GetAllRoles)
+		roleReaders.GET("/permissions", nil, h.GetAllPermissions)
+	}
+
+	roleAdmin := registry.Guard(adminGroup, middleware.Perm("manage:roles"))
 	{
-		ops.POST("/inward-entries", middleware.PermissionMiddleware("create:inward_entry"), h.CreateInwardEntry)
-		ops.GET("/inward-entries/pending", middleware.PermissionMiddleware("view:inward_entries"), h.GetPendingInwardEntries)
-		ops.GET("/inward-entries/completed", middleware.PermissionMiddleware("view:inward_entries"), h.GetCompletedInwardEntries)
-		ops.PUT("/inward-entries/:entryId/complete", middleware.PermissionMiddleware("complete:inward_entry"), h.CompleteInwardEntry)
-		ops.DELETE("/inward-entries/:entryId", middleware.PermissionMiddleware("delete:inward_entry"), h.DeleteInwardEntry)
+		roleAdmin.GET("/role-templates", nil, h.GetRoleTemplates)
+		roleAdmin.POST("/roles", nil, h.CreateRole)
+		roleAdmin.PUT("/roles/:roleId", nil, h.UpdateRole)
+		roleAdmin.DELETE("/roles/:roleId", nil, h.DeleteRole)
+		roleAdmin.POST("/roles/:roleId/clone", nil, h.CloneRole)
+		roleAdmin.PUT("/roles/:roleId/two-factor", nil, h.SetRoleTwoFactorPolicy)
+		roleAdmin.GET("/roles/:roleId/permissions", nil, h.GetPermissionsForRole)
+		roleAdmin.PUT("/roles/:roleId/permissions", nil, h.UpdatePermissionsForRole)
+	}
 
-		ops.GET("/partners", h.GetAllPartners)
-		ops.POST("/partners", h.CreatePartner)
+	serviceAccounts := registry.Guard(adminGroup, middleware.Perm("manage:service_accounts"))
+	{
+		serviceAccounts.GET("/service-accounts", nil, h.GetServiceAccounts)
+		serviceAccounts.POST("/service-accounts", nil, h.CreateServiceAccount)
+		serviceAccounts.GET("/service-accounts/:accountId/keys", nil, h.GetAPIKeys)
+		serviceAccounts.POST("/service-accounts/:accountId/keys", nil, h.CreateAPIKey)
+		serviceAccounts.POST("/service-accounts/:accountId/keys/:keyId/rotate", nil, h.RotateAPIKey)
+		serviceAccounts.DELETE("/service-accounts/:accountId/keys/:keyId", nil, h.RevokeAPIKey)
+	}
 
-		ops.POST("/sorting-log", middleware.PermissionMiddleware("create:sorting_log"), h.CreateSortingLog)
-		ops.GET("/sorting-logs", middleware.PermissionMiddleware("create:sorting_log"), h.GetSortingLogs)
+	auditReaders := registry.Guard(adminGroup, middleware.Perm("view:audit_log"))
+	{
+		auditReaders.GET("/audit-log", nil, h.GetAuditLog)
+		auditReaders.GET("/audit-log/export", nil, h.ExportAuditLog)
+	}
 
-		ops.GET("/inventory", middleware.PermissionMiddleware("view:inventory"), h.GetInventory)
-		ops.POST("/inventory/adjust", middleware.PermissionMiddleware("manage:inventory_audit"), h.AdjustInventory)
-		ops.GET("/inventory/audits", middleware.PermissionMiddleware("manage:inventory_audit"), h.GetInventoryAudits)
+	opsGroup := r.Group("/api/operations")
+	opsGroup.Use(middleware.AuthMiddleware(db), middleware.PasswordRotationMiddleware(), middleware.TwoFactorEnrollmentMiddleware())
+	ops := registry.Guard(opsGroup, nil)
+	{
+		ops.POST("/inward-entries", middleware.Perm("create:inward_entry"), h.CreateInwardEntry)
+		ops.GET("/inward-entries/pending", middleware.Perm("view:inward_entries"), h.GetPendingInwardEntries)
+		ops.GET("/inward-entries/completed", middleware.Perm("view:inward_entries"), h.GetCompletedInwardEntries)
+		ops.PUT("/inward-entries/:entryId/complete", middleware.Perm("complete:inward_entry"), h.CompleteInwardEntry)
+		weighing := middleware.AnyOf(middleware.Perm("create:inward_entry"), middleware.Perm("complete:inward_entry"))
+		ops.GET("/weighbridge/reading", weighing, h.GetWeighbridgeReading)
+		ops.GET("/weighbridge/stream", weighing, h.StreamWeighbridgeReadings)
+		ops.PUT("/inward-entries/:entryId/status", middleware.Perm("complete:inward_entry"), h.TransitionInwardEntry)
+		ops.GET("/inward-entries/:entryId/history", middleware.Perm("view:inward_entries"), h.GetInwardEntryHistory)
+		ops.GET("/inward-entries/:entryId/slip", middleware.Perm("view:inward_entries"), h.DownloadWeighmentSlip)
+		ops.DELETE("/inward-entries/:entryId", middleware.Perm("delete:inward_entry"), h.DeleteInwardEntry)
 
-		ops.GET("/cashbook", middleware.PermissionMiddleware("view:cashbook"), h.GetCashbookData)
-		ops.POST("/cashbook", middleware.PermissionMiddleware("view:cashbook"), h.CreateCashbookTransaction)
+		viewVehicles := middleware.AnyOf(middleware.Perm("view:vehicles"), middleware.Perm("manage:vehicles"))
+		ops.GET("/vehicles", viewVehicles, h.GetVehicles)
+		ops.GET("/vehicles/by-registration/:registration", viewVehicles, h.GetVehicleByRegistration)
+		ops.POST("/vehicles", middleware.Perm("manage:vehicles"), h.CreateVehicle)
+		ops.PUT("/vehicles/:vehicleId", middleware.Perm("manage:vehicles"), h.UpdateVehicle)
+		ops.PUT("/vehicles/:vehicleId/tare", middleware.Perm("manage:vehicles"), h.SetVehicleTare)
+		ops.PUT("/vehicles/:vehicleId/blacklist", middleware.Perm("blacklist:vehicles"), h.SetVehicleBlacklist)
 
-		ops.GET("/sales", h.GetMaterialSales)
-		ops.POST("/sales", h.CreateMaterialSale)
+		viewPartners := middleware.AnyOf(middleware.Perm("manage:partners"), middleware.Perm("view:inward_entries"), middleware.Perm("view:sales"))
+		ops.GET("/partners", viewPartners, h.GetAllPartners)
+		ops.POST("/partners", middleware.Perm("manage:partners"), h.CreatePartner)
+		ops.PUT("/partners/:partnerId/tax-details", middleware.Perm("manage:partners"), h.UpdatePartnerTaxDetails)
 
-		ops.POST("/employees", middleware.PermissionMiddleware("manage:employees"), h.CreateEmployee)
-		ops.GET("/employees", middleware.PermissionMiddleware("manage:employees"), h.GetEmployees)
-		ops.PUT("/employees/:id", middleware.PermissionMiddleware("manage:employees"), h.UpdateEmployee)
-		ops.DELETE("/employees/:id", middleware.PermissionMiddleware("manage:employees"), h.DeleteEmployee)
+		ops.POST("/sorting-log", middleware.Perm("create:sorting_log"), h.CreateSortingLog)
+		ops.GET("/sorting-logs", middleware.Perm("create:sorting_log"), h.GetSortingLogs)
 
-		ops.GET("/attendance", middleware.PermissionMiddleware("manage:attendance"), h.GetAttendance)
-		ops.POST("/attendance", middleware.PermissionMiddleware("manage:attendance"), h.SaveAttendance)
+		ops.GET("/inventory", middleware.Perm("view:inventory"), h.GetInventory)
+		ops.POST("/inventory/adjust", middleware.Perm("manage:inventory_audit"), h.AdjustInventory)
+		ops.GET("/inventory/audits", middleware.Perm("manage:inventory_audit"), h.GetInventoryAudits)
+		ops.GET("/inventory/movements", middleware.Perm("view:inventory"), h.GetInventoryMovements)
+		ops.GET("/inventory/posting-rules", middleware.Perm("view:inventory"), h.GetInventoryPostingRules)
+		ops.PUT("/inventory/posting-rules/:entryType", middleware.Perm("manage:inventory_rules"), h.SetInventoryPostingRule)
 
-		ops.POST("/assets", middleware.PermissionMiddleware("create:assets"), h.CreateAsset)
-		ops.GET("/assets", middleware.PermissionMiddleware("view:assets"), h.GetAssets)
-		ops.PUT("/assets/:id", middleware.PermissionMiddleware("edit:assets"), h.UpdateAsset)
-		ops.DELETE("/assets/:id", middleware.PermissionMiddleware("delete:assets"), h.DeleteAsset)
-		ops.POST("/assets/:id/image", middleware.PermissionMiddleware("edit:assets"), h.UploadAssetImage)
+		ops.GET("/cashbook", middleware.Perm("view:cashbook"), h.GetCashbookData)
+		ops.POST("/cashbook", middleware.Perm("create:cashbook_entry"), h.CreateCashbookTransaction)
+		ops.PUT("/cashbook/:id/approve", middleware.Perm("approve:cashbook_entry"), h.ApproveCashbookTransaction)
 
-		ops.POST("/vendors", middleware.PermissionMiddleware("create:vendors"), h.CreateVendor)
-		ops.GET("/vendors", middleware.PermissionMiddleware("view:vendors"), h.GetVendors)
-		ops.GET("/vendors/:id", middleware.PermissionMiddleware("view:vendors"), h.GetVendor)
-		ops.PUT("/vendors/:id", middleware.PermissionMiddleware("edit:vendors"), h.UpdateVendor)
-		ops.DELETE("/vendors/:id", middleware.PermissionMiddleware("delete:vendors"), h.DeleteVendor)
-		ops.POST("/vendors/:id/documents", middleware.PermissionMiddleware("manage:vendor_documents"), h.UploadVendorDocument)
-		ops.GET("/vendor-documents/:docId/download", middleware.PermissionMiddleware("view:vendors"), h.DownloadVendorDocument)
-		ops.DELETE("/vendor-documents/:docId", middleware.PermissionMiddleware("manage:vendor_documents"), h.DeleteVendorDocument)
+		ops.GET("/sales", middleware.Perm("view:sales"), h.GetMaterialSales)
+		ops.POST("/sales", middleware.Perm("create:sales"), h.CreateMaterialSale)
+		ops.GET("/sales/tolerances", middleware.Perm("view:sales"), h.GetMaterialTolerances)
+		ops.PUT("/sales/tolerances/:material", middleware.Perm("manage:sale_tolerances"), h.SetMaterialTolerance)
+		ops.GET("/sales/rounding-rules", middleware.Perm("view:sales"), h.GetRoundingRules)
+		ops.PUT("/sales/rounding-rules/:scope", middleware.Perm("manage:sale_rounding"), h.SetRoundingRule)
+		ops.GET("/sales/hsn-codes", middleware.Perm("view:sales"), h.GetMaterialHSNCodes)
+		ops.PUT("/sales/hsn-codes/:material", middleware.Perm("manage:invoicing"), h.SetMaterialHSNCode)
+		ops.POST("/sales/:saleId/invoice", middleware.Perm("issue:invoices"), h.IssueTaxInvoice)
+		ops.GET("/tax-invoices", middleware.Perm("view:sales"), h.GetTaxInvoices)
+		ops.GET("/tax-invoices/e-invoice", middleware.Perm("view:sales"), h.ExportEInvoices)
+		ops.GET("/tax-invoices/:invoiceId", middleware.Perm("view:sales"), h.GetTaxInvoice)
+		ops.GET("/tax-invoices/:invoiceId/pdf", middleware.Perm("view:sales"), h.DownloadTaxInvoice)
+		ops.GET("/tax-invoices/:invoiceId/e-invoice", middleware.Perm("view:sales"), h.ExportEInvoice)
+		ops.GET("/receivables", middleware.Perm("view:receivables"), h.GetReceivables)
+		ops.GET("/receivables/parties/:partyId/statement", middleware.Perm("view:receivables"), h.GetPartyStatement)
+		ops.GET("/receivables/payments", middleware.Perm("view:receivables"), h.GetSalePayments)
+		ops.POST("/receivables/payments", middleware.Perm("record:payments"), h.CreateSalePayment)
+		ops.GET("/receivables/payments/:paymentId", middleware.Perm("view:receivables"), h.GetSalePayment)
+		ops.POST("/receivables/payments/:paymentId/allocations", middleware.Perm("record:payments"), h.AllocatePayment)
+		ops.POST("/sales/:saleId/credit-note", middleware.Perm("cancel:sales"), h.CancelMaterialSale)
+		ops.GET("/sale-credit-notes", middleware.Perm("view:sales"), h.GetSaleCreditNotes)
+		ops.GET("/sale-credit-notes/:creditNoteId", middleware.Perm("view:sales"), h.GetSaleCreditNote)
+		ops.POST("/sales/:saleId/disputes", middleware.Perm("create:sales"), h.OpenSaleDispute)
+		ops.GET("/sale-disputes", middleware.Perm("view:sales"), h.GetSaleDisputes)
+		ops.GET("/sale-disputes/:disputeId", middleware.Perm("view:sales"), h.GetSaleDispute)
+		ops.PUT("/sale-disputes/:disputeId", middleware.Perm("resolve:sale_disputes"), h.UpdateSaleDispute)
+		ops.POST("/sale-disputes/:disputeId/attachments", middleware.Perm("create:sales"), h.UploadSaleDisputeAttachments)
+		ops.GET("/sale-disputes/:disputeId/attachments/:attachmentId", middleware.Perm("view:sales"), h.DownloadSaleDisputeAttachment)
 
-		ops.POST("/reports/plant-head", middleware.PermissionMiddleware("create:plant_head_report"), h.CreatePlantHeadReport)
-		ops.POST("/reports/asst-plant-head", middleware.PermissionMiddleware("create:asst_plant_head_report"), h.CreateAsstPlantHeadReport)
-		ops.POST("/reports/workforce-material", middleware.PermissionMiddleware("create:workforce_material_report"), h.CreateWorkforceMaterialReport)
+		// Department-scoped grants reach these routes; the handlers limit them to
+		// the caller's departments.
+		manageEmployees := middleware.AnyScope("manage:employees", models.ScopeDepartment)
+		manageAttendance := middleware.AnyScope("manage:attendance", models.ScopeDepartment)
+		ops.POST("/employees", manageEmployees, h.CreateEmployee)
+		ops.GET("/employees", manageEmployees, h.GetEmployees)
+		ops.PUT("/employees/:id", manageEmployees, h.UpdateEmployee)
+		ops.DELETE("/employees/:id", manageEmployees, h.DeleteEmployee)
 
-		ops.GET("/reports/plant-head", middleware.PermissionMiddleware("view:reports"), h.GetPlantHeadReports)
-		ops.GET("/reports/asst-plant-head", middleware.PermissionMiddleware("view:reports"), h.GetAsstPlantHeadReports)
-		ops.GET("/reports/workforce-material", middleware.PermissionMiddleware("view:reports"), h.GetWorkforceMaterialReports)
+		ops.GET("/attendance", manageAttendance, h.GetAttendance)
+		ops.POST("/attendance", manageAttendance, h.SaveAttendance)
+
+		ops.POST("/assets", middleware.Perm("create:assets"), h.CreateAsset)
+		ops.GET("/assets", middleware.Perm("view:assets"), h.GetAssets)
+		ops.PUT("/assets/:id", middleware.Perm("edit:assets"), h.UpdateAsset)
+		ops.DELETE("/assets/:id", middleware.Perm("delete:assets"), h.DeleteAsset)
+		ops.POST("/assets/:id/image", middleware.Perm("edit:assets"), h.UploadAssetImage)
+
+		ops.POST("/vendors", middleware.Perm("create:vendors"), h.CreateVendor)
+		ops.GET("/vendors", middleware.Perm("view:vendors"), h.GetVendors)
+		ops.GET("/vendors/:id", middleware.Perm("view:vendors"), h.GetVendor)
+		ops.GET("/vendors/:id/receipts", middleware.AllOf(middleware.Perm("view:vendors"), middleware.Perm("view:inward_entries")), h.GetVendorReceipts)
+		ops.PUT("/vendors/:id", middleware.Perm("edit:vendors"), h.UpdateVendor)
+		ops.DELETE("/vendors/:id", middleware.Perm("delete:vendors"), h.DeleteVendor)
+		ops.POST("/vendors/:id/documents", middleware.Perm("manage:vendor_documents"), h.UploadVendorDocument)
+		ops.GET("/vendor-documents/:docId/download", middleware.Perm("view:vendors"), h.DownloadVendorDocument)
+		ops.DELETE("/vendor-documents/:docId", middleware.Perm("manage:vendor_documents"), h.DeleteVendorDocument)
+
+		ops.POST("/reports/plant-head", middleware.Perm("create:plant_head_report"), h.CreatePlantHeadReport)
+		ops.POST("/reports/asst-plant-head", middleware.Perm("create:asst_plant_head_report"), h.CreateAsstPlantHeadReport)
+		ops.POST("/reports/workforce-material", middleware.Perm("create:workforce_material_report"), h.CreateWorkforceMaterialReport)
+
+		viewReports := middleware.AnyOf(middleware.Perm("view:reports"), middleware.Perm("generate:reports"))
+		ops.GET("/reports/plant-head", viewReports, h.GetPlantHeadReports)
+		ops.GET("/reports/asst-plant-head", viewReports, h.GetAsstPlantHeadReports)
+		ops.GET("/reports/workforce-material", viewReports, h.GetWorkforceMaterialReports)
 	}
 
-	log.Println("Server starting on port 8080...")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("Failed to run server: %v", err)
+	return r, registry
+}
+
+// runMigrateCommand implements `migrate up`, `migrate down [steps]` and `migrate status`.
+func runMigrateCommand(db *database.DB, schema []database.Migration, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		return db.MigrateUp(schema)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		return db.MigrateDown(schema, steps)
+	case "status":
+		statuses, err := db.GetMigrationStatus(schema)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%06d  %-45s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
 	}
 }
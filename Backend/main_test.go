@@ -0,0 +1,33 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solaris-hms/mrf-backend/handlers"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, func() []string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r, registry := setupRouter(nil, &handlers.Handlers{})
+	return r, func() []string { return registry.Unguarded(r, publicRoutes) }
+}
+
+func TestEveryRouteIsGuardedOrPublic(t *testing.T) {
+	_, unguarded := newTestRouter(t)
+	if missing := unguarded(); len(missing) > 0 {
+		t.Fatalf("routes registered without a permission requirement: %v", missing)
+	}
+}
+
+func TestUnguardedRouteIsReported(t *testing.T) {
+	r, unguarded := newTestRouter(t)
+	r.GET("/api/operations/unguarded-probe", func(c *gin.Context) {})
+
+	missing := unguarded()
+	if !slices.Contains(missing, "GET /api/operations/unguarded-probe") {
+		t.Fatalf("unguarded probe route not reported, got %v", missing)
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solaris-hms/mrf-backend/database"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+const auditChangeKey = "auditChange"
+
+type auditChange struct {
+	action     string
+	targetType string
+	targetID   string
+	before     interface{}
+	after      interface{}
+}
+
+// AuditChange describes the change a handler made so AuditMiddleware can record
+// it. Handlers call it on success; before and after are stored as JSON.
+func AuditChange(c *gin.Context, action, targetType, targetID string, before, after interface{}) {
+	c.Set(auditChangeKey, &auditChange{action: action, targetType: targetType, targetID: targetID, before: before, after: after})
+}
+
+// auditTargetParams maps route parameters to the target type recorded when a
+// handler did not describe the change itself.
+var auditTargetParams = []struct{ param, targetType string }{
+	{"userId", "user"},
+	{"roleId", "role"},
+	{"accountId", "user"},
+}
+
+// AuditMiddleware appends every state-changing request on the group to the
+// admin audit log, including requests that were denied or failed.
+// It must run after AuthMiddleware so the actor is known.
+func AuditMiddleware(db *database.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		entry := models.AuditLogEntry{
+			Action:     c.Request.Method + " " + c.FullPath(),
+			Method:     c.Request.Method,
+			Route:      c.FullPath(),
+			Path:       c.Request.URL.Path,
+			StatusCode: c.Writer.Status(),
+		}
+		if userID, ok := c.Get("userID"); ok {
+			id := userID.(int)
+			entry.ActorUserID = &id
+		}
+		if q := c.Request.URL.RawQuery; q != "" {
+			entry.Query = &q
+		}
+		if ip := c.ClientIP(); ip != "" {
+			entry.IPAddress = &ip
+		}
+		if ua := c.Request.UserAgent(); ua != "" {
+			entry.UserAgent = &ua
+		}
+
+		for _, p := range auditTargetParams {
+			if v := c.Param(p.param); v != "" {
+				targetType, targetID := p.targetType, v
+				entry.TargetType, entry.TargetID = &targetType, &targetID
+				break
+			}
+		}
+
+		if v, ok := c.Get(auditChangeKey); ok {
+			change := v.(*auditChange)
+			if change.action != "" {
+				entry.Action = change.action
+			}
+			if change.targetType != "" {
+				entry.TargetType = &change.targetType
+			}
+			if change.targetID != "" {
+				entry.TargetID = &change.targetID
+			}
+			entry.Before = marshalAuditValue(change.before)
+			entry.After = marshalAuditValue(change.after)
+		}
+
+		if err := db.InsertAuditLogEntry(&entry); err != nil {
+			log.Printf("Failed to write audit log entry for %s %s: %v\n", entry.Method, entry.Path, err)
+		}
+	}
+}
+
+func marshalAuditValue(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode audit value: %v\n", err)
+		return nil
+	}
+	return raw
+}
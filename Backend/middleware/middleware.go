@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -11,9 +13,16 @@ import (
 	"github.com/solaris-hms/mrf-backend/database"
 )
 
-// AuthMiddleware creates a middleware that verifies a JWT and fetches user permissions.
+// AuthMiddleware creates a middleware that verifies a JWT or a service account
+// API key and fetches the caller's permissions. API keys are accepted in the
+// X-API-Key header or as a bearer token.
 func AuthMiddleware(db *database.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, db, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
@@ -25,6 +34,10 @@ func AuthMiddleware(db *database.DB) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Bearer token not provided"})
 			return
 		}
+		if strings.HasPrefix(tokenString, database.APIKeyPrefix) {
+			authenticateAPIKey(c, db, tokenString)
+			return
+		}
 
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -51,40 +64,99 @@ func AuthMiddleware(db *database.DB) gin.HandlerFunc {
 		}
 		userID := int(userIDFloat)
 
-		permissions, err := db.GetUserPermissions(userID)
+		sessionIDFloat, ok := claims["sid"].(float64)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid session in token"})
+			return
+		}
+		sessionID := int(sessionIDFloat)
+
+		active, err := db.IsSessionActive(sessionID, userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not verify session"})
+			return
+		}
+		if !active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			return
+		}
+
+		grants, err := db.GetUserPermissions(userID)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve user permissions"})
 			return
 		}
 
 		c.Set("userID", userID)
-		c.Set("permissions", permissions)
+		c.Set("sessionID", sessionID)
+		mustChangePassword, _ := claims["pwd_change"].(bool)
+		c.Set("mustChangePassword", mustChangePassword)
+		mustEnrollTwoFactor, _ := claims["tfa_enroll"].(bool)
+		c.Set("mustEnrollTwoFactor", mustEnrollTwoFactor)
+		c.Set("grants", grants)
+		c.Set("permissions", grants.Actions())
 		c.Next()
 	}
 }
 
-// PermissionMiddleware creates a middleware that checks if a user has a specific permission.
-func PermissionMiddleware(requiredPermission string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		permissions, exists := c.Get("permissions")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permissions not found"})
+// authenticateAPIKey resolves an API key to its service account and limits the
+// account's permissions to the key's scopes.
+func authenticateAPIKey(c *gin.Context, db *database.DB, apiKey string) {
+	identity, err := db.AuthenticateAPIKey(apiKey)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidAPIKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
 			return
 		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not verify API key"})
+		return
+	}
 
-		userPermissions, ok := permissions.([]string)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid permissions format"})
+	grants, err := db.GetUserPermissions(identity.UserID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve user permissions"})
+		return
+	}
+	grants = grants.Restrict(identity.Scopes)
+
+	if err := db.TouchAPIKey(identity.KeyID, c.ClientIP()); err != nil {
+		log.Printf("Failed to record use of API key %d: %v\n", identity.KeyID, err)
+	}
+
+	c.Set("userID", identity.UserID)
+	c.Set("apiKeyID", identity.KeyID)
+	c.Set("mustChangePassword", false)
+	c.Set("grants", grants)
+	c.Set("permissions", grants.Actions())
+	c.Next()
+}
+
+// PasswordRotationMiddleware blocks users who must change their password from
+// everything except the auth routes, which are registered without it.
+func PasswordRotationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("mustChangePassword") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You must change your password before continuing", "code": "password_change_required"})
 			return
 		}
+		c.Next()
+	}
+}
 
-		for _, p := range userPermissions {
-			if p == requiredPermission {
-				c.Next()
-				return
-			}
+// TwoFactorEnrollmentMiddleware blocks users whose role requires two-factor
+// authentication until they enrol. The enrolment routes are registered without it.
+func TwoFactorEnrollmentMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("mustEnrollTwoFactor") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Your role requires two-factor authentication. Please set it up before continuing", "code": "two_factor_enrollment_required"})
+			return
 		}
-
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+		c.Next()
 	}
 }
+
+// PermissionMiddleware creates a middleware that checks if a user has a specific permission.
+// It is shorthand for Require(Perm(requiredPermission)).
+func PermissionMiddleware(requiredPermission string) gin.HandlerFunc {
+	return Require(Perm(requiredPermission))
+}
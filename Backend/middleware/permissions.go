@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// Requirement is a permission expression checked against the caller's grants.
+// Build them with Perm, ScopedPerm, AnyScope, AnyOf, AllOf and Not, and attach
+// them to a route with Require.
+type Requirement interface {
+	Satisfied(c *gin.Context, grants models.Grants) bool
+	String() string
+}
+
+type permRequirement struct {
+	action string
+}
+
+// Perm requires the action to be granted globally. A grant limited to a site or
+// department never satisfies it; use ScopedPerm or AnyScope for scoped routes.
+func Perm(action string) Requirement {
+	return permRequirement{action: action}
+}
+
+func (r permRequirement) Satisfied(_ *gin.Context, grants models.Grants) bool {
+	return grants.Has(r.action)
+}
+
+func (r permRequirement) String() string {
+	return r.action
+}
+
+type scopedRequirement struct {
+	action    string
+	scopeType string
+	param     string
+}
+
+// ScopedPerm requires the action to be granted either globally or for the
+// scope named by the request's route parameter or query parameter param.
+// When the request names no scope, only a global grant satisfies it.
+func ScopedPerm(action, scopeType, param string) Requirement {
+	return scopedRequirement{action: action, scopeType: scopeType, param: param}
+}
+
+func (r scopedRequirement) Satisfied(c *gin.Context, grants models.Grants) bool {
+	value := c.Param(r.param)
+	if value == "" {
+		value = c.Query(r.param)
+	}
+	if value == "" {
+		_, global := grants.Scopes(r.action, r.scopeType)
+		return global
+	}
+	return grants.HasIn(r.action, r.scopeType, value)
+}
+
+func (r scopedRequirement) String() string {
+	return r.action + "@" + r.scopeType + ":" + r.param
+}
+
+type anyScopeRequirement struct {
+	action    string
+	scopeType string
+}
+
+// AnyScope requires the action to be granted globally or for at least one scope
+// of scopeType. The handler must narrow its work to the caller's scopes, which
+// it reads with GrantsFromContext and Grants.Scopes.
+func AnyScope(action, scopeType string) Requirement {
+	return anyScopeRequirement{action: action, scopeType: scopeType}
+}
+
+func (r anyScopeRequirement) Satisfied(_ *gin.Context, grants models.Grants) bool {
+	values, global := grants.Scopes(r.action, r.scopeType)
+	return global || len(values) > 0
+}
+
+func (r anyScopeRequirement) String() string {
+	return r.action + "@" + r.scopeType + ":*"
+}
+
+type anyOfRequirement []Requirement
+
+// AnyOf is satisfied when at least one of the requirements is.
+func AnyOf(reqs ...Requirement) Requirement {
+	return anyOfRequirement(reqs)
+}
+
+func (r anyOfRequirement) Satisfied(c *gin.Context, grants models.Grants) bool {
+	for _, req := range r {
+		if req.Satisfied(c, grants) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r anyOfRequirement) String() string {
+	return "anyOf(" + joinRequirements(r) + ")"
+}
+
+type allOfRequirement []Requirement
+
+// AllOf is satisfied only when every requirement is.
+func AllOf(reqs ...Requirement) Requirement {
+	return allOfRequirement(reqs)
+}
+
+func (r allOfRequirement) Satisfied(c *gin.Context, grants models.Grants) bool {
+	for _, req := range r {
+		if !req.Satisfied(c, grants) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r allOfRequirement) String() string {
+	return "allOf(" + joinRequirements(r) + ")"
+}
+
+type notRequirement struct {
+	req Requirement
+}
+
+// Not is satisfied when the wrapped requirement is not.
+func Not(req Requirement) Requirement {
+	return notRequirement{req: req}
+}
+
+func (r notRequirement) Satisfied(c *gin.Context, grants models.Grants) bool {
+	return !r.req.Satisfied(c, grants)
+}
+
+func (r notRequirement) String() string {
+	return "not(" + r.req.String() + ")"
+}
+
+func joinRequirements(reqs []Requirement) string {
+	parts := make([]string, len(reqs))
+	for i, req := range reqs {
+		parts[i] = req.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Require creates a middleware that checks the caller's grants against a permission expression.
+func Require(req Requirement) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		grants, ok := GrantsFromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permissions not found"})
+			return
+		}
+
+		if !req.Satisfied(c, grants) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GrantsFromContext returns the grants loaded by AuthMiddleware, so handlers can
+// narrow results to the sites or departments the caller is scoped to.
+func GrantsFromContext(c *gin.Context) (models.Grants, bool) {
+	value, exists := c.Get("grants")
+	if !exists {
+		return nil, false
+	}
+	grants, ok := value.(models.Grants)
+	return grants, ok
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solaris-hms/mrf-backend/models"
+)
+
+// requestContext builds a context for a request to target with the given route parameters.
+func requestContext(target string, params gin.Params) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	c.Params = params
+	return c
+}
+
+func TestScopedGrantDoesNotSatisfyGlobalPerm(t *testing.T) {
+	scoped := models.Grants{{Action: "manage:employees", ScopeType: models.ScopeDepartment, ScopeValue: "sorting"}}
+	global := models.Grants{{Action: "manage:employees"}}
+	c := requestContext("/employees", nil)
+
+	if Perm("manage:employees").Satisfied(c, scoped) {
+		t.Fatal("department-scoped grant satisfied a global permission check")
+	}
+	if !Perm("manage:employees").Satisfied(c, global) {
+		t.Fatal("global grant did not satisfy a global permission check")
+	}
+}
+
+func TestScopedPerm(t *testing.T) {
+	grants := models.Grants{{Action: "manage:employees", ScopeType: models.ScopeDepartment, ScopeValue: "sorting"}}
+	req := ScopedPerm("manage:employees", models.ScopeDepartment, "department")
+
+	tests := []struct {
+		name   string
+		c      *gin.Context
+		wanted bool
+	}{
+		{"matching route parameter", requestContext("/", gin.Params{{Key: "department", Value: "sorting"}}), true},
+		{"matching query parameter", requestContext("/?department=sorting", nil), true},
+		{"other department", requestContext("/?department=baling", nil), false},
+		{"no department named", requestContext("/", nil), false},
+	}
+	for _, tt := range tests {
+		if got := req.Satisfied(tt.c, grants); got != tt.wanted {
+			t.Errorf("%s: Satisfied = %v, want %v", tt.name, got, tt.wanted)
+		}
+	}
+
+	// A global grant covers every department, named or not.
+	global := models.Grants{{Action: "manage:employees"}}
+	if !req.Satisfied(requestContext("/", nil), global) || !req.Satisfied(requestContext("/?department=baling", nil), global) {
+		t.Error("global grant rejected by a scoped check")
+	}
+}
+
+func TestAnyScope(t *testing.T) {
+	req := AnyScope("manage:attendance", models.ScopeDepartment)
+	c := requestContext("/attendance", nil)
+
+	tests := []struct {
+		name   string
+		grants models.Grants
+		wanted bool
+	}{
+		{"global", models.Grants{{Action: "manage:attendance"}}, true},
+		{"department", models.Grants{{Action: "manage:attendance", ScopeType: models.ScopeDepartment, ScopeValue: "sorting"}}, true},
+		{"other scope type", models.Grants{{Action: "manage:attendance", ScopeType: models.ScopeSite, ScopeValue: "north"}}, false},
+		{"other action", models.Grants{{Action: "manage:employees"}}, false},
+	}
+	for _, tt := range tests {
+		if got := req.Satisfied(c, tt.grants); got != tt.wanted {
+			t.Errorf("%s: Satisfied = %v, want %v", tt.name, got, tt.wanted)
+		}
+	}
+}
+
+func TestRequireRejectsScopedGrantOnGlobalRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/cashbook", func(c *gin.Context) {
+		c.Set("grants", models.Grants{{Action: "view:cashbook", ScopeType: models.ScopeSite, ScopeValue: "north"}})
+	}, Require(Perm("view:cashbook")), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cashbook", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
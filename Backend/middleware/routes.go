@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteRegistry records the permission expression attached to every guarded
+// route so that routes registered without one can be detected at startup.
+type RouteRegistry struct {
+	requirements map[string]string
+}
+
+// NewRouteRegistry creates an empty registry.
+func NewRouteRegistry() *RouteRegistry {
+	return &RouteRegistry{requirements: make(map[string]string)}
+}
+
+// GuardedGroup registers routes on a router group, each with a mandatory
+// permission expression that is combined with the group's base expression.
+type GuardedGroup struct {
+	group    *gin.RouterGroup
+	base     Requirement
+	registry *RouteRegistry
+}
+
+// Guard wraps a router group. base may be nil when each route carries its own requirement.
+func (r *RouteRegistry) Guard(group *gin.RouterGroup, base Requirement) *GuardedGroup {
+	return &GuardedGroup{group: group, base: base, registry: r}
+}
+
+func (g *GuardedGroup) handle(method, path string, req Requirement, handlers []gin.HandlerFunc) {
+	if req == nil {
+		req = g.base
+	} else if g.base != nil {
+		req = AllOf(g.base, req)
+	}
+	if req == nil {
+		panic("route " + method + " " + g.group.BasePath() + path + " registered without a permission requirement")
+	}
+
+	g.registry.requirements[method+" "+joinRoutePath(g.group.BasePath(), path)] = req.String()
+	g.group.Handle(method, path, append([]gin.HandlerFunc{Require(req)}, handlers...)...)
+}
+
+// GET registers a guarded GET route. A nil req uses only the group's base requirement.
+func (g *GuardedGroup) GET(path string, req Requirement, handlers ...gin.HandlerFunc) {
+	g.handle("GET", path, req, handlers)
+}
+
+// POST registers a guarded POST route.
+func (g *GuardedGroup) POST(path string, req Requirement, handlers ...gin.HandlerFunc) {
+	g.handle("POST", path, req, handlers)
+}
+
+// PUT registers a guarded PUT route.
+func (g *GuardedGroup) PUT(path string, req Requirement, handlers ...gin.HandlerFunc) {
+	g.handle("PUT", path, req, handlers)
+}
+
+// DELETE registers a guarded DELETE route.
+func (g *GuardedGroup) DELETE(path string, req Requirement, handlers ...gin.HandlerFunc) {
+	g.handle("DELETE", path, req, handlers)
+}
+
+// Requirement returns the permission expression recorded for a route.
+func (r *RouteRegistry) Requirement(method, path string) (string, bool) {
+	req, ok := r.requirements[method+" "+path]
+	return req, ok
+}
+
+// Unguarded lists every route on the engine that was not registered through a
+// GuardedGroup and is not in the public allow-list ("METHOD /path").
+func (r *RouteRegistry) Unguarded(engine *gin.Engine, public []string) []string {
+	allowed := make(map[string]bool)
+	for _, route := range public {
+		allowed[route] = true
+	}
+
+	var unguarded []string
+	for _, route := range engine.Routes() {
+		key := route.Method + " " + route.Path
+		if _, ok := r.requirements[key]; ok || allowed[key] {
+			continue
+		}
+		unguarded = append(unguarded, key)
+	}
+	sort.Strings(unguarded)
+	return unguarded
+}
+
+func joinRoutePath(base, path string) string {
+	if path == "" {
+		return base
+	}
+	if base == "/" {
+		return path
+	}
+	if base[len(base)-1] == '/' && path[0] == '/' {
+		return base + path[1:]
+	}
+	return base + path
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS workforce_material_reports;
+DROP TABLE IF EXISTS asst_plant_head_reports;
+DROP TABLE IF EXISTS plant_head_reports;
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_session_refresh_tokens_session_id;
+DROP INDEX IF EXISTS idx_user_sessions_user_id;
+
+DROP TABLE IF EXISTS session_refresh_tokens;
+DROP TABLE IF EXISTS user_sessions;
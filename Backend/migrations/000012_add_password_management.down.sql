@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_password_reset_tokens_user_id;
+DROP INDEX IF EXISTS idx_password_history_user_id;
+
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS password_history;
+
+ALTER TABLE users
+DROP COLUMN must_change_password,
+DROP COLUMN password_changed_at;
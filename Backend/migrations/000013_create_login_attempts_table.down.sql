@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_login_attempts_created_at;
+DROP INDEX IF EXISTS idx_login_attempts_ip_created_at;
+DROP INDEX IF EXISTS idx_login_attempts_email_created_at;
+
+DROP TABLE IF EXISTS login_attempts;
+
+ALTER TABLE users
+DROP COLUMN failed_login_count,
+DROP COLUMN last_failed_login_at,
+DROP COLUMN locked_until;
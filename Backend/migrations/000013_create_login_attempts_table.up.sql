@@ -0,0 +1,19 @@
+ALTER TABLE users
+ADD COLUMN failed_login_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_failed_login_at TIMESTAMPTZ,
+ADD COLUMN locked_until TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(100) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(64) NOT NULL,
+    user_agent TEXT,
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_attempts_email_created_at ON login_attempts(email, created_at);
+CREATE INDEX idx_login_attempts_ip_created_at ON login_attempts(ip_address, created_at);
+CREATE INDEX idx_login_attempts_created_at ON login_attempts(created_at);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_user_status_history_user_id;
+DROP INDEX IF EXISTS idx_users_status;
+
+DROP TABLE IF EXISTS user_status_history;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users
+DROP COLUMN status,
+DROP COLUMN status_reason,
+DROP COLUMN status_changed_at,
+DROP COLUMN status_changed_by_user_id;
@@ -0,0 +1,23 @@
+ALTER TABLE users
+ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'pending',
+ADD COLUMN status_reason TEXT,
+ADD COLUMN status_changed_at TIMESTAMPTZ,
+ADD COLUMN status_changed_by_user_id INTEGER REFERENCES users(id);
+
+UPDATE users SET status = CASE WHEN is_approved THEN 'active' ELSE 'pending' END;
+
+ALTER TABLE users ADD CONSTRAINT users_status_check
+    CHECK (status IN ('pending', 'active', 'suspended', 'rejected', 'offboarded'));
+
+CREATE TABLE IF NOT EXISTS user_status_history (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    changed_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_users_status ON users(status);
+CREATE INDEX idx_user_status_history_user_id ON user_status_history(user_id);
@@ -0,0 +1,9 @@
+DELETE FROM user_roles WHERE scope_type <> '';
+
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_scope_check;
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_pkey;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, role_id);
+
+ALTER TABLE user_roles
+DROP COLUMN scope_type,
+DROP COLUMN scope_value;
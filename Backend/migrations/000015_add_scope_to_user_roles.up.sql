@@ -0,0 +1,10 @@
+-- A role can be held globally (empty scope) or limited to a single site or department.
+ALTER TABLE user_roles
+ADD COLUMN scope_type VARCHAR(20) NOT NULL DEFAULT '',
+ADD COLUMN scope_value VARCHAR(100) NOT NULL DEFAULT '';
+
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_pkey;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, role_id, scope_type, scope_value);
+
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_scope_check
+    CHECK ((scope_type = '' AND scope_value = '') OR (scope_type IN ('site', 'department') AND scope_value <> ''));
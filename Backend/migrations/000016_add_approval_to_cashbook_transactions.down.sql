@@ -0,0 +1,3 @@
+ALTER TABLE cashbook_transactions
+DROP COLUMN approved_by_user_id,
+DROP COLUMN approved_at;
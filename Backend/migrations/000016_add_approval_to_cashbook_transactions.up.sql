@@ -0,0 +1,3 @@
+ALTER TABLE cashbook_transactions
+ADD COLUMN approved_by_user_id INTEGER REFERENCES users(id),
+ADD COLUMN approved_at TIMESTAMPTZ;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS role_template_seeds;
+
+ALTER TABLE roles
+DROP COLUMN description,
+DROP COLUMN template,
+DROP COLUMN created_at;
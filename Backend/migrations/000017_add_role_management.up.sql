@@ -0,0 +1,22 @@
+ALTER TABLE roles
+ADD COLUMN description TEXT NOT NULL DEFAULT '',
+ADD COLUMN template VARCHAR(50),
+ADD COLUMN created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+
+-- Records which built-in role templates have been seeded, so a template role an
+-- admin deleted or renamed is not recreated on the next start.
+CREATE TABLE IF NOT EXISTS role_template_seeds (
+    template VARCHAR(50) PRIMARY KEY,
+    role_id INTEGER REFERENCES roles(id) ON DELETE SET NULL,
+    seeded_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Role administration used to ride on manage:users; keep existing admins able to edit roles.
+INSERT INTO permissions (action) VALUES ('manage:roles') ON CONFLICT (action) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT rp.role_id, mr.id
+FROM role_permissions rp
+JOIN permissions mu ON rp.permission_id = mu.id AND mu.action = 'manage:users'
+JOIN permissions mr ON mr.action = 'manage:roles'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS api_keys;
+
+ALTER TABLE users
+DROP CONSTRAINT users_account_type_check,
+DROP COLUMN account_type;
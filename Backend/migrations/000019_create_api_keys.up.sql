@@ -0,0 +1,23 @@
+-- Service accounts are users that authenticate only with API keys, so they share
+-- the role and permission model of human users.
+ALTER TABLE users
+ADD COLUMN account_type VARCHAR(20) NOT NULL DEFAULT 'human',
+ADD CONSTRAINT users_account_type_check CHECK (account_type IN ('human', 'service'));
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) UNIQUE NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMPTZ,
+    created_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMPTZ,
+    rotated_to_key_id INTEGER REFERENCES api_keys(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS user_recovery_codes;
+
+ALTER TABLE roles DROP COLUMN require_two_factor;
+
+ALTER TABLE users
+DROP COLUMN totp_secret,
+DROP COLUMN totp_pending_secret,
+DROP COLUMN totp_enabled_at,
+DROP COLUMN totp_last_used_step;
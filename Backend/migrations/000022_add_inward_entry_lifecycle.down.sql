@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_inward_entries_status;
+ALTER TABLE inward_entries DROP CONSTRAINT IF EXISTS inward_entries_status_check;
+
+DELETE FROM inward_entries WHERE status = 'cancelled';
+UPDATE inward_entries SET status = CASE
+    WHEN status IN ('second_weighed', 'quality_check', 'gate_out') THEN 'Completed'
+    ELSE 'Pending'
+END;
+
+UPDATE inward_entries SET entry_type = 'Item Export'
+WHERE entry_type = 'Empty Vehicle' AND status = 'Completed' AND material IS NOT NULL AND material <> '';
+
+DROP TABLE IF EXISTS inward_entry_status_history;
+
+ALTER TABLE inward_entries ALTER COLUMN status SET DEFAULT 'Pending';
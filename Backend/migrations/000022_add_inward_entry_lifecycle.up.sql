@@ -0,0 +1,33 @@
+-- Replace the free-form Pending/Completed status with the weighbridge lifecycle.
+ALTER TABLE inward_entries ALTER COLUMN status SET DEFAULT 'gate_in';
+
+-- Completion used to rewrite a loaded empty vehicle's entry_type to 'Item Export';
+-- the original type is kept from now on.
+UPDATE inward_entries SET entry_type = 'Empty Vehicle' WHERE entry_type = 'Item Export';
+
+CREATE TABLE IF NOT EXISTS inward_entry_status_history (
+    id SERIAL PRIMARY KEY,
+    inward_entry_id INTEGER NOT NULL REFERENCES inward_entries(id) ON DELETE CASCADE,
+    from_status VARCHAR(50),
+    to_status VARCHAR(50) NOT NULL,
+    note TEXT,
+    changed_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_inward_entry_status_history_entry_id ON inward_entry_status_history(inward_entry_id);
+
+-- Existing pending entries have their gross weight; completed ones have left the site.
+INSERT INTO inward_entry_status_history (inward_entry_id, from_status, to_status, changed_by_user_id, created_at)
+SELECT id, NULL, 'first_weighed', created_by_user_id, COALESCE(created_at, NOW()) FROM inward_entries;
+
+INSERT INTO inward_entry_status_history (inward_entry_id, from_status, to_status, note, created_at)
+SELECT id, 'first_weighed', 'gate_out', 'Completed before lifecycle tracking', COALESCE(completed_at, created_at, NOW())
+FROM inward_entries WHERE status = 'Completed';
+
+UPDATE inward_entries SET status = CASE WHEN status = 'Completed' THEN 'gate_out' ELSE 'first_weighed' END;
+
+ALTER TABLE inward_entries ADD CONSTRAINT inward_entries_status_check
+    CHECK (status IN ('gate_in', 'first_weighed', 'unloading', 'loading', 'second_weighed', 'quality_check', 'gate_out', 'cancelled'));
+
+CREATE INDEX IF NOT EXISTS idx_inward_entries_status ON inward_entries(status);
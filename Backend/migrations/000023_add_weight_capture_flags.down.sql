@@ -0,0 +1,3 @@
+ALTER TABLE inward_entries
+DROP COLUMN gross_weight_manual,
+DROP COLUMN tare_weight_manual;
@@ -0,0 +1,7 @@
+-- Whether each weight was typed by the operator instead of captured from the indicator.
+-- Everything recorded before the weighbridge integration was keyed by hand.
+ALTER TABLE inward_entries
+ADD COLUMN gross_weight_manual BOOLEAN NOT NULL DEFAULT true,
+ADD COLUMN tare_weight_manual BOOLEAN;
+
+UPDATE inward_entries SET tare_weight_manual = true WHERE tare_weight IS NOT NULL;
@@ -0,0 +1,29 @@
+-- How completing an inward entry of each type changes stock. material_name is
+-- the bucket to post to; NULL posts to the entry's own material.
+CREATE TABLE IF NOT EXISTS inventory_posting_rules (
+    entry_type VARCHAR(50) PRIMARY KEY,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('add', 'deduct', 'none')),
+    material_name VARCHAR(255),
+    updated_by_user_id INTEGER REFERENCES users(id),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO inventory_posting_rules (entry_type, action, material_name) VALUES
+    ('Dry Waste', 'add', 'Unsorted'),
+    ('Water Tanker', 'none', NULL),
+    ('Empty Vehicle', 'deduct', NULL)
+ON CONFLICT (entry_type) DO NOTHING;
+
+-- Every change to inventory.current_stock_kg, with what caused it.
+CREATE TABLE IF NOT EXISTS inventory_movements (
+    id SERIAL PRIMARY KEY,
+    material_name VARCHAR(255) NOT NULL,
+    quantity_kg NUMERIC(12, 2) NOT NULL,
+    source_type VARCHAR(30) NOT NULL,
+    source_id INTEGER,
+    created_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_material ON inventory_movements (material_name, created_at);
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_source ON inventory_movements (source_type, source_id);
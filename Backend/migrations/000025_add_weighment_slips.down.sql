@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS weighment_slips;
+DROP SEQUENCE IF EXISTS weighment_slip_number_seq;
@@ -0,0 +1,15 @@
+-- Weighment slips handed out at gate-out. The number and verification token
+-- are fixed when the slip is first printed; every later print is a duplicate.
+CREATE SEQUENCE IF NOT EXISTS weighment_slip_number_seq;
+
+CREATE TABLE IF NOT EXISTS weighment_slips (
+    id SERIAL PRIMARY KEY,
+    inward_entry_id INTEGER NOT NULL UNIQUE REFERENCES inward_entries(id) ON DELETE CASCADE,
+    slip_number VARCHAR(20) NOT NULL UNIQUE,
+    verification_token VARCHAR(64) NOT NULL UNIQUE,
+    print_count INTEGER NOT NULL DEFAULT 0,
+    first_printed_by_user_id INTEGER REFERENCES users(id),
+    last_printed_by_user_id INTEGER REFERENCES users(id),
+    first_printed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_printed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
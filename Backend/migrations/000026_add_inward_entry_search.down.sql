@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_inward_entries_status_completed;
+DROP INDEX IF EXISTS idx_inward_entries_status_created;
+DROP INDEX IF EXISTS idx_inward_entries_vehicle_search;
+DROP FUNCTION IF EXISTS normalize_vehicle_number(TEXT);
@@ -0,0 +1,14 @@
+-- Vehicle numbers are typed with and without spaces and dashes; searches
+-- compare them in this normalized form.
+CREATE OR REPLACE FUNCTION normalize_vehicle_number(value TEXT) RETURNS TEXT
+    LANGUAGE SQL IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT regexp_replace(upper(value), '[^A-Z0-9]', '', 'g') $$;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_inward_entries_vehicle_search
+    ON inward_entries USING GIN (normalize_vehicle_number(vehicle_number) gin_trgm_ops);
+
+-- Keyset pagination of the pending and completed lists.
+CREATE INDEX IF NOT EXISTS idx_inward_entries_status_created ON inward_entries (status, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_inward_entries_status_completed ON inward_entries (status, completed_at DESC, id DESC);
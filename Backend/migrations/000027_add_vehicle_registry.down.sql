@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_inward_entries_vehicle;
+ALTER TABLE inward_entries DROP COLUMN IF EXISTS tare_weight_stored;
+ALTER TABLE inward_entries DROP COLUMN IF EXISTS vehicle_id;
+DROP TABLE IF EXISTS vehicles;
@@ -0,0 +1,43 @@
+-- One row per vehicle, keyed by its normalized registration number.
+CREATE TABLE IF NOT EXISTS vehicles (
+    id SERIAL PRIMARY KEY,
+    registration_number VARCHAR(20) NOT NULL UNIQUE
+        CHECK (registration_number <> '' AND registration_number = normalize_vehicle_number(registration_number)),
+    owner_partner_id INTEGER REFERENCES partners(id) ON DELETE SET NULL,
+    vehicle_type VARCHAR(50),
+    -- Stored empty weight, used in place of a second weighing until it expires.
+    tare_weight NUMERIC(12, 2) CHECK (tare_weight > 0),
+    tare_valid_until DATE,
+    tare_recorded_at TIMESTAMPTZ,
+    tare_recorded_by_user_id INTEGER REFERENCES users(id),
+    is_blacklisted BOOLEAN NOT NULL DEFAULT false,
+    blacklist_reason TEXT,
+    blacklisted_at TIMESTAMPTZ,
+    blacklisted_by_user_id INTEGER REFERENCES users(id),
+    created_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((tare_weight IS NULL) = (tare_valid_until IS NULL)),
+    CHECK (NOT is_blacklisted OR blacklist_reason IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicles_registration_search ON vehicles USING GIN (registration_number gin_trgm_ops);
+
+ALTER TABLE inward_entries ADD COLUMN IF NOT EXISTS vehicle_id INTEGER REFERENCES vehicles(id);
+-- Set when the tare came from the registry instead of a second weighing.
+ALTER TABLE inward_entries ADD COLUMN IF NOT EXISTS tare_weight_stored BOOLEAN NOT NULL DEFAULT false;
+
+-- Register every vehicle seen so far and link its entries.
+UPDATE inward_entries SET vehicle_number = normalize_vehicle_number(vehicle_number)
+WHERE normalize_vehicle_number(vehicle_number) <> '';
+
+INSERT INTO vehicles (registration_number, created_at)
+SELECT vehicle_number, MIN(created_at) FROM inward_entries
+WHERE vehicle_number <> '' AND vehicle_number = normalize_vehicle_number(vehicle_number) AND length(vehicle_number) <= 20
+GROUP BY vehicle_number
+ON CONFLICT (registration_number) DO NOTHING;
+
+UPDATE inward_entries ie SET vehicle_id = v.id
+FROM vehicles v WHERE v.registration_number = ie.vehicle_number;
+
+CREATE INDEX IF NOT EXISTS idx_inward_entries_vehicle ON inward_entries (vehicle_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS sale_dispute_attachments;
+DROP TABLE IF EXISTS sale_disputes;
+ALTER TABLE material_sales DROP COLUMN IF EXISTS buyer_weight_tons;
+DROP TABLE IF EXISTS material_tolerances;
@@ -0,0 +1,51 @@
+-- Per-material limits on sale deductions and on the difference between our
+-- net weight and the buyer's weighbridge. The '*' row applies to materials
+-- without a row of their own.
+CREATE TABLE IF NOT EXISTS material_tolerances (
+    material_name VARCHAR(255) PRIMARY KEY,
+    max_deduction_percent NUMERIC(5, 2) NOT NULL CHECK (max_deduction_percent BETWEEN 0 AND 100),
+    weight_tolerance_percent NUMERIC(5, 2) NOT NULL CHECK (weight_tolerance_percent BETWEEN 0 AND 100),
+    weight_tolerance_kg NUMERIC(10, 2) NOT NULL CHECK (weight_tolerance_kg >= 0),
+    updated_by_user_id INTEGER REFERENCES users(id),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO material_tolerances (material_name, max_deduction_percent, weight_tolerance_percent, weight_tolerance_kg)
+VALUES ('*', 5, 0.5, 50)
+ON CONFLICT (material_name) DO NOTHING;
+
+ALTER TABLE material_sales ADD COLUMN IF NOT EXISTS buyer_weight_tons NUMERIC(10, 3);
+
+CREATE TABLE IF NOT EXISTS sale_disputes (
+    id SERIAL PRIMARY KEY,
+    sale_id INTEGER NOT NULL REFERENCES material_sales(id) ON DELETE CASCADE,
+    our_weight_tons NUMERIC(10, 3) NOT NULL,
+    buyer_weight_tons NUMERIC(10, 3) NOT NULL,
+    tolerance_tons NUMERIC(10, 3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'under_review', 'resolved')),
+    resolution VARCHAR(30) CHECK (resolution IN ('weight_upheld', 'buyer_weight_accepted', 'settled')),
+    resolved_weight_tons NUMERIC(10, 3),
+    note TEXT,
+    resolution_note TEXT,
+    opened_by_user_id INTEGER REFERENCES users(id),
+    opened_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_by_user_id INTEGER REFERENCES users(id),
+    resolved_at TIMESTAMPTZ,
+    CHECK ((status = 'resolved') = (resolution IS NOT NULL)),
+    CHECK ((status = 'resolved') = (resolved_weight_tons IS NOT NULL))
+);
+
+-- At most one unresolved dispute per sale.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sale_disputes_unresolved ON sale_disputes (sale_id) WHERE status <> 'resolved';
+
+CREATE TABLE IF NOT EXISTS sale_dispute_attachments (
+    id SERIAL PRIMARY KEY,
+    dispute_id INTEGER NOT NULL REFERENCES sale_disputes(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    original_name VARCHAR(255) NOT NULL,
+    file_size BIGINT NOT NULL,
+    file_type VARCHAR(100),
+    file_path VARCHAR(500) NOT NULL,
+    uploaded_by_user_id INTEGER REFERENCES users(id),
+    uploaded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+ALTER TABLE material_sales DROP COLUMN IF EXISTS amount_breakdown;
+DROP TABLE IF EXISTS rounding_rules;
@@ -0,0 +1,18 @@
+-- How sale amounts are rounded. amount is rate x billing weight, gst is the
+-- tax on the rounded amount and total is their sum.
+CREATE TABLE IF NOT EXISTS rounding_rules (
+    scope VARCHAR(20) PRIMARY KEY CHECK (scope IN ('amount', 'gst', 'total')),
+    places SMALLINT NOT NULL CHECK (places BETWEEN 0 AND 2),
+    mode VARCHAR(10) NOT NULL CHECK (mode IN ('half_up', 'half_even', 'up', 'down')),
+    updated_by_user_id INTEGER REFERENCES users(id),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO rounding_rules (scope, places, mode) VALUES
+    ('amount', 2, 'half_up'),
+    ('gst', 2, 'half_up'),
+    ('total', 2, 'half_up')
+ON CONFLICT (scope) DO NOTHING;
+
+-- The server's computation of each sale's amounts, with the rules it used.
+ALTER TABLE material_sales ADD COLUMN IF NOT EXISTS amount_breakdown JSONB;
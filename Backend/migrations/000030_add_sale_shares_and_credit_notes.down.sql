@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS sale_credit_notes;
+DROP SEQUENCE IF EXISTS credit_note_number_seq;
+DROP INDEX IF EXISTS idx_material_sales_active_entry;
+ALTER TABLE material_sales DROP COLUMN IF EXISTS cancelled_at;
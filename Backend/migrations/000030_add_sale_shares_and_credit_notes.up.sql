@@ -0,0 +1,22 @@
+-- A dispatch may be billed over several invoices. Each sale's
+-- original_weight_tons is its share of the entry's net weight; the shares of
+-- a dispatch's uncancelled sales never exceed the net weight.
+ALTER TABLE material_sales ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_material_sales_active_entry ON material_sales (inward_entry_id) WHERE cancelled_at IS NULL;
+
+-- Sales are cancelled by issuing a credit note for the full sale, never deleted.
+CREATE SEQUENCE IF NOT EXISTS credit_note_number_seq;
+
+CREATE TABLE IF NOT EXISTS sale_credit_notes (
+    id SERIAL PRIMARY KEY,
+    credit_note_number VARCHAR(20) NOT NULL UNIQUE,
+    sale_id INTEGER NOT NULL UNIQUE REFERENCES material_sales(id),
+    reason TEXT NOT NULL,
+    weight_tons NUMERIC(10, 3),
+    amount NUMERIC(10, 2) NOT NULL,
+    gst_amount NUMERIC(10, 2) NOT NULL,
+    total_amount NUMERIC(10, 2) NOT NULL,
+    created_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS tax_invoices;
+DROP TABLE IF EXISTS invoice_number_counters;
+DROP TABLE IF EXISTS material_hsn_codes;
+ALTER TABLE partners
+    DROP COLUMN IF EXISTS gstin,
+    DROP COLUMN IF EXISTS address,
+    DROP COLUMN IF EXISTS city,
+    DROP COLUMN IF EXISTS pincode,
+    DROP COLUMN IF EXISTS state_code;
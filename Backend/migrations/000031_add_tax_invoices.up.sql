@@ -0,0 +1,68 @@
+-- Buyer registration details printed on tax invoices. The state code is
+-- taken from the GSTIN when there is one and is only entered for
+-- unregistered buyers.
+ALTER TABLE partners
+    ADD COLUMN IF NOT EXISTS gstin VARCHAR(15),
+    ADD COLUMN IF NOT EXISTS address TEXT,
+    ADD COLUMN IF NOT EXISTS city VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS pincode VARCHAR(6),
+    ADD COLUMN IF NOT EXISTS state_code VARCHAR(2);
+
+CREATE TABLE IF NOT EXISTS material_hsn_codes (
+    material_name VARCHAR(255) PRIMARY KEY,
+    hsn_code VARCHAR(8) NOT NULL CHECK (hsn_code ~ '^[0-9]{4,8}$'),
+    updated_by_user_id INTEGER REFERENCES users(id),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Invoice numbers run without gaps within a financial year. A counter row is
+-- incremented inside the issuing transaction, so a rolled back invoice
+-- releases its number, which a sequence would not.
+CREATE TABLE IF NOT EXISTS invoice_number_counters (
+    financial_year VARCHAR(7) PRIMARY KEY,
+    last_number INTEGER NOT NULL
+);
+
+-- A tax invoice copies the seller, buyer and item details as they were when
+-- it was issued; later edits to partners or HSN codes do not change it.
+CREATE TABLE IF NOT EXISTS tax_invoices (
+    id SERIAL PRIMARY KEY,
+    invoice_number VARCHAR(16) NOT NULL UNIQUE,
+    financial_year VARCHAR(7) NOT NULL,
+    sequence_number INTEGER NOT NULL,
+    invoice_date DATE NOT NULL,
+    sale_id INTEGER NOT NULL UNIQUE REFERENCES material_sales(id),
+    seller_gstin VARCHAR(15) NOT NULL,
+    seller_name VARCHAR(255) NOT NULL,
+    seller_address TEXT,
+    seller_location VARCHAR(100),
+    seller_pincode VARCHAR(6),
+    buyer_partner_id INTEGER NOT NULL REFERENCES partners(id),
+    buyer_name VARCHAR(255) NOT NULL,
+    buyer_gstin VARCHAR(15),
+    buyer_address TEXT,
+    buyer_location VARCHAR(100),
+    buyer_pincode VARCHAR(6),
+    place_of_supply VARCHAR(2) NOT NULL,
+    interstate BOOLEAN NOT NULL,
+    material VARCHAR(255) NOT NULL,
+    hsn_code VARCHAR(8) NOT NULL,
+    quantity_tons NUMERIC(10, 3) NOT NULL,
+    rate_per_ton NUMERIC(10, 2) NOT NULL,
+    taxable_value NUMERIC(12, 2) NOT NULL,
+    gst_rate NUMERIC(5, 2) NOT NULL,
+    cgst_rate NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    cgst_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    sgst_rate NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    sgst_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    igst_rate NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    igst_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    round_off NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    total_amount NUMERIC(12, 2) NOT NULL,
+    vehicle_number VARCHAR(50),
+    created_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (financial_year, sequence_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_invoices_invoice_date ON tax_invoices (invoice_date);
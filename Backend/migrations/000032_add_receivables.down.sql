@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS sale_payment_allocations;
+DROP TABLE IF EXISTS sale_payments;
+DROP SEQUENCE IF EXISTS payment_receipt_number_seq;
@@ -0,0 +1,34 @@
+-- Money received from a party. TDS is tax the buyer deducted and paid to the
+-- government on our behalf; it settles sales like money received. Whatever
+-- is not allocated to sales is held as an advance for later sales.
+CREATE SEQUENCE IF NOT EXISTS payment_receipt_number_seq;
+
+CREATE TABLE IF NOT EXISTS sale_payments (
+    id SERIAL PRIMARY KEY,
+    receipt_number VARCHAR(20) NOT NULL UNIQUE,
+    party_id INTEGER NOT NULL REFERENCES partners(id),
+    payment_date DATE NOT NULL,
+    mode VARCHAR(20) NOT NULL CHECK (mode IN ('cash', 'bank_transfer', 'cheque', 'upi')),
+    reference VARCHAR(100),
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+    tds_amount NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (tds_amount >= 0),
+    note TEXT,
+    cashbook_transaction_id INTEGER REFERENCES cashbook_transactions(id),
+    created_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (amount + tds_amount > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sale_payments_party_date ON sale_payments (party_id, payment_date);
+
+CREATE TABLE IF NOT EXISTS sale_payment_allocations (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES sale_payments(id) ON DELETE CASCADE,
+    sale_id INTEGER NOT NULL REFERENCES material_sales(id),
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    created_by_user_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (payment_id, sale_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sale_payment_allocations_sale_id ON sale_payment_allocations (sale_id);
@@ -0,0 +1,4 @@
+-- Ties each in-flight OIDC login to the browser that started it. The callback
+-- must present the matching cookie, so a victim cannot be logged in to an
+-- attacker's account with a code and state the attacker obtained.
+ALTER TABLE oidc_login_states ADD COLUMN IF NOT EXISTS browser_binding_hash VARCHAR(64);
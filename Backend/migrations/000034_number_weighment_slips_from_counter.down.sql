@@ -0,0 +1,7 @@
+CREATE SEQUENCE IF NOT EXISTS weighment_slip_number_seq;
+
+SELECT setval('weighment_slip_number_seq', last_number)
+FROM weighment_slip_number_counters
+WHERE prefix = 'WS' AND last_number > 0;
+
+DROP TABLE IF EXISTS weighment_slip_number_counters;
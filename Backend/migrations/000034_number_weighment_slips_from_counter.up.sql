@@ -0,0 +1,15 @@
+-- Slip numbers came from a sequence, which skips a number whenever a first
+-- print rolls back. A counter row incremented inside the printing transaction
+-- keeps them gap-free, as invoice_number_counters does for invoices.
+CREATE TABLE IF NOT EXISTS weighment_slip_number_counters (
+    prefix VARCHAR(8) PRIMARY KEY,
+    last_number INTEGER NOT NULL
+);
+
+INSERT INTO weighment_slip_number_counters (prefix, last_number)
+SELECT 'WS', COALESCE(MAX(SUBSTRING(slip_number FROM 4)::INTEGER), 0)
+FROM weighment_slips
+WHERE slip_number ~ '^WS-[0-9]+$'
+ON CONFLICT (prefix) DO NOTHING;
+
+DROP SEQUENCE IF EXISTS weighment_slip_number_seq;
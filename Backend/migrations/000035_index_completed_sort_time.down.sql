@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_inward_entries_status_completed;
+CREATE INDEX IF NOT EXISTS idx_inward_entries_status_completed ON inward_entries (status, completed_at DESC, id DESC);
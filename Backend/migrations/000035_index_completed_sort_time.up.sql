@@ -0,0 +1,5 @@
+-- The completed list sorts and pages on COALESCE(completed_at, created_at), so
+-- entries missing completed_at cannot break the keyset cursor.
+DROP INDEX IF EXISTS idx_inward_entries_status_completed;
+CREATE INDEX IF NOT EXISTS idx_inward_entries_status_completed
+    ON inward_entries (status, COALESCE(completed_at, created_at) DESC, id DESC);
@@ -0,0 +1 @@
+-- idx_inward_entries_vehicle_search belongs to 000026, whose down migration drops it.
@@ -0,0 +1,28 @@
+-- Partial vehicle searches (LIKE '%...%') use a trigram index. Creating pg_trgm
+-- needs superuser, or on PostgreSQL 13+ the CREATE privilege on the database;
+-- an administrator can run CREATE EXTENSION pg_trgm beforehand. Without it the
+-- search index is a btree on the normalized number, which serves exact and
+-- prefix matches only, and partial searches scan the table.
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION WHEN insufficient_privilege OR undefined_file THEN
+    RAISE NOTICE 'pg_trgm unavailable (%), vehicle search uses a btree index', SQLERRM;
+END
+$$;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        IF NOT EXISTS (SELECT 1 FROM pg_indexes
+                       WHERE indexname = 'idx_inward_entries_vehicle_search' AND indexdef LIKE '%gin_trgm_ops%') THEN
+            DROP INDEX IF EXISTS idx_inward_entries_vehicle_search;
+            CREATE INDEX idx_inward_entries_vehicle_search
+                ON inward_entries USING GIN (normalize_vehicle_number(vehicle_number) gin_trgm_ops);
+        END IF;
+    ELSE
+        CREATE INDEX IF NOT EXISTS idx_inward_entries_vehicle_search
+            ON inward_entries (normalize_vehicle_number(vehicle_number) text_pattern_ops);
+    END IF;
+END
+$$;
@@ -0,0 +1,2 @@
+-- The links and registrations added here are indistinguishable from those made
+-- by 000027 and by new entries, so they are kept.
@@ -0,0 +1,16 @@
+-- 000027 registered vehicles by rewriting inward_entries.vehicle_number into its
+-- normalized form. Entries now keep the number as typed and are matched on
+-- normalize_vehicle_number, so register and link any entry that is still
+-- unlinked. Numbers already rewritten by 000027 cannot be restored.
+INSERT INTO vehicles (registration_number, created_at)
+SELECT normalize_vehicle_number(vehicle_number), MIN(created_at) FROM inward_entries
+WHERE vehicle_id IS NULL
+  AND normalize_vehicle_number(vehicle_number) <> ''
+  AND length(normalize_vehicle_number(vehicle_number)) <= 20
+GROUP BY normalize_vehicle_number(vehicle_number)
+ON CONFLICT (registration_number) DO NOTHING;
+
+UPDATE inward_entries ie SET vehicle_id = v.id
+FROM vehicles v
+WHERE ie.vehicle_id IS NULL AND v.registration_number = normalize_vehicle_number(ie.vehicle_number);
+
@@ -0,0 +1,10 @@
+// Package migrations embeds the numbered up/down SQL files so the server binary
+// can apply them itself at startup.
+package migrations
+
+import "embed"
+
+// FS holds every *.up.sql and *.down.sql file in this directory.
+//
+//go:embed *.sql
+var FS embed.FS
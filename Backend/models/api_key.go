@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Account types stored in users.account_type.
+const (
+	AccountTypeHuman   = "human"
+	AccountTypeService = "service"
+)
+
+// ServiceAccount is a non-human user that authenticates with API keys only.
+type ServiceAccount struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	Status      string    `json:"status"`
+	Roles       []string  `json:"roles"`
+	ActiveKeys  int       `json:"active_keys"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateServiceAccountRequest defines the shape for creating a service account.
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+	RoleIDs     []int  `json:"role_ids" binding:"required,min=1"`
+}
+
+// APIKey is a credential issued to a service account. Only a hash of the key is
+// stored; Prefix identifies the key in listings and logs.
+type APIKey struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"service_account_id"`
+	Name            string     `json:"name"`
+	Prefix          string     `json:"prefix"`
+	Scopes          []string   `json:"scopes"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+	CreatedByUserID *int       `json:"created_by_user_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	LastUsedIP      *string    `json:"last_used_ip"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	RotatedToKeyID  *int       `json:"rotated_to_key_id,omitempty"`
+}
+
+// IssuedAPIKey is returned once, when a key is created or rotated. Key is never shown again.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKeyRequest defines the shape for issuing a key. Scopes lists the
+// permissions the key may use, at least one; ExpiresInDays of 0 never expires.
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0,max=3650"`
+}
+
+// RotateAPIKeyRequest defines the optional body for rotating a key. The old key
+// keeps working for GraceHours so callers can switch over.
+type RotateAPIKeyRequest struct {
+	GraceHours int `json:"grace_hours" binding:"min=0,max=720"`
+}
+
+// APIKeyIdentity is what a valid API key authenticates as.
+type APIKeyIdentity struct {
+	KeyID  int
+	UserID int
+	Scopes []string
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLogEntry is one privileged change (or attempted change) made through the admin API.
+type AuditLogEntry struct {
+	ID          int64           `json:"id"`
+	ActorUserID *int            `json:"actor_user_id"`
+	ActorName   *string         `json:"actor_name,omitempty"`
+	Action      string          `json:"action"`
+	TargetType  *string         `json:"target_type"`
+	TargetID    *string         `json:"target_id"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	Method      string          `json:"method"`
+	Route       string          `json:"route"`
+	Path        string          `json:"path"`
+	Query       *string         `json:"query,omitempty"`
+	StatusCode  int             `json:"status_code"`
+	IPAddress   *string         `json:"ip_address"`
+	UserAgent   *string         `json:"user_agent"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AuditLogFilter holds the optional filters for listing audit log entries.
+type AuditLogFilter struct {
+	ActorUserID *int
+	Action      string
+	TargetType  string
+	TargetID    string
+	From        *time.Time
+	To          *time.Time
+	Limit       int
+	Offset      int
+}
+
+// AuditLogPage is one page of audit log entries with the total number of matches.
+type AuditLogPage struct {
+	Entries []AuditLogEntry `json:"entries"`
+	Total   int             `json:"total"`
+	Limit   int             `json:"limit"`
+	Offset  int             `json:"offset"`
+}
+
+// UserSnapshot is the security-relevant state of a user recorded before and after admin changes.
+type UserSnapshot struct {
+	FullName           string           `json:"full_name"`
+	Username           *string          `json:"username"`
+	Email              string           `json:"email"`
+	Designation        *string          `json:"designation"`
+	Status             string           `json:"status"`
+	StatusReason       *string          `json:"status_reason,omitempty"`
+	MustChangePassword bool             `json:"must_change_password"`
+	Roles              []RoleAssignment `json:"roles"`
+}
+
+// RoleSnapshot is the state of a role recorded before and after admin changes.
+type RoleSnapshot struct {
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	RequireTwoFactor bool     `json:"require_two_factor"`
+	Permissions      []string `json:"permissions"`
+}
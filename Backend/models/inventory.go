@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// UnsortedMaterial is the inventory bucket that incoming mixed waste is posted
+// to and that sorting logs draw from.
+const UnsortedMaterial = "Unsorted"
+
+// Inventory posting actions for an entry type.
+const (
+	PostingActionAdd    = "add"
+	PostingActionDeduct = "deduct"
+	PostingActionNone   = "none"
+)
+
+// Inventory movement sources recorded in inventory_movements.source_type.
+const (
+	MovementSourceInwardEntry = "inward_entry"
+	MovementSourceSortingLog  = "sorting_log"
+	MovementSourceAdjustment  = "adjustment"
+)
+
+// InventoryPostingRule says how completing an inward entry of EntryType changes stock.
+type InventoryPostingRule struct {
+	EntryType string `json:"entry_type"`
+	Action    string `json:"action"`
+	// MaterialName is the bucket to post to; nil means the entry's own material.
+	MaterialName    *string   `json:"material_name"`
+	UpdatedByUserID *int      `json:"updated_by_user_id,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// UpdateInventoryPostingRuleRequest defines the shape for changing an entry type's posting rule.
+type UpdateInventoryPostingRuleRequest struct {
+	Action       string  `json:"action" binding:"required,oneof=add deduct none"`
+	MaterialName *string `json:"material_name"`
+}
+
+// InventoryMovement is one change to a material's stock.
+type InventoryMovement struct {
+	ID            int       `json:"id"`
+	MaterialName  string    `json:"material_name"`
+	QuantityTons  float64   `json:"quantity_tons"`
+	SourceType    string    `json:"source_type"`
+	SourceID      *int      `json:"source_id"`
+	CreatedByName *string   `json:"created_by_name,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
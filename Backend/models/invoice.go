@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// UpdatePartnerTaxDetailsRequest sets the details a partner's tax invoices
+// are addressed with. StateCode is only needed when there is no GSTIN.
+type UpdatePartnerTaxDetailsRequest struct {
+	GSTIN     string `json:"gstin"`
+	Address   string `json:"address"`
+	City      string `json:"city"`
+	Pincode   string `json:"pincode" binding:"omitempty,len=6,numeric"`
+	StateCode string `json:"state_code" binding:"omitempty,len=2,numeric"`
+}
+
+// MaterialHSNCode is the HSN code a material is invoiced under.
+type MaterialHSNCode struct {
+	MaterialName    string    `json:"material_name"`
+	HSNCode         string    `json:"hsn_code"`
+	UpdatedByUserID *int      `json:"updated_by_user_id,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// UpdateMaterialHSNCodeRequest defines the shape for setting a material's HSN code.
+type UpdateMaterialHSNCodeRequest struct {
+	HSNCode string `json:"hsn_code" binding:"required,numeric,min=4,max=8"`
+}
+
+// TaxInvoice is the GST tax invoice issued for a sale. Seller, buyer and
+// item details are copied when it is issued.
+type TaxInvoice struct {
+	ID             int       `json:"id"`
+	InvoiceNumber  string    `json:"invoice_number"`
+	FinancialYear  string    `json:"financial_year"`
+	SequenceNumber int       `json:"sequence_number"`
+	InvoiceDate    time.Time `json:"invoice_date"`
+	SaleID         int       `json:"sale_id"`
+	VehicleNumber  *string   `json:"vehicle_number,omitempty"`
+
+	SellerGSTIN    string  `json:"seller_gstin"`
+	SellerName     string  `json:"seller_name"`
+	SellerAddress  *string `json:"seller_address,omitempty"`
+	SellerLocation *string `json:"seller_location,omitempty"`
+	SellerPincode  *string `json:"seller_pincode,omitempty"`
+
+	BuyerPartnerID int     `json:"buyer_partner_id"`
+	BuyerName      string  `json:"buyer_name"`
+	BuyerGSTIN     *string `json:"buyer_gstin,omitempty"`
+	BuyerAddress   *string `json:"buyer_address,omitempty"`
+	BuyerLocation  *string `json:"buyer_location,omitempty"`
+	BuyerPincode   *string `json:"buyer_pincode,omitempty"`
+	// PlaceOfSupply is the buyer's state code. Supplies outside the seller's
+	// state carry IGST, those within it CGST and SGST.
+	PlaceOfSupply string `json:"place_of_supply"`
+	Interstate    bool   `json:"interstate"`
+
+	Material     string  `json:"material"`
+	HSNCode      string  `json:"hsn_code"`
+	QuantityTons float64 `json:"quantity_tons"`
+	RatePerTon   float64 `json:"rate_per_ton"`
+	TaxableValue float64 `json:"taxable_value"`
+	GSTRate      float64 `json:"gst_rate"`
+	CGSTRate     float64 `json:"cgst_rate"`
+	CGSTAmount   float64 `json:"cgst_amount"`
+	SGSTRate     float64 `json:"sgst_rate"`
+	SGSTAmount   float64 `json:"sgst_amount"`
+	IGSTRate     float64 `json:"igst_rate"`
+	IGSTAmount   float64 `json:"igst_amount"`
+	RoundOff     float64 `json:"round_off"`
+	TotalAmount  float64 `json:"total_amount"`
+
+	// CreditNoteNumber is set when the sale has since been cancelled.
+	CreditNoteNumber *string   `json:"credit_note_number,omitempty"`
+	CreatedByUserID  *int      `json:"created_by_user_id,omitempty"`
+	CreatedByName    *string   `json:"created_by_name,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
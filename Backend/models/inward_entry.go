@@ -0,0 +1,155 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Inward entry types recorded at gate-in.
+const (
+	EntryTypeDryWaste     = "Dry Waste"
+	EntryTypeWaterTanker  = "Water Tanker"
+	EntryTypeEmptyVehicle = "Empty Vehicle"
+)
+
+// IsOutgoingEntryType reports whether vehicles of this type arrive empty and leave loaded.
+func IsOutgoingEntryType(entryType string) bool {
+	return entryType == EntryTypeEmptyVehicle
+}
+
+// Weighbridge lifecycle states stored in inward_entries.status.
+const (
+	InwardStatusGateIn        = "gate_in"
+	InwardStatusFirstWeighed  = "first_weighed"
+	InwardStatusUnloading     = "unloading"
+	InwardStatusLoading       = "loading"
+	InwardStatusSecondWeighed = "second_weighed"
+	InwardStatusQualityCheck  = "quality_check"
+	InwardStatusGateOut       = "gate_out"
+	InwardStatusCancelled     = "cancelled"
+)
+
+// inwardStatusTransitions lists, for each state, the states an entry may move to.
+var inwardStatusTransitions = map[string][]string{
+	InwardStatusGateIn:        {InwardStatusFirstWeighed, InwardStatusCancelled},
+	InwardStatusFirstWeighed:  {InwardStatusUnloading, InwardStatusLoading, InwardStatusCancelled},
+	InwardStatusUnloading:     {InwardStatusSecondWeighed, InwardStatusCancelled},
+	InwardStatusLoading:       {InwardStatusSecondWeighed, InwardStatusCancelled},
+	InwardStatusSecondWeighed: {InwardStatusQualityCheck, InwardStatusGateOut},
+	InwardStatusQualityCheck:  {InwardStatusGateOut},
+	InwardStatusGateOut:       {},
+	InwardStatusCancelled:     {},
+}
+
+// InwardPendingStatuses are the states of entries still waiting for their second weighing.
+var InwardPendingStatuses = []string{InwardStatusGateIn, InwardStatusFirstWeighed, InwardStatusUnloading, InwardStatusLoading}
+
+// InwardWeighedStatuses are the states of entries whose net weight is final.
+var InwardWeighedStatuses = []string{InwardStatusSecondWeighed, InwardStatusQualityCheck, InwardStatusGateOut}
+
+// CanTransitionInwardStatus reports whether an entry in state from may move to state to.
+func CanTransitionInwardStatus(from, to string) bool {
+	for _, allowed := range inwardStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// InwardStatusChange represents a row in the inward_entry_status_history table.
+type InwardStatusChange struct {
+	ID              int       `json:"id"`
+	InwardEntryID   int       `json:"inward_entry_id"`
+	FromStatus      *string   `json:"from_status"`
+	ToStatus        string    `json:"to_status"`
+	Note            *string   `json:"note,omitempty"`
+	ChangedByUserID *int      `json:"changed_by_user_id,omitempty"`
+	ChangedByName   *string   `json:"changed_by_name,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TransitionInwardEntryRequest moves an entry through the steps that do not
+// record a weight. Weighings go through create and complete instead.
+type TransitionInwardEntryRequest struct {
+	Status string `json:"status" binding:"required,oneof=unloading loading quality_check gate_out cancelled"`
+	Note   string `json:"note"`
+}
+
+// NormalizeVehicleNumber upper-cases a registration number and drops spaces,
+// dashes and other separators, matching normalize_vehicle_number in SQL.
+func NormalizeVehicleNumber(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(value) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// InwardEntryFilter holds the optional filters for listing pending or
+// completed inward entries. Dates and weights apply to the first weighing for
+// pending entries and to the second weighing and net weight for completed ones.
+type InwardEntryFilter struct {
+	From          *time.Time
+	To            *time.Time
+	VehicleNumber string
+	// Search matches any part of the vehicle number.
+	Search        string
+	PartyID       *int
+	SourceID      *int
+	Material      string
+	EntryType     string
+	MinWeightTons *float64
+	MaxWeightTons *float64
+	After         *InwardEntryCursor
+	Limit         int
+}
+
+// InwardEntryCursor marks the last entry of a page; the next page starts
+// strictly after it in list order.
+type InwardEntryCursor struct {
+	At time.Time
+	ID int
+}
+
+// String encodes the cursor for the next_cursor response field.
+func (c InwardEntryCursor) String() string {
+	raw := strconv.FormatInt(c.At.UnixMicro(), 10) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseInwardEntryCursor decodes a cursor produced by InwardEntryCursor.String.
+func ParseInwardEntryCursor(value string) (*InwardEntryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	at, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	micros, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	entryID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	return &InwardEntryCursor{At: time.UnixMicro(micros), ID: entryID}, nil
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// InwardEntryPage is one page of inward entries with the total number of matches.
+type InwardEntryPage struct {
+	Entries    []InwardEntry `json:"entries"`
+	Total      int           `json:"total"`
+	Limit      int           `json:"limit"`
+	NextCursor *string       `json:"next_cursor"`
+}
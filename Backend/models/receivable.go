@@ -0,0 +1,128 @@
+package models
+
+import "time"
+
+// Ways a party can pay.
+const (
+	PaymentModeCash         = "cash"
+	PaymentModeBankTransfer = "bank_transfer"
+	PaymentModeCheque       = "cheque"
+	PaymentModeUPI          = "upi"
+)
+
+// SalePayment is money received from a party, with any TDS the party
+// deducted. Amount plus TDSAmount settles the party's sales; the part not yet
+// allocated is an advance. CashbookApprovedAt is empty while a payment posted
+// to the cashbook awaits approval there.
+type SalePayment struct {
+	ID                    int                     `json:"id"`
+	ReceiptNumber         string                  `json:"receipt_number"`
+	PartyID               int                     `json:"party_id"`
+	PartyName             string                  `json:"party_name"`
+	PaymentDate           time.Time               `json:"payment_date"`
+	Mode                  string                  `json:"mode"`
+	Reference             *string                 `json:"reference,omitempty"`
+	Amount                float64                 `json:"amount"`
+	TDSAmount             float64                 `json:"tds_amount"`
+	AllocatedAmount       float64                 `json:"allocated_amount"`
+	UnallocatedAmount     float64                 `json:"unallocated_amount"`
+	Note                  *string                 `json:"note,omitempty"`
+	CashbookTransactionID *int                    `json:"cashbook_transaction_id,omitempty"`
+	CashbookApprovedAt    *time.Time              `json:"cashbook_approved_at,omitempty"`
+	CreatedByName         *string                 `json:"created_by_name,omitempty"`
+	CreatedAt             time.Time               `json:"created_at"`
+	Allocations           []SalePaymentAllocation `json:"allocations,omitempty"`
+}
+
+// SalePaymentAllocation applies part of a payment to one sale.
+type SalePaymentAllocation struct {
+	SaleID        int       `json:"sale_id"`
+	InvoiceNumber *string   `json:"invoice_number,omitempty"`
+	SaleDate      time.Time `json:"sale_date"`
+	Amount        float64   `json:"amount"`
+}
+
+// PaymentAllocationRequest applies Amount of a payment to a sale.
+type PaymentAllocationRequest struct {
+	SaleID int     `json:"sale_id" binding:"required"`
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+}
+
+// AllocatePaymentRequest applies a payment's unallocated amount to sales.
+// Without allocations it settles the party's oldest outstanding sales first.
+type AllocatePaymentRequest struct {
+	Allocations []PaymentAllocationRequest `json:"allocations" binding:"dive"`
+}
+
+// CreateSalePaymentRequest records a payment. Allocations are applied as
+// listed; without them AutoAllocate settles the party's oldest outstanding
+// sales first, and otherwise the whole payment is held as an advance.
+// PostToCashbook adds a cash payment to the cashbook as cash in, pending
+// approval like any other cashbook entry.
+type CreateSalePaymentRequest struct {
+	PartyID        int                        `json:"party_id" binding:"required"`
+	PaymentDate    string                     `json:"payment_date" binding:"required"`
+	Mode           string                     `json:"mode" binding:"required,oneof=cash bank_transfer cheque upi"`
+	Reference      string                     `json:"reference"`
+	Amount         float64                    `json:"amount" binding:"gte=0"`
+	TDSAmount      float64                    `json:"tds_amount" binding:"gte=0"`
+	Note           string                     `json:"note"`
+	Allocations    []PaymentAllocationRequest `json:"allocations" binding:"dive"`
+	AutoAllocate   bool                       `json:"auto_allocate"`
+	PostToCashbook bool                       `json:"post_to_cashbook"`
+}
+
+// PartyReceivable is what a party owes as of a date, with the outstanding
+// sales aged by days since the sale.
+type PartyReceivable struct {
+	PartyID          int     `json:"party_id"`
+	PartyName        string  `json:"party_name"`
+	OutstandingTotal float64 `json:"outstanding_total"`
+	Days0To30        float64 `json:"days_0_30"`
+	Days31To60       float64 `json:"days_31_60"`
+	Days61To90       float64 `json:"days_61_90"`
+	DaysOver90       float64 `json:"days_over_90"`
+	// Advance is payment not yet allocated to any sale.
+	Advance    float64 `json:"advance"`
+	NetBalance float64 `json:"net_balance"`
+}
+
+// ReceivablesReport lists party balances as of a date.
+type ReceivablesReport struct {
+	AsOf    time.Time         `json:"as_of"`
+	Parties []PartyReceivable `json:"parties"`
+}
+
+// Statement line types.
+const (
+	StatementSale       = "sale"
+	StatementCreditNote = "credit_note"
+	StatementPayment    = "payment"
+	StatementTDS        = "tds"
+)
+
+// StatementLine is one movement on a party's account. Debits are amounts
+// billed, credits amounts settled.
+type StatementLine struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"`
+	Reference   string    `json:"reference"`
+	Description string    `json:"description"`
+	Debit       float64   `json:"debit"`
+	Credit      float64   `json:"credit"`
+	Balance     float64   `json:"balance"`
+}
+
+// PartyStatement is a party's account over a period. A positive balance is
+// owed by the party.
+type PartyStatement struct {
+	PartyID        int             `json:"party_id"`
+	PartyName      string          `json:"party_name"`
+	From           *time.Time      `json:"from,omitempty"`
+	To             *time.Time      `json:"to,omitempty"`
+	OpeningBalance float64         `json:"opening_balance"`
+	TotalDebit     float64         `json:"total_debit"`
+	TotalCredit    float64         `json:"total_credit"`
+	ClosingBalance float64         `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Deduction types a sale may apply to the weighed net weight.
+const (
+	DeductionNone       = "none"
+	DeductionPercentage = "percentage"
+	DeductionFixed      = "fixed"
+)
+
+// DefaultToleranceMaterial names the tolerance row used for materials without their own.
+const DefaultToleranceMaterial = "*"
+
+// Sale dispute states and resolutions.
+const (
+	DisputeStatusOpen        = "open"
+	DisputeStatusUnderReview = "under_review"
+	DisputeStatusResolved    = "resolved"
+
+	DisputeResolutionWeightUpheld        = "weight_upheld"
+	DisputeResolutionBuyerWeightAccepted = "buyer_weight_accepted"
+	DisputeResolutionSettled             = "settled"
+)
+
+// CanTransitionDisputeStatus reports whether a dispute in state from may move to state to.
+func CanTransitionDisputeStatus(from, to string) bool {
+	switch from {
+	case DisputeStatusOpen:
+		return to == DisputeStatusUnderReview || to == DisputeStatusResolved
+	case DisputeStatusUnderReview:
+		return to == DisputeStatusResolved
+	}
+	return false
+}
+
+// RoundTons rounds a weight to the kilogram, the precision sale weights are stored at.
+func RoundTons(tons float64) float64 {
+	return math.Round(tons*1000) / 1000
+}
+
+// MaterialTolerance limits sale deductions and the accepted difference from
+// the buyer's weighbridge for one material.
+type MaterialTolerance struct {
+	MaterialName        string  `json:"material_name"`
+	MaxDeductionPercent float64 `json:"max_deduction_percent"`
+	// A buyer's figure within the larger of WeightTolerancePercent of our net
+	// weight and WeightToleranceKg is accepted without a dispute.
+	WeightTolerancePercent float64   `json:"weight_tolerance_percent"`
+	WeightToleranceKg      float64   `json:"weight_tolerance_kg"`
+	UpdatedByUserID        *int      `json:"updated_by_user_id,omitempty"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+// AllowedVarianceTons is the largest difference from netTons accepted without a dispute.
+func (t *MaterialTolerance) AllowedVarianceTons(netTons float64) float64 {
+	return RoundTons(math.Max(netTons*t.WeightTolerancePercent/100, t.WeightToleranceKg/1000))
+}
+
+// UpdateMaterialToleranceRequest defines the shape for setting a material's tolerances.
+type UpdateMaterialToleranceRequest struct {
+	MaxDeductionPercent    float64 `json:"max_deduction_percent" binding:"gte=0,lte=100"`
+	WeightTolerancePercent float64 `json:"weight_tolerance_percent" binding:"gte=0,lte=100"`
+	WeightToleranceKg      float64 `json:"weight_tolerance_kg" binding:"gte=0"`
+}
+
+// SaleDispute records a buyer's weighbridge figure that differs from our net
+// weight by more than the material's tolerance.
+type SaleDispute struct {
+	ID                 int        `json:"id"`
+	SaleID             int        `json:"sale_id"`
+	InwardEntryID      int        `json:"inward_entry_id"`
+	VehicleNumber      string     `json:"vehicle_number"`
+	MaterialName       *string    `json:"material_name"`
+	PartyName          *string    `json:"party_name"`
+	OurWeightTons      float64    `json:"our_weight_tons"`
+	BuyerWeightTons    float64    `json:"buyer_weight_tons"`
+	DifferenceTons     float64    `json:"difference_tons"`
+	ToleranceTons      float64    `json:"tolerance_tons"`
+	Status             string     `json:"status"`
+	Resolution         *string    `json:"resolution"`
+	ResolvedWeightTons *float64   `json:"resolved_weight_tons"`
+	Note               *string    `json:"note"`
+	ResolutionNote     *string    `json:"resolution_note"`
+	OpenedByName       *string    `json:"opened_by_name,omitempty"`
+	OpenedAt           time.Time  `json:"opened_at"`
+	ResolvedByName     *string    `json:"resolved_by_name,omitempty"`
+	ResolvedAt         *time.Time `json:"resolved_at"`
+
+	Attachments []SaleDisputeAttachment `json:"attachments,omitempty"`
+}
+
+// SaleDisputeAttachment is a file kept as evidence for a dispute, such as the
+// buyer's weighment slip.
+type SaleDisputeAttachment struct {
+	ID               int       `json:"id"`
+	DisputeID        int       `json:"dispute_id"`
+	FileName         string    `json:"file_name"`
+	OriginalName     string    `json:"original_name"`
+	FileSize         int64     `json:"file_size"`
+	FileType         string    `json:"file_type"`
+	FilePath         string    `json:"-"`
+	UploadedByUserID *int      `json:"uploaded_by_user_id,omitempty"`
+	UploadedAt       time.Time `json:"uploaded_at"`
+}
+
+// OpenSaleDisputeRequest records a buyer's weighbridge figure reported after the sale.
+type OpenSaleDisputeRequest struct {
+	BuyerWeightTons float64 `json:"buyer_weight_tons" binding:"required,gt=0"`
+	Note            string  `json:"note"`
+}
+
+// UpdateSaleDisputeRequest moves a dispute under review or resolves it.
+// ResolvedWeightTons is required for a settled resolution and ignored otherwise.
+type UpdateSaleDisputeRequest struct {
+	Status             string   `json:"status" binding:"required,oneof=under_review resolved"`
+	Resolution         string   `json:"resolution" binding:"omitempty,oneof=weight_upheld buyer_weight_accepted settled"`
+	ResolvedWeightTons *float64 `json:"resolved_weight_tons"`
+	Note               string   `json:"note"`
+}
+
+// RoundingRule sets how one sale figure is rounded: "amount" is rate x
+// billing weight, "gst" the tax on the rounded amount, "total" their sum.
+type RoundingRule struct {
+	Scope           string    `json:"scope"`
+	Places          int32     `json:"places"`
+	Mode            string    `json:"mode"`
+	UpdatedByUserID *int      `json:"updated_by_user_id,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// UpdateRoundingRuleRequest defines the shape for changing a rounding rule.
+type UpdateRoundingRuleRequest struct {
+	Places *int32 `json:"places" binding:"required,gte=0,lte=2"`
+	Mode   string `json:"mode" binding:"required,oneof=half_up half_even up down"`
+}
+
+// SaleCreditNote cancels a sale in full. The sale's weight share becomes
+// available to bill again.
+type SaleCreditNote struct {
+	ID               int       `json:"id"`
+	CreditNoteNumber string    `json:"credit_note_number"`
+	SaleID           int       `json:"sale_id"`
+	InwardEntryID    int       `json:"inward_entry_id"`
+	PartyName        *string   `json:"party_name,omitempty"`
+	Reason           string    `json:"reason"`
+	WeightTons       *float64  `json:"weight_tons"`
+	Amount           float64   `json:"amount"`
+	GSTAmount        float64   `json:"gst_amount"`
+	TotalAmount      float64   `json:"total_amount"`
+	CreatedByUserID  *int      `json:"created_by_user_id,omitempty"`
+	CreatedByName    *string   `json:"created_by_name,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// CreateSaleCreditNoteRequest cancels a sale.
+type CreateSaleCreditNoteRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
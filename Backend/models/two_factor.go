@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// TwoFactorState is a user's stored TOTP configuration.
+type TwoFactorState struct {
+	Secret        *string
+	PendingSecret *string
+	EnabledAt     *time.Time
+	LastUsedStep  *int64
+	// Required is true when any of the user's roles enforces two-factor authentication.
+	Required bool
+}
+
+// Enabled reports whether the user has completed TOTP enrolment.
+func (s *TwoFactorState) Enabled() bool {
+	return s.Secret != nil && s.EnabledAt != nil
+}
+
+// TwoFactorStatus is the caller-facing view of a user's two-factor setup.
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	Required               bool       `json:"required"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorEnrollment is returned when starting enrolment, for the authenticator app.
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TwoFactorCodeRequest carries a code from the user's authenticator app.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest requires both the password and a current code.
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorLoginRequest completes a login challenge with either an authenticator code or a recovery code.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// RoleTwoFactorRequest sets whether holders of a role must use two-factor authentication.
+type RoleTwoFactorRequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
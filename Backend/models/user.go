@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/solaris-hms/mrf-backend/billing"
+)
 
 // User represents the users table in the database.
 type User struct {
@@ -11,14 +15,108 @@ type User struct {
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
 	IsApproved   bool      `json:"is_approved"`
+	Status       string    `json:"status"`
+	StatusReason *string   `json:"status_reason,omitempty"`
+	AccountType  string    `json:"account_type"`
 	CreatedAt    time.Time `json:"created_at"`
 	RoleName     *string   `json:"role_name,omitempty"`
+	RoleIDs      []int     `json:"role_ids"`
+	Roles        []string  `json:"roles"`
+
+	MustChangePassword bool       `json:"must_change_password"`
+	FailedLoginCount   int        `json:"-"`
+	LockedUntil        *time.Time `json:"-"`
+}
+
+// User lifecycle states stored in users.status.
+const (
+	UserStatusPending    = "pending"
+	UserStatusActive     = "active"
+	UserStatusSuspended  = "suspended"
+	UserStatusRejected   = "rejected"
+	UserStatusOffboarded = "offboarded"
+)
+
+// userStatusTransitions lists, for each state, the states a user may move to.
+var userStatusTransitions = map[string][]string{
+	UserStatusPending:    {UserStatusActive, UserStatusRejected},
+	UserStatusActive:     {UserStatusSuspended, UserStatusOffboarded},
+	UserStatusSuspended:  {UserStatusActive, UserStatusOffboarded},
+	UserStatusRejected:   {UserStatusPending},
+	UserStatusOffboarded: {},
+}
+
+// CanTransitionUserStatus reports whether a user in state from may move to state to.
+func CanTransitionUserStatus(from, to string) bool {
+	for _, allowed := range userStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UserStatusChange represents a row in the user_status_history table.
+type UserStatusChange struct {
+	ID              int       `json:"id"`
+	UserID          int       `json:"user_id"`
+	FromStatus      string    `json:"from_status"`
+	ToStatus        string    `json:"to_status"`
+	Reason          *string   `json:"reason,omitempty"`
+	ChangedByUserID *int      `json:"changed_by_user_id,omitempty"`
+	ChangedByName   *string   `json:"changed_by_name,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// UpdateUserStatusRequest defines the shape for moving a user to another lifecycle state.
+type UpdateUserStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=pending active suspended rejected offboarded"`
+	Reason string `json:"reason"`
+}
+
+// RejectUserRequest defines the optional body for rejecting a registration.
+type RejectUserRequest struct {
+	Reason string `json:"reason"`
 }
 
 // Role represents a single role from the database.
 type Role struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Template    *string `json:"template,omitempty"`
+	UserCount   int     `json:"user_count"`
+	// RequireTwoFactor forces holders of the role to enrol in TOTP before using the app.
+	RequireTwoFactor bool       `json:"require_two_factor"`
+	CreatedAt        *time.Time `json:"created_at,omitempty"`
+}
+
+// RoleTemplate is a built-in role definition with its permission actions.
+type RoleTemplate struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// CreateRoleRequest defines the shape for creating a role. When Template is set,
+// the template's permissions are granted in addition to PermissionIDs.
+type CreateRoleRequest struct {
+	Name          string `json:"name" binding:"required,max=50"`
+	Description   string `json:"description"`
+	Template      string `json:"template"`
+	PermissionIDs []int  `json:"permission_ids"`
+}
+
+// UpdateRoleRequest defines the shape for renaming a role or changing its description.
+type UpdateRoleRequest struct {
+	Name        string `json:"name" binding:"required,max=50"`
+	Description string `json:"description"`
+}
+
+// CloneRoleRequest defines the shape for copying a role and its permissions under a new name.
+type CloneRoleRequest struct {
+	Name        string `json:"name" binding:"required,max=50"`
+	Description string `json:"description"`
 }
 
 // Permission represents a single permission from the database.
@@ -27,11 +125,108 @@ type Permission struct {
 	Action string `json:"action"`
 }
 
+// Scope types a role assignment can be limited to. An empty scope type means the grant is global.
+const (
+	ScopeSite       = "site"
+	ScopeDepartment = "department"
+)
+
+// PermissionGrant is one permission a user holds through a role, optionally limited to a scope.
+type PermissionGrant struct {
+	Action     string `json:"action"`
+	ScopeType  string `json:"scope_type,omitempty"`
+	ScopeValue string `json:"scope_value,omitempty"`
+}
+
+// Grants is the full set of permission grants held by a user.
+type Grants []PermissionGrant
+
+// Actions returns each granted action once, regardless of scope.
+func (g Grants) Actions() []string {
+	seen := make(map[string]bool)
+	actions := []string{}
+	for _, grant := range g {
+		if !seen[grant.Action] {
+			seen[grant.Action] = true
+			actions = append(actions, grant.Action)
+		}
+	}
+	return actions
+}
+
+// Has reports whether the action is granted globally. Scoped grants do not count.
+func (g Grants) Has(action string) bool {
+	for _, grant := range g {
+		if grant.Action == action && grant.ScopeType == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasIn reports whether the action is granted globally or for the given scope.
+func (g Grants) HasIn(action, scopeType, scopeValue string) bool {
+	for _, grant := range g {
+		if grant.Action != action {
+			continue
+		}
+		if grant.ScopeType == "" || (grant.ScopeType == scopeType && grant.ScopeValue == scopeValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes lists the scope values of the given type an action is granted for.
+// global is true when the action is also granted without any scope.
+func (g Grants) Scopes(action, scopeType string) (values []string, global bool) {
+	for _, grant := range g {
+		if grant.Action != action {
+			continue
+		}
+		if grant.ScopeType == "" {
+			global = true
+		} else if grant.ScopeType == scopeType {
+			values = append(values, grant.ScopeValue)
+		}
+	}
+	return values, global
+}
+
+// Restrict limits grants to the given actions. An empty scope list allows nothing.
+func (g Grants) Restrict(scopes []string) Grants {
+	allowed := make(map[string]bool)
+	for _, s := range scopes {
+		allowed[s] = true
+	}
+	restricted := Grants{}
+	for _, grant := range g {
+		if allowed[grant.Action] {
+			restricted = append(restricted, grant)
+		}
+	}
+	return restricted
+}
+
+// RoleAssignment is a role held by a user, optionally limited to a site or department.
+type RoleAssignment struct {
+	RoleID     int    `json:"role_id" binding:"required"`
+	RoleName   string `json:"role_name,omitempty"`
+	ScopeType  string `json:"scope_type" binding:"omitempty,oneof=site department"`
+	ScopeValue string `json:"scope_value"`
+}
+
 // Partner represents a source, destination, or party from the database.
 type Partner struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
 	Type string `json:"type"`
+	// Registration details printed on tax invoices issued to the partner.
+	GSTIN     *string `json:"gstin,omitempty"`
+	Address   *string `json:"address,omitempty"`
+	City      *string `json:"city,omitempty"`
+	Pincode   *string `json:"pincode,omitempty"`
+	StateCode *string `json:"state_code,omitempty"`
 }
 
 // InventoryItem represents a single material's stock from the inventory table.
@@ -52,7 +247,40 @@ type UpdateUserRequest struct {
 	Username    string `json:"username"`
 	Designation string `json:"designation"`
 	Email       string `json:"email" binding:"required,email"`
-	RoleID      int    `json:"role_id" binding:"required"`
+	RoleID      int    `json:"role_id"`
+	RoleIDs     []int  `json:"role_ids"`
+}
+
+// AllRoleIDs returns the requested global roles without duplicates.
+func (r *UpdateUserRequest) AllRoleIDs() []int {
+	return mergeRoleIDs(r.RoleID, r.RoleIDs)
+}
+
+func mergeRoleIDs(roleID int, roleIDs []int) []int {
+	seen := make(map[int]bool)
+	ids := []int{}
+	for _, id := range append([]int{roleID}, roleIDs...) {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// EffectivePermission is one permission a user holds, with the roles that grant it.
+type EffectivePermission struct {
+	Action     string   `json:"action"`
+	ScopeType  string   `json:"scope_type,omitempty"`
+	ScopeValue string   `json:"scope_value,omitempty"`
+	GrantedBy  []string `json:"granted_by"`
+}
+
+// UserAccessSummary lists a user's role assignments and the union of the permissions they grant.
+type UserAccessSummary struct {
+	UserID      int                   `json:"user_id"`
+	Roles       []RoleAssignment      `json:"roles"`
+	Permissions []EffectivePermission `json:"permissions"`
 }
 
 // RegisterRequest defines the shape of the registration request body.
@@ -70,49 +298,139 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// ApprovalRequest defines the shape for the approval request body.
+// RefreshTokenRequest defines the shape of the token refresh request body.
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Session represents a row in the user_sessions table.
+type Session struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	IPAddress     *string    `json:"ip_address,omitempty"`
+	UserAgent     *string    `json:"user_agent,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason *string    `json:"revoked_reason,omitempty"`
+}
+
+// ChangePasswordRequest defines the shape for an authenticated password change.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+// ResetPasswordRequest defines the shape for redeeming an admin-issued reset token.
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// ForcePasswordChangeRequest defines the shape for toggling forced password rotation.
+type ForcePasswordChangeRequest struct {
+	MustChangePassword *bool `json:"must_change_password" binding:"required"`
+}
+
+// LoginAttempt represents a row in the login_attempts table.
+type LoginAttempt struct {
+	ID            int       `json:"id"`
+	Email         string    `json:"email"`
+	UserID        *int      `json:"user_id,omitempty"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     *string   `json:"user_agent,omitempty"`
+	Success       bool      `json:"success"`
+	FailureReason *string   `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LoginAttemptFilter holds the optional filters for listing login attempts.
+type LoginAttemptFilter struct {
+	Email     string
+	IPAddress string
+	Success   *bool
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+// LockedAccount describes a user currently locked out after repeated failed logins.
+type LockedAccount struct {
+	UserID            int        `json:"user_id"`
+	FullName          string     `json:"full_name"`
+	Email             string     `json:"email"`
+	FailedLoginCount  int        `json:"failed_login_count"`
+	LastFailedLoginAt *time.Time `json:"last_failed_login_at"`
+	LockedUntil       time.Time  `json:"locked_until"`
+}
+
+// ApprovalRequest defines the shape for the approval request body. Either
+// role_ids or the older single role_id may be given.
 type ApprovalRequest struct {
-	RoleID int `json:"role_id" binding:"required"`
+	RoleID  int   `json:"role_id"`
+	RoleIDs []int `json:"role_ids"`
+}
+
+// AllRoleIDs returns the requested roles without duplicates.
+func (r *ApprovalRequest) AllRoleIDs() []int {
+	return mergeRoleIDs(r.RoleID, r.RoleIDs)
 }
 
 // InwardEntry represents the inward_entries table in the database.
 type InwardEntry struct {
-	ID              int        `json:"id"`
-	VehicleNumber   string     `json:"vehicle_number"`
-	SourceID        *int       `json:"source_id"`
-	DestinationID   *int       `json:"destination_id"`
-	PartyID         *int       `json:"party_id"`
-	VendorID        *string    `json:"vendor_id"`
-	PartyName       *string    `json:"party_name,omitempty"`
-	VendorName      *string    `json:"vendor_name,omitempty"`
-	Material        *string    `json:"material"`
-	EntryType       string     `json:"entry_type"`
-	GrossWeightTons float64    `json:"gross_weight_tons"`
-	TareWeightTons  *float64   `json:"tare_weight_tons"`
-	NetWeightTons   *float64   `json:"net_weight_tons"`
-	Status          string     `json:"status"`
-	CreatedByUserID int        `json:"created_by_user_id"`
-	CreatedAt       time.Time  `json:"created_at"`
-	CompletedAt     *time.Time `json:"completed_at"`
-	SourceName      *string    `json:"source_name,omitempty"`
+	ID              int      `json:"id"`
+	VehicleNumber   string   `json:"vehicle_number"`
+	SourceID        *int     `json:"source_id"`
+	DestinationID   *int     `json:"destination_id"`
+	PartyID         *int     `json:"party_id"`
+	VendorID        *string  `json:"vendor_id"`
+	PartyName       *string  `json:"party_name,omitempty"`
+	VendorName      *string  `json:"vendor_name,omitempty"`
+	Material        *string  `json:"material"`
+	EntryType       string   `json:"entry_type"`
+	GrossWeightTons float64  `json:"gross_weight_tons"`
+	TareWeightTons  *float64 `json:"tare_weight_tons"`
+	NetWeightTons   *float64 `json:"net_weight_tons"`
+	// GrossWeightManual and TareWeightManual are set when the operator keyed
+	// the weight instead of capturing it from the weighbridge.
+	GrossWeightManual bool  `json:"gross_weight_manual"`
+	TareWeightManual  *bool `json:"tare_weight_manual"`
+	// TareWeightStored is set when the tare came from the vehicle registry.
+	TareWeightStored bool       `json:"tare_weight_stored"`
+	VehicleID        *int       `json:"vehicle_id"`
+	Status           string     `json:"status"`
+	CreatedByUserID  int        `json:"created_by_user_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	CompletedAt      *time.Time `json:"completed_at"`
+	SourceName       *string    `json:"source_name,omitempty"`
 }
 
 // CreateInwardEntryRequest defines the shape for creating a new entry.
 type CreateInwardEntryRequest struct {
-	VehicleNumber   string  `json:"vehicle_number" binding:"required"`
-	SourceID        *int    `json:"source_id"`
-	DestinationID   *int    `json:"destination_id"`
-	PartyID         *int    `json:"party_id"`
-	VendorID        *string `json:"vendor_id"`
-	Material        string  `json:"material"`
-	EntryType       string  `json:"entry_type" binding:"required"`
-	GrossWeightTons float64 `json:"gross_weight_tons" binding:"required"`
+	VehicleNumber string  `json:"vehicle_number" binding:"required"`
+	SourceID      *int    `json:"source_id"`
+	DestinationID *int    `json:"destination_id"`
+	PartyID       *int    `json:"party_id"`
+	VendorID      *string `json:"vendor_id"`
+	Material      string  `json:"material"`
+	EntryType     string  `json:"entry_type" binding:"required"`
+	// GrossWeightTons is only read when ManualWeight is set or no weighbridge
+	// is connected; otherwise the locked indicator reading is captured.
+	GrossWeightTons float64 `json:"gross_weight_tons"`
+	ManualWeight    bool    `json:"manual_weight"`
 }
 
 // CompleteInwardEntryRequest defines the shape for completing an entry.
 type CompleteInwardEntryRequest struct {
-	TareWeightTons float64 `json:"tare_weight_tons" binding:"required"`
-	Material       *string `json:"material"`
+	// TareWeightTons follows the same rules as CreateInwardEntryRequest.GrossWeightTons.
+	TareWeightTons float64 `json:"tare_weight_tons"`
+	ManualWeight   bool    `json:"manual_weight"`
+	// UseStoredTare completes the entry without a second weighing, using the
+	// vehicle's stored tare. TareWeightTons and ManualWeight are then ignored.
+	UseStoredTare bool    `json:"use_stored_tare"`
+	Material      *string `json:"material"`
 }
 
 // Structs for Sorting Log
@@ -135,12 +453,15 @@ type SortingLog struct {
 }
 
 type CashbookTransaction struct {
-	ID          int       `json:"id"`
-	Date        time.Time `json:"date"`
-	Time        time.Time `json:"time"`
-	Description string    `json:"description"`
-	CashIn      float64   `json:"cash_in"`
-	CashOut     float64   `json:"cash_out"`
+	ID          int        `json:"id"`
+	Date        time.Time  `json:"date"`
+	Time        time.Time  `json:"time"`
+	Description string     `json:"description"`
+	CashIn      float64    `json:"cash_in"`
+	CashOut     float64    `json:"cash_out"`
+	CreatedBy   *string    `json:"created_by,omitempty"`
+	ApprovedBy  *string    `json:"approved_by,omitempty"`
+	ApprovedAt  *time.Time `json:"approved_at,omitempty"`
 }
 
 type CreateCashbookTransactionRequest struct {
@@ -172,6 +493,12 @@ type CreateMaterialSaleRequest struct {
 	DeductionAmount    float64 `json:"deduction_amount"`
 	DeductionReason    string  `json:"deduction_reason"`
 	BillingWeightTons  float64 `json:"billing_weight_tons"`
+	// BuyerWeightTons is the buyer's weighbridge figure, when known. A figure
+	// outside the material's tolerance opens a dispute with the sale.
+	BuyerWeightTons *float64 `json:"buyer_weight_tons"`
+	// WeightShareTons is the part of the dispatch's net weight this invoice
+	// bills. Nil bills whatever earlier sales of the dispatch left unbilled.
+	WeightShareTons *float64 `json:"weight_share_tons"`
 }
 
 type MaterialSale struct {
@@ -204,6 +531,16 @@ type MaterialSale struct {
 	DeductionAmount    *float64 `json:"deduction_amount,omitempty"`
 	DeductionReason    *string  `json:"deduction_reason,omitempty"`
 	BillingWeightTons  *float64 `json:"billing_weight_tons,omitempty"`
+	BuyerWeightTons    *float64 `json:"buyer_weight_tons,omitempty"`
+	DisputeID          *int     `json:"dispute_id,omitempty"`
+	DisputeStatus      *string  `json:"dispute_status,omitempty"`
+	// AmountBreakdown is the server's computation of Amount, GSTAmount and TotalAmount.
+	AmountBreakdown *billing.SaleAmounts `json:"amount_breakdown,omitempty"`
+	// CancelledAt and CreditNoteNumber are set once a credit note cancels the sale.
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+	CreditNoteNumber *string    `json:"credit_note_number,omitempty"`
+	// SettledAmount is how much of TotalAmount payments allocated to the sale cover.
+	SettledAmount float64 `json:"settled_amount"`
 }
 type Employee struct {
 	ID          int       `json:"id"`
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Vehicle is a registered vehicle. RegistrationNumber is always normalized
+// with NormalizeVehicleNumber.
+type Vehicle struct {
+	ID                 int     `json:"id"`
+	RegistrationNumber string  `json:"registration_number"`
+	OwnerPartnerID     *int    `json:"owner_partner_id"`
+	OwnerName          *string `json:"owner_name,omitempty"`
+	VehicleType        *string `json:"vehicle_type"`
+	// TareWeightTons is the stored empty weight, usable until TareValidUntil.
+	TareWeightTons  *float64   `json:"tare_weight_tons"`
+	TareValidUntil  *time.Time `json:"tare_valid_until"`
+	TareRecordedAt  *time.Time `json:"tare_recorded_at"`
+	IsBlacklisted   bool       `json:"is_blacklisted"`
+	BlacklistReason *string    `json:"blacklist_reason"`
+	BlacklistedAt   *time.Time `json:"blacklisted_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// HasValidTare reports whether the stored tare can be used on the given day.
+func (v *Vehicle) HasValidTare(on time.Time) bool {
+	if v.TareWeightTons == nil || v.TareValidUntil == nil {
+		return false
+	}
+	y, m, d := on.Date()
+	vy, vm, vd := v.TareValidUntil.Date()
+	return !time.Date(vy, vm, vd, 0, 0, 0, 0, time.UTC).Before(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+}
+
+// CreateVehicleRequest defines the shape for registering a vehicle.
+type CreateVehicleRequest struct {
+	RegistrationNumber string  `json:"registration_number" binding:"required"`
+	OwnerPartnerID     *int    `json:"owner_partner_id"`
+	VehicleType        *string `json:"vehicle_type"`
+}
+
+// UpdateVehicleRequest defines the shape for changing a vehicle's owner and type.
+type UpdateVehicleRequest struct {
+	OwnerPartnerID *int    `json:"owner_partner_id"`
+	VehicleType    *string `json:"vehicle_type"`
+}
+
+// SetVehicleTareRequest stores a vehicle's empty weight. The weight follows the
+// same capture rules as CreateInwardEntryRequest.GrossWeightTons.
+type SetVehicleTareRequest struct {
+	TareWeightTons float64 `json:"tare_weight_tons"`
+	ManualWeight   bool    `json:"manual_weight"`
+	ValidUntil     string  `json:"valid_until" binding:"required"`
+}
+
+// SetVehicleBlacklistRequest blacklists a vehicle, or clears the flag.
+type SetVehicleBlacklistRequest struct {
+	Blacklisted bool   `json:"blacklisted"`
+	Reason      string `json:"reason"`
+}
@@ -103,3 +103,26 @@ type UpdateVendorRequest struct {
 	Status              *string          `json:"status"`
 	Factories           []FactoryRequest `json:"factories"`
 }
+
+// VendorStatusApproved is the status a vendor must hold before inward entries can be linked to it.
+const VendorStatusApproved = "Approved"
+
+// VendorReceipts summarises the weighed inward entries received from a vendor over a period.
+type VendorReceipts struct {
+	VendorID        string                  `json:"vendor_id"`
+	VendorName      string                  `json:"vendor_name"`
+	From            *time.Time              `json:"from"`
+	To              *time.Time              `json:"to"`
+	Trips           int                     `json:"trips"`
+	GrossWeightTons float64                 `json:"gross_weight_tons"`
+	NetWeightTons   float64                 `json:"net_weight_tons"`
+	ByMaterial      []VendorMaterialReceipt `json:"by_material"`
+}
+
+// VendorMaterialReceipt is one material's share of a vendor's receipts.
+type VendorMaterialReceipt struct {
+	Material        string  `json:"material"`
+	Trips           int     `json:"trips"`
+	GrossWeightTons float64 `json:"gross_weight_tons"`
+	NetWeightTons   float64 `json:"net_weight_tons"`
+}
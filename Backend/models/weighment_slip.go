@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// WeighmentSlip is the printed record of a completed inward entry. Print
+// counts above one mean the slip being produced is a duplicate.
+type WeighmentSlip struct {
+	SlipNumber        string    `json:"slip_number"`
+	VerificationToken string    `json:"-"`
+	PrintCount        int       `json:"print_count"`
+	FirstPrintedAt    time.Time `json:"first_printed_at"`
+	LastPrintedAt     time.Time `json:"last_printed_at"`
+
+	InwardEntryID     int        `json:"inward_entry_id"`
+	VehicleNumber     string     `json:"vehicle_number"`
+	EntryType         string     `json:"entry_type"`
+	Material          *string    `json:"material"`
+	SourceName        *string    `json:"source_name"`
+	PartyName         *string    `json:"party_name"`
+	VendorName        *string    `json:"vendor_name"`
+	GrossWeightTons   float64    `json:"gross_weight_tons"`
+	TareWeightTons    float64    `json:"tare_weight_tons"`
+	NetWeightTons     float64    `json:"net_weight_tons"`
+	GrossWeightManual bool       `json:"gross_weight_manual"`
+	TareWeightManual  bool       `json:"tare_weight_manual"`
+	TareWeightStored  bool       `json:"tare_weight_stored"`
+	FirstWeighedAt    time.Time  `json:"first_weighed_at"`
+	SecondWeighedAt   *time.Time `json:"second_weighed_at"`
+	// FirstOperator and SecondOperator recorded the gross and tare weighings.
+	FirstOperator  *string `json:"first_operator"`
+	SecondOperator *string `json:"second_operator"`
+	PrintedBy      *string `json:"printed_by"`
+}
+
+// IsDuplicate reports whether the slip has been printed before.
+func (s *WeighmentSlip) IsDuplicate() bool {
+	return s.PrintCount > 1
+}
+
+// WeighmentSlipVerification is what anyone scanning a slip's QR code sees.
+type WeighmentSlipVerification struct {
+	SlipNumber      string     `json:"slip_number"`
+	VehicleNumber   string     `json:"vehicle_number"`
+	Material        *string    `json:"material"`
+	NetWeightTons   float64    `json:"net_weight_tons"`
+	SecondWeighedAt *time.Time `json:"second_weighed_at"`
+	PrintCount      int        `json:"print_count"`
+	Status          string     `json:"status"`
+}
@@ -0,0 +1,72 @@
+// Package notify delivers user-facing messages such as password reset links.
+// Real deployments can plug in mail or SMS; locally a log or file sink is enough.
+package notify
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is a single notification addressed to a user.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier sends messages to users.
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// FromEnv picks a notifier based on NOTIFIER ("log" or "file"). The file sink
+// writes to NOTIFIER_FILE_PATH, defaulting to notifications.log. It returns nil
+// when NOTIFIER is not set: messages carry secrets such as reset codes, so they
+// are never written to the server log unless that sink is chosen explicitly.
+func FromEnv() (Notifier, error) {
+	switch sink := os.Getenv("NOTIFIER"); sink {
+	case "":
+		return nil, nil
+	case "log":
+		return LogNotifier{}, nil
+	case "file":
+		path := os.Getenv("NOTIFIER_FILE_PATH")
+		if path == "" {
+			path = "notifications.log"
+		}
+		return &FileNotifier{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown NOTIFIER %q (want log or file)", sink)
+	}
+}
+
+// LogNotifier writes messages to the server log. Only suitable for development.
+type LogNotifier struct{}
+
+func (LogNotifier) Send(msg Message) error {
+	log.Printf("Notification to %s: %s\n%s\n", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileNotifier appends messages to a file, one block per message.
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (n *FileNotifier) Send(msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
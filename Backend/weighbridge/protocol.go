@@ -0,0 +1,186 @@
+package weighbridge
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const poundsToKg = 0.45359237
+
+// Frame is one weight report decoded from an indicator.
+type Frame struct {
+	WeightKg float64
+	// Motion is set while the indicator reports the platform as unsettled.
+	Motion bool
+	// OutOfRange is set on overload, underload or other invalid weight.
+	OutOfRange bool
+}
+
+// Protocol decodes one continuous-output format. Encode produces the same
+// format and is used by the indicator simulator.
+type Protocol interface {
+	Name() string
+	Split(data []byte, atEOF bool) (advance int, token []byte, err error)
+	Decode(frame []byte) (Frame, error)
+	Encode(f Frame) []byte
+}
+
+// ProtocolByName returns a supported protocol: "toledo" or "ascii".
+func ProtocolByName(name string) (Protocol, error) {
+	switch strings.ToLower(name) {
+	case "toledo", "mettler":
+		return Toledo{}, nil
+	case "ascii", "", "st-gs":
+		return ASCII{}, nil
+	}
+	return nil, fmt.Errorf("unknown weighbridge protocol %q", name)
+}
+
+var errBadFrame = errors.New("malformed indicator frame")
+
+// Toledo is the Mettler Toledo standard continuous output:
+// STX, three status bytes, six weight digits, six tare digits, CR and an
+// optional checksum byte, which Encode leaves out.
+type Toledo struct{}
+
+func (Toledo) Name() string { return "toledo" }
+
+func (Toledo) Split(data []byte, atEOF bool) (int, []byte, error) {
+	start := bytes.IndexByte(data, 0x02)
+	if start < 0 {
+		return len(data), nil, nil
+	}
+	end := bytes.IndexByte(data[start:], '\r')
+	if end < 0 {
+		if atEOF {
+			return len(data), nil, nil
+		}
+		return start, nil, nil
+	}
+	// A checksum byte after the previous CR may itself look like STX; the frame
+	// starts at the last STX before the CR.
+	frame := data[start+1 : start+end]
+	if i := bytes.LastIndexByte(frame, 0x02); i >= 0 {
+		frame = frame[i+1:]
+	}
+	return start + end + 1, frame, nil
+}
+
+// toledoDecimals maps status word A bits 0-2 to a power of ten.
+var toledoDecimals = [8]int{2, 1, 0, -1, -2, -3, -4, -5}
+
+func (Toledo) Decode(frame []byte) (Frame, error) {
+	if len(frame) < 9 {
+		return Frame{}, errBadFrame
+	}
+	swa, swb := frame[0], frame[1]
+	digits := strings.TrimSpace(string(frame[3:9]))
+	raw, err := strconv.Atoi(digits)
+	if err != nil {
+		return Frame{}, errBadFrame
+	}
+
+	weight := float64(raw) * math.Pow10(toledoDecimals[swa&0x07])
+	if swb&0x02 != 0 {
+		weight = -weight
+	}
+	if swb&0x10 == 0 {
+		weight *= poundsToKg
+	}
+	return Frame{
+		WeightKg:   weight,
+		OutOfRange: swb&0x04 != 0,
+		Motion:     swb&0x08 != 0,
+	}, nil
+}
+
+func (Toledo) Encode(f Frame) []byte {
+	// Whole kilograms, kg units, gross weight.
+	swa := byte(0x20 | 0x08 | 2)
+	swb := byte(0x20 | 0x10)
+	weight := math.Round(f.WeightKg)
+	if weight < 0 {
+		swb |= 0x02
+		weight = -weight
+	}
+	if f.OutOfRange {
+		swb |= 0x04
+	}
+	if f.Motion {
+		swb |= 0x08
+	}
+	return []byte(fmt.Sprintf("\x02%c%c%c%06d%06d\r", swa, swb, byte(0x20), int(weight)%1000000, 0))
+}
+
+// ASCII is the line-based "ST,GS,+0012345kg" format used by many indicators:
+// a stability header (ST stable, US unstable, OL overload), a weight header
+// (GS gross, NT net) and a signed weight with its unit, terminated by CR LF.
+type ASCII struct{}
+
+func (ASCII) Name() string { return "ascii" }
+
+func (ASCII) Split(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if token != nil {
+		token = bytes.TrimSpace(token)
+	}
+	return advance, token, err
+}
+
+func (ASCII) Decode(frame []byte) (Frame, error) {
+	fields := strings.Split(string(frame), ",")
+	if len(fields) < 3 {
+		return Frame{}, errBadFrame
+	}
+
+	var f Frame
+	switch strings.TrimSpace(fields[0]) {
+	case "ST":
+	case "US":
+		f.Motion = true
+	case "OL":
+		f.OutOfRange = true
+		return f, nil
+	default:
+		return Frame{}, errBadFrame
+	}
+
+	value := strings.ReplaceAll(strings.TrimSpace(fields[len(fields)-1]), " ", "")
+	unitStart := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '+' && r != '-' })
+	unit := "kg"
+	if unitStart >= 0 {
+		value, unit = value[:unitStart], strings.ToLower(value[unitStart:])
+	}
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return Frame{}, errBadFrame
+	}
+	switch unit {
+	case "kg":
+	case "t":
+		weight *= 1000
+	case "g":
+		weight /= 1000
+	case "lb":
+		weight *= poundsToKg
+	default:
+		return Frame{}, errBadFrame
+	}
+	f.WeightKg = weight
+	return f, nil
+}
+
+func (ASCII) Encode(f Frame) []byte {
+	header := "ST"
+	if f.OutOfRange {
+		header = "OL"
+	} else if f.Motion {
+		header = "US"
+	}
+	return []byte(fmt.Sprintf("%s,GS,%+08.0fkg\r\n", header, f.WeightKg))
+}
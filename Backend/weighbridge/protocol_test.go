@@ -0,0 +1,168 @@
+package weighbridge
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"testing"
+)
+
+// scanFrames splits a byte stream the way Reader does and decodes every frame.
+func scanFrames(t *testing.T, p Protocol, stream []byte) []Frame {
+	t.Helper()
+	scanner := bufio.NewScanner(bytes.NewReader(stream))
+	scanner.Split(p.Split)
+	var frames []Frame
+	for scanner.Scan() {
+		f, err := p.Decode(scanner.Bytes())
+		if err != nil {
+			t.Fatalf("%s: decode %q: %v", p.Name(), scanner.Bytes(), err)
+		}
+		frames = append(frames, f)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return frames
+}
+
+func sameFrame(a, b Frame) bool {
+	return math.Abs(a.WeightKg-b.WeightKg) < 1e-6 && a.Motion == b.Motion && a.OutOfRange == b.OutOfRange
+}
+
+func TestProtocolRoundTrip(t *testing.T) {
+	frames := []Frame{
+		{WeightKg: 0},
+		{WeightKg: 12340},
+		{WeightKg: 8765, Motion: true},
+		{WeightKg: -40},
+		{WeightKg: 999999},
+	}
+	for _, p := range []Protocol{Toledo{}, ASCII{}} {
+		var stream []byte
+		for _, f := range frames {
+			stream = append(stream, p.Encode(f)...)
+		}
+		got := scanFrames(t, p, stream)
+		if len(got) != len(frames) {
+			t.Fatalf("%s: decoded %d frames, want %d", p.Name(), len(got), len(frames))
+		}
+		for i := range frames {
+			if !sameFrame(got[i], frames[i]) {
+				t.Errorf("%s: frame %d = %+v, want %+v", p.Name(), i, got[i], frames[i])
+			}
+		}
+	}
+}
+
+func TestProtocolOverload(t *testing.T) {
+	toledo := Toledo{}
+	got, err := toledo.Decode(bytes.Trim(toledo.Encode(Frame{WeightKg: 500, OutOfRange: true}), "\x02\r"))
+	if err != nil || !got.OutOfRange || got.WeightKg != 500 {
+		t.Errorf("toledo overload = %+v, %v", got, err)
+	}
+
+	// The ASCII overload header carries no usable weight.
+	ascii := ASCII{}
+	got, err = ascii.Decode(bytes.TrimSpace(ascii.Encode(Frame{WeightKg: 500, OutOfRange: true})))
+	if err != nil || !got.OutOfRange || got.WeightKg != 0 {
+		t.Errorf("ascii overload = %+v, %v", got, err)
+	}
+}
+
+func TestToledoSplitSkipsChecksumAndNoise(t *testing.T) {
+	p := Toledo{}
+	var stream []byte
+	stream = append(stream, "noise\r"...)
+	stream = append(stream, p.Encode(Frame{WeightKg: 100})...)
+	// A checksum byte that happens to equal STX must not be taken for a frame start.
+	stream = append(stream, 0x02)
+	stream = append(stream, p.Encode(Frame{WeightKg: 200})...)
+	// A trailing partial frame is dropped.
+	stream = append(stream, "\x02(0 0001"...)
+
+	got := scanFrames(t, p, stream)
+	if len(got) != 2 || got[0].WeightKg != 100 || got[1].WeightKg != 200 {
+		t.Fatalf("frames = %+v, want 100 kg and 200 kg", got)
+	}
+}
+
+func TestToledoDecodeStatusWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		want  Frame
+	}{
+		{"one decimal", "\x2b\x30\x20012345000000", Frame{WeightKg: 1234.5}},
+		{"tens", "\x29\x30\x20001234000000", Frame{WeightKg: 12340}},
+		{"pounds", "\x2a\x20\x20001000000000", Frame{WeightKg: 1000 * poundsToKg}},
+		{"negative in motion", "\x2a\x3a\x20000050000000", Frame{WeightKg: -50, Motion: true}},
+	}
+	for _, tt := range tests {
+		got, err := Toledo{}.Decode([]byte(tt.frame))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !sameFrame(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		p     Protocol
+		frame string
+	}{
+		{Toledo{}, ""},
+		{Toledo{}, "\x2a\x30\x2000123"},
+		{Toledo{}, "\x2a\x30\x2000x123000000"},
+		{ASCII{}, ""},
+		{ASCII{}, "ST,GS"},
+		{ASCII{}, "XX,GS,+0001000kg"},
+		{ASCII{}, "ST,GS,heavy"},
+		{ASCII{}, "ST,GS,+0001000oz"},
+	}
+	for _, tt := range tests {
+		if f, err := tt.p.Decode([]byte(tt.frame)); err == nil {
+			t.Errorf("%s: Decode(%q) = %+v, want error", tt.p.Name(), tt.frame, f)
+		}
+	}
+}
+
+func TestASCIIDecodeUnits(t *testing.T) {
+	tests := []struct {
+		frame string
+		want  Frame
+	}{
+		{"ST,GS,+0012345kg", Frame{WeightKg: 12345}},
+		{"US,NT,-12.5kg", Frame{WeightKg: -12.5, Motion: true}},
+		{"ST,GS,+1.25t", Frame{WeightKg: 1250}},
+		{"ST,GS, 2500 g", Frame{WeightKg: 2.5}},
+		{"ST,GS,+100lb", Frame{WeightKg: 100 * poundsToKg}},
+		{"ST,GS,+4200", Frame{WeightKg: 4200}},
+	}
+	for _, tt := range tests {
+		got, err := ASCII{}.Decode([]byte(tt.frame))
+		if err != nil {
+			t.Errorf("Decode(%q): %v", tt.frame, err)
+			continue
+		}
+		if !sameFrame(got, tt.want) {
+			t.Errorf("Decode(%q) = %+v, want %+v", tt.frame, got, tt.want)
+		}
+	}
+}
+
+func TestProtocolByName(t *testing.T) {
+	for name, want := range map[string]string{"": "ascii", "ST-GS": "ascii", "Toledo": "toledo", "mettler": "toledo"} {
+		p, err := ProtocolByName(name)
+		if err != nil || p.Name() != want {
+			t.Errorf("ProtocolByName(%q) = %v, %v; want %s", name, p, err, want)
+		}
+	}
+	if _, err := ProtocolByName("modbus"); err == nil {
+		t.Error("ProtocolByName accepted an unknown protocol")
+	}
+}
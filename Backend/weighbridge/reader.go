@@ -0,0 +1,249 @@
+// Package weighbridge reads live weights from a weighbridge indicator's
+// continuous output over a serial port or a TCP socket.
+package weighbridge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrNoReading is returned when the indicator has not reported recently.
+	ErrNoReading = errors.New("no recent weighbridge reading")
+	// ErrUnstable is returned when the platform has not settled long enough to lock a weight.
+	ErrUnstable = errors.New("weighbridge reading is not stable")
+)
+
+// Reading is the indicator's latest weight as seen by the server.
+type Reading struct {
+	WeightKg   float64 `json:"weight_kg"`
+	WeightTons float64 `json:"weight_tons"`
+	Motion     bool    `json:"motion"`
+	OutOfRange bool    `json:"out_of_range"`
+	// Stable is set once the weight has been steady for the configured window;
+	// a stable reading is the one create and complete capture.
+	Stable      bool       `json:"stable"`
+	StableSince *time.Time `json:"stable_since,omitempty"`
+	At          time.Time  `json:"at"`
+}
+
+// Config configures a Reader.
+type Config struct {
+	// Source is tcp://host:port or serial:///dev/ttyUSB0?baud=9600.
+	Source   string
+	Protocol Protocol
+	// StableFor is how long the weight must stay within Tolerance, without
+	// the indicator reporting motion, before it is locked.
+	StableFor time.Duration
+	// Tolerance is the largest change in kg still considered the same weight.
+	Tolerance float64
+	// MaxAge is how old the latest reading may be before it is ignored.
+	MaxAge time.Duration
+	// IdleTimeout is how long the connection may go without a valid frame before
+	// it is dropped and reopened, e.g. after a converter lost power without closing it.
+	IdleTimeout time.Duration
+}
+
+// Reader keeps a connection to one indicator and tracks its latest reading.
+type Reader struct {
+	cfg Config
+
+	mu          sync.Mutex
+	latest      *Reading
+	stableSince time.Time
+	stableKg    float64
+	subscribers map[chan Reading]struct{}
+}
+
+// FromEnv builds a reader from WEIGHBRIDGE_SOURCE and WEIGHBRIDGE_PROTOCOL
+// ("ascii" or "toledo"). WEIGHBRIDGE_STABLE_SECONDS (default 2) and
+// WEIGHBRIDGE_TOLERANCE_KG (default 10) tune stability. It returns nil when
+// no source is set, in which case every weight is keyed manually.
+func FromEnv() (*Reader, error) {
+	source := os.Getenv("WEIGHBRIDGE_SOURCE")
+	if source == "" {
+		return nil, nil
+	}
+	protocol, err := ProtocolByName(os.Getenv("WEIGHBRIDGE_PROTOCOL"))
+	if err != nil {
+		return nil, err
+	}
+	cfg := Config{Source: source, Protocol: protocol}
+	if v := os.Getenv("WEIGHBRIDGE_STABLE_SECONDS"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New("WEIGHBRIDGE_STABLE_SECONDS must be a number")
+		}
+		cfg.StableFor = time.Duration(seconds * float64(time.Second))
+	}
+	if v := os.Getenv("WEIGHBRIDGE_TOLERANCE_KG"); v != "" {
+		if cfg.Tolerance, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, errors.New("WEIGHBRIDGE_TOLERANCE_KG must be a number")
+		}
+	}
+	return NewReader(cfg), nil
+}
+
+// NewReader creates a reader; call Run to start reading.
+func NewReader(cfg Config) *Reader {
+	if cfg.Protocol == nil {
+		cfg.Protocol = ASCII{}
+	}
+	if cfg.StableFor == 0 {
+		cfg.StableFor = 2 * time.Second
+	}
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = 10
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = 3 * time.Second
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 10 * time.Second
+	}
+	return &Reader{cfg: cfg, subscribers: make(map[chan Reading]struct{})}
+}
+
+// Run reads from the indicator until ctx is cancelled, reconnecting with
+// backoff whenever the connection drops or goes silent. The backoff starts
+// over once a connection has delivered readings.
+func (r *Reader) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		received, err := r.readOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			backoff = time.Second
+		}
+		log.Printf("Weighbridge %s: %v; reconnecting in %s", r.cfg.Source, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// readOnce reads one connection until it fails, reporting whether it decoded any frame.
+func (r *Reader) readOnce(ctx context.Context) (bool, error) {
+	conn, err := Open(r.cfg.Source)
+	if err != nil {
+		return false, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	var silent atomic.Bool
+	idle := time.AfterFunc(r.cfg.IdleTimeout, func() {
+		silent.Store(true)
+		conn.Close()
+	})
+	defer idle.Stop()
+
+	received := false
+	scanner := bufio.NewScanner(conn)
+	scanner.Split(r.cfg.Protocol.Split)
+	for scanner.Scan() {
+		frame, err := r.cfg.Protocol.Decode(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		idle.Reset(r.cfg.IdleTimeout)
+		received = true
+		r.Observe(frame, time.Now())
+	}
+	if silent.Load() {
+		return received, fmt.Errorf("no frames for %s", r.cfg.IdleTimeout)
+	}
+	if err := scanner.Err(); err != nil {
+		return received, err
+	}
+	return received, errors.New("connection closed")
+}
+
+// Observe records a decoded frame. It is called by Run for every frame and
+// can be fed directly when readings come from elsewhere.
+func (r *Reader) Observe(f Frame, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	steady := !f.Motion && !f.OutOfRange
+	if !steady || r.stableSince.IsZero() || math.Abs(f.WeightKg-r.stableKg) > r.cfg.Tolerance ||
+		(r.latest != nil && at.Sub(r.latest.At) > r.cfg.MaxAge) {
+		r.stableSince, r.stableKg = time.Time{}, f.WeightKg
+		if steady {
+			r.stableSince = at
+		}
+	}
+
+	reading := Reading{
+		WeightKg:   f.WeightKg,
+		WeightTons: f.WeightKg / 1000,
+		Motion:     f.Motion,
+		OutOfRange: f.OutOfRange,
+		At:         at,
+	}
+	if !r.stableSince.IsZero() && at.Sub(r.stableSince) >= r.cfg.StableFor {
+		since := r.stableSince
+		reading.Stable, reading.StableSince = true, &since
+	}
+	r.latest = &reading
+
+	for ch := range r.subscribers {
+		select {
+		case ch <- reading:
+		default:
+			// Slow subscribers miss readings rather than blocking the indicator.
+		}
+	}
+}
+
+// Current returns the latest reading if the indicator reported recently.
+func (r *Reader) Current() (Reading, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.latest == nil || time.Since(r.latest.At) > r.cfg.MaxAge {
+		return Reading{}, ErrNoReading
+	}
+	return *r.latest, nil
+}
+
+// Locked returns the current reading only if it is stable.
+func (r *Reader) Locked() (Reading, error) {
+	reading, err := r.Current()
+	if err != nil {
+		return Reading{}, err
+	}
+	if !reading.Stable {
+		return reading, ErrUnstable
+	}
+	return reading, nil
+}
+
+// Subscribe returns a channel receiving every new reading and a function to stop.
+func (r *Reader) Subscribe() (<-chan Reading, func()) {
+	ch := make(chan Reading, 16)
+	r.mu.Lock()
+	r.subscribers[ch] = struct{}{}
+	r.mu.Unlock()
+	return ch, func() {
+		r.mu.Lock()
+		delete(r.subscribers, ch)
+		r.mu.Unlock()
+	}
+}
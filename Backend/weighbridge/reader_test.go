@@ -0,0 +1,239 @@
+package weighbridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestObserveLocksAfterStableWindow(t *testing.T) {
+	r := NewReader(Config{StableFor: 2 * time.Second, Tolerance: 10, MaxAge: 5 * time.Second})
+	start := time.Now().Add(-3 * time.Second)
+
+	r.Observe(Frame{WeightKg: 12000}, start)
+	r.Observe(Frame{WeightKg: 12005}, start.Add(time.Second))
+	if _, err := r.Locked(); !errors.Is(err, ErrUnstable) {
+		t.Fatalf("locked before the stable window elapsed: %v", err)
+	}
+
+	r.Observe(Frame{WeightKg: 11998}, start.Add(2*time.Second))
+	reading, err := r.Locked()
+	if err != nil {
+		t.Fatalf("Locked: %v", err)
+	}
+	if reading.WeightKg != 11998 || reading.StableSince == nil || !reading.StableSince.Equal(start) {
+		t.Fatalf("locked reading = %+v", reading)
+	}
+
+	// Motion and drift beyond the tolerance both restart the window.
+	r.Observe(Frame{WeightKg: 11998, Motion: true}, start.Add(2500*time.Millisecond))
+	if reading, _ := r.Current(); reading.Stable {
+		t.Fatal("reading in motion reported stable")
+	}
+	r.Observe(Frame{WeightKg: 12000}, start.Add(2600*time.Millisecond))
+	r.Observe(Frame{WeightKg: 12050}, start.Add(3*time.Second))
+	if _, err := r.Locked(); !errors.Is(err, ErrUnstable) {
+		t.Fatalf("drifting weight locked: %v", err)
+	}
+}
+
+func TestObserveOutOfRangeNeverLocks(t *testing.T) {
+	r := NewReader(Config{StableFor: time.Second})
+	start := time.Now().Add(-2 * time.Second)
+	for i := 0; i <= 4; i++ {
+		r.Observe(Frame{WeightKg: 60000, OutOfRange: true}, start.Add(time.Duration(i)*500*time.Millisecond))
+	}
+	if _, err := r.Locked(); !errors.Is(err, ErrUnstable) {
+		t.Fatalf("overloaded platform locked: %v", err)
+	}
+}
+
+func TestCurrentExpiresStaleReading(t *testing.T) {
+	r := NewReader(Config{MaxAge: time.Second})
+	r.Observe(Frame{WeightKg: 500}, time.Now().Add(-2*time.Second))
+	if _, err := r.Current(); !errors.Is(err, ErrNoReading) {
+		t.Fatalf("stale reading returned: %v", err)
+	}
+}
+
+// indicator is a loopback TCP weighbridge indicator for Reader tests.
+type indicator struct {
+	t     *testing.T
+	ln    net.Listener
+	conns chan net.Conn
+}
+
+func newIndicator(t *testing.T) *indicator {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ind := &indicator{t: t, ln: ln, conns: make(chan net.Conn, 16)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			ind.conns <- conn
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		<-done
+		close(ind.conns)
+		for conn := range ind.conns {
+			conn.Close()
+		}
+	})
+	return ind
+}
+
+func (ind *indicator) source() string {
+	return "tcp://" + ind.ln.Addr().String()
+}
+
+// accept waits for the reader to connect.
+func (ind *indicator) accept(timeout time.Duration) net.Conn {
+	ind.t.Helper()
+	select {
+	case conn := <-ind.conns:
+		ind.t.Cleanup(func() { conn.Close() })
+		return conn
+	case <-time.After(timeout):
+		ind.t.Fatalf("reader did not connect within %s", timeout)
+		return nil
+	}
+}
+
+// stream writes frames from p every interval until ctx ends or the write fails.
+func stream(ctx context.Context, conn net.Conn, p Protocol, f Frame, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := conn.Write(p.Encode(f)); err != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitFor polls until cond holds or the timeout passes.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startReader(t *testing.T, cfg Config) *Reader {
+	t.Helper()
+	r := NewReader(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return r
+}
+
+func TestReaderLocksStableWeightOverTCP(t *testing.T) {
+	ind := newIndicator(t)
+	r := startReader(t, Config{Source: ind.source(), Protocol: Toledo{}, StableFor: 200 * time.Millisecond, MaxAge: time.Second})
+	updates, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+
+	conn := ind.accept(2 * time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream(ctx, conn, Toledo{}, Frame{WeightKg: 12340}, 20*time.Millisecond)
+
+	select {
+	case first := <-updates:
+		if first.Stable || first.WeightKg != 12340 {
+			t.Fatalf("first reading = %+v, want unstable 12340 kg", first)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reading from the indicator")
+	}
+
+	waitFor(t, 2*time.Second, "a locked weight", func() bool {
+		_, err := r.Locked()
+		return err == nil
+	})
+	reading, _ := r.Locked()
+	if reading.WeightKg != 12340 || reading.WeightTons != 12.34 {
+		t.Fatalf("locked reading = %+v", reading)
+	}
+}
+
+func TestReaderReconnectsAfterDisconnect(t *testing.T) {
+	ind := newIndicator(t)
+	r := startReader(t, Config{Source: ind.source(), Protocol: ASCII{}, MaxAge: 200 * time.Millisecond})
+
+	first := ind.accept(2 * time.Second)
+	first.Write(ASCII{}.Encode(Frame{WeightKg: 1000}))
+	waitFor(t, time.Second, "the first reading", func() bool {
+		reading, err := r.Current()
+		return err == nil && reading.WeightKg == 1000
+	})
+	first.Close()
+
+	// The old reading expires while the reader is reconnecting.
+	waitFor(t, time.Second, "the reading to expire", func() bool {
+		_, err := r.Current()
+		return errors.Is(err, ErrNoReading)
+	})
+
+	second := ind.accept(3 * time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream(ctx, second, ASCII{}, Frame{WeightKg: 2000}, 20*time.Millisecond)
+	waitFor(t, time.Second, "a reading after reconnecting", func() bool {
+		reading, err := r.Current()
+		return err == nil && reading.WeightKg == 2000
+	})
+}
+
+func TestReaderDropsSilentConnection(t *testing.T) {
+	ind := newIndicator(t)
+	r := startReader(t, Config{Source: ind.source(), Protocol: ASCII{}, MaxAge: 100 * time.Millisecond, IdleTimeout: 300 * time.Millisecond})
+
+	// The indicator sends one frame, then goes quiet without closing the socket.
+	silent := ind.accept(2 * time.Second)
+	silent.Write(ASCII{}.Encode(Frame{WeightKg: 750}))
+	waitFor(t, time.Second, "the first reading", func() bool {
+		_, err := r.Current()
+		return err == nil
+	})
+	waitFor(t, time.Second, "the reading to time out", func() bool {
+		_, err := r.Current()
+		return errors.Is(err, ErrNoReading)
+	})
+
+	// The idle timeout closes the quiet socket and the reader dials again.
+	ind.accept(3 * time.Second)
+	silent.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := silent.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("silent connection still open: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package weighbridge
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Open connects to an indicator. Supported sources are
+// tcp://host:port (serial-to-Ethernet converters) and
+// serial:///dev/ttyUSB0?baud=9600&parity=none&databits=8, which also works
+// for a pseudo-terminal.
+func Open(source string) (io.ReadCloser, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid weighbridge source %q: %w", source, err)
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		return net.DialTimeout("tcp", u.Host, 5*time.Second)
+	case "serial":
+		mode, err := serialMode(u.Query())
+		if err != nil {
+			return nil, err
+		}
+		return serial.Open(u.Path, mode)
+	}
+	return nil, fmt.Errorf("unsupported weighbridge source scheme %q", u.Scheme)
+}
+
+func serialMode(q url.Values) (*serial.Mode, error) {
+	mode := &serial.Mode{BaudRate: 9600, DataBits: 8, Parity: serial.NoParity, StopBits: serial.OneStopBit}
+	if v := q.Get("baud"); v != "" {
+		baud, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid baud rate %q", v)
+		}
+		mode.BaudRate = baud
+	}
+	if v := q.Get("databits"); v != "" {
+		bits, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data bits %q", v)
+		}
+		mode.DataBits = bits
+	}
+	switch q.Get("parity") {
+	case "", "none":
+	case "even":
+		mode.Parity = serial.EvenParity
+	case "odd":
+		mode.Parity = serial.OddParity
+	default:
+		return nil, fmt.Errorf("invalid parity %q", q.Get("parity"))
+	}
+	if q.Get("stopbits") == "2" {
+		mode.StopBits = serial.TwoStopBits
+	}
+	return mode, nil
+}
@@ -14,12 +14,11 @@ const EditUserModal = ({ isOpen, onClose, user, roles, onSave }) => {
     };
     
     const [formData, setFormData] = useState(getInitialFormData(user));
-    const [selectedRole, setSelectedRole] = useState('');
+    const [selectedRoles, setSelectedRoles] = useState([]);
 
     useEffect(() => {
         if (user && roles.length > 0) {
-            const currentRole = roles.find(r => r.name === user.role_name);
-            setSelectedRole(currentRole ? currentRole.id : '');
+            setSelectedRoles(user.role_ids || []);
         }
         // When the user prop changes, reset the form data.
         setFormData(getInitialFormData(user));
@@ -30,18 +29,18 @@ const EditUserModal = ({ isOpen, onClose, user, roles, onSave }) => {
         setFormData(prev => ({ ...prev, [name]: value }));
     };
 
-    const handleRoleChange = (e) => {
-        setSelectedRole(e.target.value);
+    const handleRoleToggle = (roleId) => {
+        setSelectedRoles(prev => prev.includes(roleId) ? prev.filter(id => id !== roleId) : [...prev, roleId]);
     };
 
     const handleSave = () => {
-        if (!selectedRole) {
-            alert('Please select a role for the user.');
+        if (selectedRoles.length === 0) {
+            alert('Please select at least one role for the user.');
             return;
         }
         const userData = {
             ...formData, // Use the spread operator for cleanliness
-            role_id: parseInt(selectedRole, 10),
+            role_ids: selectedRoles,
         };
         onSave(user.id, userData);
     };
@@ -63,15 +62,14 @@ const EditUserModal = ({ isOpen, onClose, user, roles, onSave }) => {
                     <InputField label="Designation" name="designation" value={formData.designation} onChange={handleChange} icon={<FaBriefcase />} />
                     
                     <div>
-                        <label className="block text-sm font-medium text-slate-700 mb-1">Role</label>
-                        <div className="relative">
-                            <FaUserShield className="absolute left-4 top-1/2 -translate-y-1/2 text-slate-400" />
-                            <select value={selectedRole} onChange={handleRoleChange} className="w-full h-12 pl-12 pr-4 border border-slate-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500">
-                                <option value="" disabled>Select a role</option>
-                                {roles.map(role => (
-                                    <option key={role.id} value={role.id}>{role.name}</option>
-                                ))}
-                            </select>
+                        <label className="flex items-center gap-2 text-sm font-medium text-slate-700 mb-1"><FaUserShield className="text-slate-400" /> Roles</label>
+                        <div className="grid grid-cols-2 gap-2 max-h-48 overflow-y-auto border border-slate-300 rounded-lg p-3">
+                            {roles.map(role => (
+                                <label key={role.id} className="flex items-center gap-2 text-sm text-slate-700">
+                                    <input type="checkbox" checked={selectedRoles.includes(role.id)} onChange={() => handleRoleToggle(role.id)} />
+                                    {role.name}
+                                </label>
+                            ))}
                         </div>
                     </div>
                 </div>
@@ -6,15 +6,20 @@ import { getCompletedEntries } from '../services/apiService';
 
 const CompletedEntriesPage = () => {
   const [entries, setEntries] = useState([]);
+  const [nextCursor, setNextCursor] = useState(null);
+  const [total, setTotal] = useState(0);
   const [loading, setLoading] = useState(true);
+  const [loadingMore, setLoadingMore] = useState(false);
   const [error, setError] = useState('');
 
   useEffect(() => {
     const fetchData = async () => {
       try {
         setLoading(true);
-        const response = await getCompletedEntries();
-        setEntries(response.data || []);
+        const response = await getCompletedEntries({ limit: 200 });
+        setEntries(response.data.entries || []);
+        setNextCursor(response.data.next_cursor || null);
+        setTotal(response.data.total || 0);
       } catch (err) {
         setError('Failed to fetch completed entries. Please try again.');
         console.error(err);
@@ -25,6 +30,20 @@ const CompletedEntriesPage = () => {
     fetchData();
   }, []);
 
+  const handleLoadMore = async () => {
+    try {
+      setLoadingMore(true);
+      const response = await getCompletedEntries({ limit: 200, cursor: nextCursor });
+      setEntries(prev => [...prev, ...(response.data.entries || [])]);
+      setNextCursor(response.data.next_cursor || null);
+    } catch (err) {
+      setError('Failed to fetch more completed entries. Please try again.');
+      console.error(err);
+    } finally {
+      setLoadingMore(false);
+    }
+  };
+
   const [searchTerm, setSearchTerm] = useState('');
   const [dateRange, setDateRange] = useState({ start: '', end: '' });
   
@@ -129,6 +148,15 @@ const CompletedEntriesPage = () => {
             </tbody>
           </table>
          </div>
+         <div className="flex justify-between items-center pt-4 text-sm text-slate-500">
+            <span>Showing {entries.length} of {total} completed entries</span>
+            {nextCursor && (
+              <button onClick={handleLoadMore} disabled={loadingMore}
+                className="px-4 py-2 bg-slate-100 text-slate-700 font-semibold rounded-lg hover:bg-slate-200 transition disabled:opacity-50">
+                {loadingMore ? 'Loading...' : 'Load more'}
+              </button>
+            )}
+         </div>
       </div>
     </div>
   );
@@ -1,7 +1,7 @@
 import React, { useState, useEffect, useMemo } from 'react';
 import { FaShoppingCart, FaRupeeSign, FaWeightHanging, FaTimes, FaFileExcel, FaTruck, FaPercent, FaCalculator } from 'react-icons/fa';
 import CreatableSelect from 'react-select/creatable';
-import { getCompletedEntries, createSaleEntry, getAllPartners, getMaterialSales, createPartner } from '../services/apiService';
+import { getCompletedEntries, getAllEntryPages, createSaleEntry, getAllPartners, getMaterialSales, createPartner } from '../services/apiService';
 import * as XLSX from 'xlsx';
 
 const SummaryCard = ({ title, value, icon, color }) => (
@@ -53,7 +53,8 @@ const SaleEntryModal = ({ isOpen, onClose, entry, party, onSave, transporters })
         return null;
     }
 
-    const originalWeight = parseFloat(entry.net_weight_tons) || 0;
+    // A dispatch may already be partly billed; this sale bills what is left.
+    const originalWeight = parseFloat(entry.unbilled_weight_tons ?? entry.net_weight_tons) || 0;
     const rate = parseFloat(formData.rate) || 0;
     const ratePerTon = rateUnit === 'kg' ? rate * 1000 : rate;
     const gstPercentage = parseFloat(formData.gst) || 0;
@@ -91,6 +92,7 @@ const SaleEntryModal = ({ isOpen, onClose, entry, party, onSave, transporters })
             transporter: selectedTransporter,
             rate: ratePerTon,
             original_weight_tons: originalWeight,
+            weight_share_tons: originalWeight,
             deduction_type: deductionType,
             deduction_value: parseFloat(deductionValue) || 0,
             deduction_amount: deductionAmount,
@@ -344,14 +346,21 @@ const MaterialSalesPage = () => {
     const fetchData = async () => {
         try {
             setLoading(true);
-            const [entriesRes, partnersRes, salesRes] = await Promise.all([
-                getCompletedEntries(),
+            const [dispatches, partnersRes, salesRes] = await Promise.all([
+                getAllEntryPages(getCompletedEntries, { entry_type: 'Empty Vehicle' }),
                 getAllPartners(),
                 getMaterialSales()
             ]);
 
-            const soldEntryIds = new Set((salesRes.data || []).map(s => s.inward_entry_id));
-            setCompletedEntries((entriesRes.data || []).filter(e => e.entry_type === 'Item Export' && !soldEntryIds.has(e.id)));
+            // Cancelled sales no longer bill their share of the dispatch.
+            const billedTons = {};
+            (salesRes.data || []).filter(s => !s.cancelled_at).forEach(s => {
+                billedTons[s.inward_entry_id] = (billedTons[s.inward_entry_id] || 0) + (s.original_weight_tons ?? s.net_weight_tons);
+            });
+            setCompletedEntries(dispatches
+                .filter(e => e.entry_type === 'Empty Vehicle' && e.material)
+                .map(e => ({ ...e, unbilled_weight_tons: parseFloat((e.net_weight_tons - (billedTons[e.id] || 0)).toFixed(3)) }))
+                .filter(e => e.unbilled_weight_tons > 0));
             
             setAllPartners(partnersRes.data || []);
             setSalesLog(salesRes.data || []);
@@ -436,9 +445,10 @@ const MaterialSalesPage = () => {
     };
 
     const dailySummary = useMemo(() => {
-        const totalSales = salesLog.reduce((sum, s) => sum + s.total_amount, 0);
-        const totalQuantity = salesLog.reduce((sum, s) => sum + (s.billing_weight_tons || s.net_weight_tons), 0);
-        return { totalSales, totalQuantity, transactionCount: salesLog.length };
+        const activeSales = salesLog.filter(s => !s.cancelled_at);
+        const totalSales = activeSales.reduce((sum, s) => sum + s.total_amount, 0);
+        const totalQuantity = activeSales.reduce((sum, s) => sum + (s.billing_weight_tons || s.net_weight_tons), 0);
+        return { totalSales, totalQuantity, transactionCount: activeSales.length };
     }, [salesLog]);
 
     if (loading) return <div className="text-center p-8">Loading available materials...</div>;
@@ -476,7 +486,7 @@ const MaterialSalesPage = () => {
                                     <td className="td">{new Date(entry.completed_at).toLocaleDateString()}</td>
                                     <td className="td font-medium">{entry.material}</td>
                                     <td className="td">{entry.vehicle_number}</td>
-                                    <td className="td text-right font-bold">{entry.net_weight_tons?.toFixed(3)}</td>
+                                    <td className="td text-right font-bold">{entry.unbilled_weight_tons?.toFixed(3)}</td>
                                     <td className="td text-center">
                                         <button onClick={() => handleOpenSaleModal(entry)} className="px-4 py-1.5 bg-blue-500 text-white font-semibold rounded-lg hover:bg-blue-600">
                                             Log Sale
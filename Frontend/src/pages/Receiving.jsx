@@ -3,7 +3,7 @@ import { FaTruckLoading, FaTruck, FaTint } from 'react-icons/fa';
 import PendingEntriesTable from '../components/receiving/PendingEntriesTable';
 import CompleteEntryModal from '../components/receiving/CompleteEntryModal';
 import InwardEntryModal from '../components/receiving/InwardEntryModal';
-import { getPendingEntries, createInwardEntry, completeInwardEntry, getAllPartners, createPartner, deleteInwardEntry } from '../services/apiService';
+import { getPendingEntries, getAllEntryPages, createInwardEntry, completeInwardEntry, getAllPartners, createPartner, deleteInwardEntry } from '../services/apiService';
 import { EXPORTABLE_MATERIALS } from '../config/materials';
 
 const defaultMaterials = [
@@ -39,11 +39,11 @@ const ReceivingPage = () => {
     const fetchData = async () => {
         try {
             setLoading(true);
-            const [pendingRes, partnersRes] = await Promise.all([
-                getPendingEntries(),
+            const [pendingEntries, partnersRes] = await Promise.all([
+                getAllEntryPages(getPendingEntries),
                 getAllPartners()
             ]);
-            setEntries(pendingRes.data || []);
+            setEntries(pendingEntries);
             setAllPartners(partnersRes.data || []);
         } catch (err) {
             setError('Failed to fetch initial data.');
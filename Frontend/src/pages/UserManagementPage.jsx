@@ -95,13 +95,20 @@ const UserManagementPage = () => {
                                         <td className="td">{user.email}</td>
                                         <td className="td">{user.designation || 'N/A'}</td>
                                         <td className="td">
-                                            <span className={`px-2 py-1 text-xs font-semibold rounded-full ${
-                                                user.role_name === 'Admin' ? 'bg-red-100 text-red-800' :
-                                                user.role_name === 'Supervisor' ? 'bg-yellow-100 text-yellow-800' :
-                                                'bg-blue-100 text-blue-800'
-                                            }`}>
-                                                {user.role_name || 'Not Assigned'}
-                                            </span>
+                                            <div className="flex flex-wrap gap-1">
+                                                {(user.roles || []).map(role => (
+                                                    <span key={role} className={`px-2 py-1 text-xs font-semibold rounded-full ${
+                                                        role === 'Admin' ? 'bg-red-100 text-red-800' :
+                                                        role === 'Supervisor' ? 'bg-yellow-100 text-yellow-800' :
+                                                        'bg-blue-100 text-blue-800'
+                                                    }`}>
+                                                        {role}
+                                                    </span>
+                                                ))}
+                                                {(!user.roles || user.roles.length === 0) && (
+                                                    <span className="px-2 py-1 text-xs font-semibold rounded-full bg-blue-100 text-blue-800">Not Assigned</span>
+                                                )}
+                                            </div>
                                         </td>
                                         <td className="td text-center">
                                             {user.is_approved ? (
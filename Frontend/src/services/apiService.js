@@ -21,10 +21,23 @@ api.interceptors.request.use(
     }
 );
 
-// Response interceptor - handle 401 errors automatically
+// Response interceptor - refresh the session once on 401, then give up and redirect
+let refreshPromise = null;
 api.interceptors.response.use(
     (response) => response,
-    (error) => {
+    async (error) => {
+        const original = error.config;
+        if (error.response?.status === 401 && original && !original._retried) {
+            original._retried = true;
+            try {
+                refreshPromise = refreshPromise || authService.refresh().finally(() => { refreshPromise = null; });
+                const token = await refreshPromise;
+                original.headers['Authorization'] = `Bearer ${token}`;
+                return api(original);
+            } catch {
+                // fall through to logout below
+            }
+        }
         if (error.response?.status === 401) {
             console.log('401 Unauthorized - redirecting to login');
             authService.logout();
@@ -40,28 +53,90 @@ export const getPendingUsers = () => { return api.get('/admin/pending-users'); }
 export const getAllRoles = () => { return api.get('/admin/roles'); };
 export const getAllUsers = () => { return api.get('/admin/users'); };
 export const updateUser = (userId, userData) => { return api.put(`/admin/users/${userId}`, userData); };
-export const approveUser = (userId, roleId) => { return api.post(`/admin/approve/${userId}`, { role_id: roleId }); };
+export const approveUser = (userId, roleIds) => { return api.post(`/admin/approve/${userId}`, { role_ids: [].concat(roleIds) }); };
+export const getUserAccess = (userId) => { return api.get(`/admin/users/${userId}/permissions`); };
 export const rejectUser = (userId) => { return api.delete(`/admin/reject/${userId}`); };
 export const getAllPermissions = () => { return api.get('/admin/permissions'); };
 export const getPermissionsForRole = (roleId) => { return api.get(`/admin/roles/${roleId}/permissions`); };
 export const updatePermissionsForRole = (roleId, permissionIds) => { return api.put(`/admin/roles/${roleId}/permissions`, { permission_ids: permissionIds }); };
+export const getRoleTemplates = () => { return api.get('/admin/role-templates'); };
+export const createRole = (roleData) => { return api.post('/admin/roles', roleData); };
+export const updateRole = (roleId, roleData) => { return api.put(`/admin/roles/${roleId}`, roleData); };
+export const deleteRole = (roleId) => { return api.delete(`/admin/roles/${roleId}`); };
+export const cloneRole = (roleId, roleData) => { return api.post(`/admin/roles/${roleId}/clone`, roleData); };
+export const setRoleTwoFactorPolicy = (roleId, required) => { return api.put(`/admin/roles/${roleId}/two-factor`, { required }); };
+export const resetUserTwoFactor = (userId) => { return api.post(`/admin/users/${userId}/reset-two-factor`); };
+export const getUserIdentities = (userId) => { return api.get(`/admin/users/${userId}/identities`); };
+export const unlinkUserIdentity = (userId, identityId) => { return api.delete(`/admin/users/${userId}/identities/${identityId}`); };
+export const getAuditLog = (params) => { return api.get('/admin/audit-log', { params }); };
+export const exportAuditLog = (params) => { return api.get('/admin/audit-log/export', { params, responseType: 'blob' }); };
+export const getTwoFactorStatus = () => { return api.get('/auth/2fa'); };
+export const enrollTwoFactor = () => { return api.post('/auth/2fa/enroll'); };
+export const confirmTwoFactor = (code) => { return api.post('/auth/2fa/confirm', { code }).then((response) => { authService.storeTokens(response.data); return response; }); };
+export const disableTwoFactor = (password, code) => { return api.post('/auth/2fa/disable', { password, code }); };
+export const regenerateRecoveryCodes = (code) => { return api.post('/auth/2fa/recovery-codes', { code }); };
+export const getServiceAccounts = () => { return api.get('/admin/service-accounts'); };
+export const createServiceAccount = (accountData) => { return api.post('/admin/service-accounts', accountData); };
+export const getApiKeys = (accountId) => { return api.get(`/admin/service-accounts/${accountId}/keys`); };
+export const createApiKey = (accountId, keyData) => { return api.post(`/admin/service-accounts/${accountId}/keys`, keyData); };
+export const rotateApiKey = (accountId, keyId, graceHours = 0) => { return api.post(`/admin/service-accounts/${accountId}/keys/${keyId}/rotate`, { grace_hours: graceHours }); };
+export const revokeApiKey = (accountId, keyId) => { return api.delete(`/admin/service-accounts/${accountId}/keys/${keyId}`); };
 
 // --- Inward Entry Functions ---
 export const createInwardEntry = (entryData) => { return api.post('/operations/inward-entries', entryData); };
-export const getPendingEntries = () => { return api.get('/operations/inward-entries/pending'); };
-export const completeInwardEntry = (entryId, tareWeight, material = null) => {
-    const payload = { tare_weight_tons: tareWeight, material: material };
+// Listings return { entries, total, limit, next_cursor }; pass next_cursor back as params.cursor for the next page.
+export const getPendingEntries = (params) => { return api.get('/operations/inward-entries/pending', { params }); };
+// Pass manualWeight to record the typed weight instead of the weighbridge's locked reading,
+// or useStoredTare to complete with the vehicle's registered tare and skip the second weighing.
+export const completeInwardEntry = (entryId, tareWeight, material = null, manualWeight = false, useStoredTare = false) => {
+    const payload = { tare_weight_tons: tareWeight, material: material, manual_weight: manualWeight, use_stored_tare: useStoredTare };
     return api.put(`/operations/inward-entries/${entryId}/complete`, payload);
 };
-export const getCompletedEntries = () => { return api.get('/operations/inward-entries/completed'); };
+export const getCompletedEntries = (params) => { return api.get('/operations/inward-entries/completed', { params }); };
+// Follows next_cursor through every page of a listing and returns all entries.
+export const getAllEntryPages = async (getPage, params = {}) => {
+    const entries = [];
+    let cursor;
+    do {
+        const response = await getPage({ ...params, limit: 500, ...(cursor ? { cursor } : {}) });
+        entries.push(...(response.data.entries || []));
+        cursor = response.data.next_cursor;
+    } while (cursor);
+    return entries;
+};
+export const updateInwardEntryStatus = (entryId, status, note = '') => { return api.put(`/operations/inward-entries/${entryId}/status`, { status, note }); };
+export const getInwardEntryHistory = (entryId) => { return api.get(`/operations/inward-entries/${entryId}/history`); };
+// Every download after the first is a reprint and comes back marked DUPLICATE.
+export const downloadWeighmentSlip = (entryId) => { return api.get(`/operations/inward-entries/${entryId}/slip`, { responseType: 'blob' }); };
+export const getWeighbridgeReading = () => { return api.get('/operations/weighbridge/reading'); };
+// The stream needs the Authorization header, so it is read with fetch rather than EventSource.
+export const streamWeighbridgeReadings = (signal) => {
+    return fetch(`${API_URL}/operations/weighbridge/stream`, {
+        headers: { Authorization: `Bearer ${authService.getCurrentToken()}` },
+        signal,
+    });
+};
 export const deleteInwardEntry = (entryId) => {
     return api.delete(`/operations/inward-entries/${entryId}`);
 };
 
+// --- Vehicle Registry ---
+export const searchVehicles = (q, limit = 20) => { return api.get('/operations/vehicles', { params: { q, limit } }); };
+export const getVehicleByRegistration = (registration) => { return api.get(`/operations/vehicles/by-registration/${encodeURIComponent(registration)}`); };
+export const createVehicle = (vehicleData) => { return api.post('/operations/vehicles', vehicleData); };
+export const updateVehicle = (vehicleId, vehicleData) => { return api.put(`/operations/vehicles/${vehicleId}`, vehicleData); };
+export const setVehicleTare = (vehicleId, tareWeight, validUntil, manualWeight = false) => {
+    return api.put(`/operations/vehicles/${vehicleId}/tare`, { tare_weight_tons: tareWeight, valid_until: validUntil, manual_weight: manualWeight });
+};
+export const setVehicleBlacklist = (vehicleId, blacklisted, reason = '') => { return api.put(`/operations/vehicles/${vehicleId}/blacklist`, { blacklisted, reason }); };
+
 // --- Sorting, Inventory, and Cashbook ---
 export const createSortingLog = (logData) => { return api.post('/operations/sorting-log', logData); };
 export const getSortingLogs = () => { return api.get('/operations/sorting-logs'); };
 export const getInventory = () => { return api.get('/operations/inventory'); };
+export const getInventoryMovements = (params) => { return api.get('/operations/inventory/movements', { params }); };
+export const getInventoryPostingRules = () => { return api.get('/operations/inventory/posting-rules'); };
+export const setInventoryPostingRule = (entryType, rule) => { return api.put(`/operations/inventory/posting-rules/${encodeURIComponent(entryType)}`, rule); };
 export const getCashbookData = (date) => { return api.get(`/operations/cashbook?date=${date}`); };
 export const createCashbookTransaction = (transactionData) => { return api.post('/operations/cashbook', transactionData); };
 
@@ -93,14 +168,54 @@ export const createSaleEntry = (saleData) => {
         deduction_amount: saleData.deduction_amount,
         deduction_reason: saleData.deduction_reason,
         billing_weight_tons: saleData.billing_weight_tons,
+        // Optional; a figure outside the material's tolerance opens a dispute.
+        buyer_weight_tons: saleData.buyer_weight_tons ?? null,
+        // Optional; left out, the sale bills whatever earlier sales of the dispatch left unbilled.
+        weight_share_tons: saleData.weight_share_tons ?? null,
     };
     return api.post('/operations/sales', payload);
 };
 
+export const getMaterialTolerances = () => { return api.get('/operations/sales/tolerances'); };
+export const setMaterialTolerance = (material, tolerance) => { return api.put(`/operations/sales/tolerances/${encodeURIComponent(material)}`, tolerance); };
+export const getRoundingRules = () => { return api.get('/operations/sales/rounding-rules'); };
+export const setRoundingRule = (scope, rule) => { return api.put(`/operations/sales/rounding-rules/${scope}`, rule); };
+export const cancelMaterialSale = (saleId, reason) => { return api.post(`/operations/sales/${saleId}/credit-note`, { reason }); };
+export const getSaleCreditNotes = (params = {}) => { return api.get('/operations/sale-credit-notes', { params }); };
+export const getSaleCreditNote = (creditNoteId) => { return api.get(`/operations/sale-credit-notes/${creditNoteId}`); };
+export const getMaterialHSNCodes = () => { return api.get('/operations/sales/hsn-codes'); };
+export const setMaterialHSNCode = (material, hsnCode) => { return api.put(`/operations/sales/hsn-codes/${encodeURIComponent(material)}`, { hsn_code: hsnCode }); };
+export const issueTaxInvoice = (saleId) => { return api.post(`/operations/sales/${saleId}/invoice`); };
+export const getTaxInvoices = (params = {}) => { return api.get('/operations/tax-invoices', { params }); };
+export const getTaxInvoice = (invoiceId) => { return api.get(`/operations/tax-invoices/${invoiceId}`); };
+export const downloadTaxInvoice = (invoiceId) => { return api.get(`/operations/tax-invoices/${invoiceId}/pdf`, { responseType: 'blob' }); };
+export const exportEInvoice = (invoiceId) => { return api.get(`/operations/tax-invoices/${invoiceId}/e-invoice`, { responseType: 'blob' }); };
+export const exportEInvoices = (params = {}) => { return api.get('/operations/tax-invoices/e-invoice', { params, responseType: 'blob' }); };
+export const getReceivables = (partyId = null) => { return api.get('/operations/receivables', { params: partyId ? { party_id: partyId } : {} }); };
+export const getPartyStatement = (partyId, params = {}) => { return api.get(`/operations/receivables/parties/${partyId}/statement`, { params }); };
+export const downloadPartyStatement = (partyId, format, params = {}) => { return api.get(`/operations/receivables/parties/${partyId}/statement`, { params: { ...params, format }, responseType: 'blob' }); };
+export const getSalePayments = (params = {}) => { return api.get('/operations/receivables/payments', { params }); };
+export const getSalePayment = (paymentId) => { return api.get(`/operations/receivables/payments/${paymentId}`); };
+export const recordSalePayment = (payment) => { return api.post('/operations/receivables/payments', payment); };
+export const allocateSalePayment = (paymentId, allocations = []) => { return api.post(`/operations/receivables/payments/${paymentId}/allocations`, { allocations }); };
+export const openSaleDispute = (saleId, buyerWeightTons, note = '') => { return api.post(`/operations/sales/${saleId}/disputes`, { buyer_weight_tons: buyerWeightTons, note }); };
+export const getSaleDisputes = (status = '') => { return api.get('/operations/sale-disputes', { params: { status } }); };
+export const getSaleDispute = (disputeId) => { return api.get(`/operations/sale-disputes/${disputeId}`); };
+export const updateSaleDispute = (disputeId, update) => { return api.put(`/operations/sale-disputes/${disputeId}`, update); };
+export const uploadSaleDisputeAttachments = (disputeId, files) => {
+    const formData = new FormData();
+    files.forEach(file => formData.append('attachments', file));
+    return api.post(`/operations/sale-disputes/${disputeId}/attachments`, formData, { headers: { 'Content-Type': 'multipart/form-data' } });
+};
+export const downloadSaleDisputeAttachment = (disputeId, attachmentId) => {
+    return api.get(`/operations/sale-disputes/${disputeId}/attachments/${attachmentId}`, { responseType: 'blob' });
+};
+
 
 // --- Partner Functions ---
 export const getAllPartners = () => { return api.get('/operations/partners'); };
 export const createPartner = (name, type) => { return api.post('/operations/partners', { name: name, type: type }); };
+export const updatePartnerTaxDetails = (partnerId, details) => { return api.put(`/operations/partners/${partnerId}/tax-details`, details); };
 
 // --- Employee & Attendance Functions ---
 export const getEmployees = () => {
@@ -161,6 +276,11 @@ export const updateVendor = (vendorId, vendorData) => {
     return api.put(`/operations/vendors/${vendorId}`, vendorData);
 };
 
+// params: { from, to } as YYYY-MM-DD; either may be omitted.
+export const getVendorReceipts = (vendorId, params) => {
+    return api.get(`/operations/vendors/${vendorId}/receipts`, { params });
+};
+
 export const deleteVendor = (vendorId) => {
     return api.delete(`/operations/vendors/${vendorId}`);
 };
@@ -18,14 +18,67 @@ const login = async (email, password) => {
         email,
         password,
     });
-    if (response.data.token) {
-        localStorage.setItem('user_token', response.data.token);
-    }
+    storeTokens(response.data);
+    return response.data;
+};
+
+// Second login step for accounts with two-factor enabled; pass either an authenticator code or a recovery code.
+const completeTwoFactorLogin = async (challengeToken, { code, recoveryCode } = {}) => {
+    const response = await axios.post(`${API_URL}/login/2fa`, {
+        challenge_token: challengeToken,
+        code,
+        recovery_code: recoveryCode,
+    });
+    storeTokens(response.data);
+    return response.data;
+};
+
+// Single sign-on: startOidcLogin sends the browser to the identity provider, which
+// redirects back to OIDC_REDIRECT_URL with code and state for completeOidcLogin.
+const getOidcConfig = async () => {
+    const response = await axios.get(`${API_URL}/oidc`);
+    return response.data;
+};
+
+const startOidcLogin = async () => {
+    const response = await axios.get(`${API_URL}/oidc/authorize`);
+    window.location.href = response.data.authorization_url;
+};
+
+const completeOidcLogin = async (code, state) => {
+    const response = await axios.post(`${API_URL}/oidc/callback`, { code, state });
+    storeTokens(response.data);
     return response.data;
 };
 
+const storeTokens = (data) => {
+    if (data.token) {
+        localStorage.setItem('user_token', data.token);
+    }
+    if (data.refresh_token) {
+        localStorage.setItem('refresh_token', data.refresh_token);
+    }
+};
+
+// Exchange the stored refresh token for a new token pair. Resolves to the new access token.
+const refresh = async () => {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) {
+        throw new Error('No refresh token');
+    }
+    const response = await axios.post(`${API_URL}/refresh`, { refresh_token: refreshToken });
+    storeTokens(response.data);
+    return response.data.token;
+};
+
 const logout = () => {
+    const token = localStorage.getItem('user_token');
+    if (token) {
+        // Revoke the server-side session; local state is cleared regardless of the outcome.
+        axios.post(`${API_URL}/logout`, null, { headers: { Authorization: `Bearer ${token}` } }).catch(() => {});
+    }
     localStorage.removeItem('user_token');
+    localStorage.removeItem('refresh_token');
 };
 
 // Enhanced token validation
@@ -37,8 +90,9 @@ const getCurrentToken = () => {
         const decoded = jwtDecode(token);
         const currentTime = Date.now() / 1000;
         
-        // Check if token is expired
-        if (decoded.exp < currentTime) {
+        // Check if token is expired; an expired access token is still usable
+        // while a refresh token is available to renew it.
+        if (decoded.exp < currentTime && !localStorage.getItem('refresh_token')) {
             console.log('Token expired, clearing storage');
             logout(); // Clear expired token
             return null;
@@ -67,8 +121,14 @@ const getCurrentUser = () => {
 };
 
 const authService = {
+    storeTokens,
     register,
     login,
+    completeTwoFactorLogin,
+    getOidcConfig,
+    startOidcLogin,
+    completeOidcLogin,
+    refresh,
     logout,
     getCurrentToken,
     getCurrentUser,